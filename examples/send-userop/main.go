@@ -9,6 +9,7 @@ import (
 	"github.com/eipcodelab/eip7702-go/pkg/batching"
 	"github.com/eipcodelab/eip7702-go/pkg/userop"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
 )
 
 const walletDelegateABI = `[
@@ -26,8 +27,13 @@ const walletDelegateABI = `[
 ]`
 
 func main() {
-	sender := common.HexToAddress("0x000000000000000000000000000000000000dEaD")
-	entryPoint := common.HexToAddress("0x0000000071727De22E5E9d8BAf0edAc6f37da032") // v0.6 example
+	// Demo key used in local dev tooling only. With EIP-7702 the EOA key signs for its own account.
+	key, err := crypto.HexToECDSA("4f3edf983ac63f7f8b7d0c4f76f2a5a70fadb53fcbf65f45d6fd5d77f07683ab")
+	if err != nil {
+		panic(err)
+	}
+	sender := crypto.PubkeyToAddress(key.PublicKey)
+	entryPoint := userop.EntryPointV07Address
 	if env := os.Getenv("ENTRYPOINT"); env != "" {
 		entryPoint = common.HexToAddress(env)
 	}
@@ -43,21 +49,41 @@ func main() {
 		panic(err)
 	}
 
-	op := userop.UserOperation{
+	op := userop.UserOperationV07{
 		Sender:               sender,
 		Nonce:                userop.HexBig(big.NewInt(0)),
-		InitCode:             []byte{},
 		CallData:             callData,
 		CallGasLimit:         userop.HexUint64(200_000),
 		VerificationGasLimit: userop.HexUint64(300_000),
-		PreVerificationGas:   userop.HexUint64(80_000),
-		MaxFeePerGas:         userop.HexBig(big.NewInt(35_000_000_000)),
-		MaxPriorityFeePerGas: userop.HexBig(big.NewInt(2_000_000_000)),
-		PaymasterAndData:     []byte{},
-		Signature:            []byte{0xde, 0xad, 0xbe, 0xef}, // replace with actual signature
 	}
 
-	payload, err := userop.BuildSendUserOperationRequest(op, entryPoint)
+	endpoint := os.Getenv("BUNDLER_RPC_URL")
+	var client *userop.BundlerClient
+	// Fixed dry-run chain and fees; with an endpoint they come from the node.
+	chainID := big.NewInt(1)
+	price := userop.GasPrice{MaxFeePerGas: big.NewInt(35_000_000_000), MaxPriorityFeePerGas: big.NewInt(2_000_000_000)}
+	if endpoint != "" {
+		client = userop.NewBundlerClient(endpoint)
+		if chainID, err = client.ChainID(context.Background()); err != nil {
+			panic(err)
+		}
+		oracle := userop.FeeHistoryOracle{Node: client, Policy: userop.FeePolicy{Percentile: 60, Multiplier: 1.1}}
+		if price, err = oracle.SuggestGasPrice(context.Background()); err != nil {
+			panic(err)
+		}
+	}
+	price.ApplyV07(&op)
+	// Priced offline with a dummy signature, before the op is signed.
+	preVerificationGas, err := userop.PreVerificationGasV07(op, userop.DefaultGasOverheads)
+	if err != nil {
+		panic(err)
+	}
+	op.PreVerificationGas = userop.HexUint64(preVerificationGas)
+	if err := userop.SignUserOperationV07(&op, key, entryPoint, chainID, userop.SignatureRawHash); err != nil {
+		panic(err)
+	}
+
+	payload, err := userop.BuildSendUserOperationV07Request(op, entryPoint)
 	if err != nil {
 		panic(err)
 	}
@@ -65,14 +91,12 @@ func main() {
 	fmt.Println("== ERC-4337 UserOperation over EIP-7702 account ==")
 	fmt.Println(string(payload))
 
-	endpoint := os.Getenv("BUNDLER_RPC_URL")
-	if endpoint == "" {
+	if client == nil {
 		fmt.Println("Dry-run only. Set BUNDLER_RPC_URL to broadcast.")
 		return
 	}
 
-	client := userop.NewBundlerClient(endpoint)
-	hash, err := client.SendUserOperation(context.Background(), op, entryPoint)
+	hash, err := client.SendUserOperationV07(context.Background(), op, entryPoint)
 	if err != nil {
 		panic(err)
 	}
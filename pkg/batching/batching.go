@@ -1,6 +1,7 @@
 package batching
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"math/big"
@@ -98,3 +99,26 @@ func EncodeFunctionCall(abiJSON string, method string, args ...any) ([]byte, err
 	}
 	return out, nil
 }
+
+// DecodeExecuteBatch decodes executeBatch calldata back into its calls. It is the
+// inverse of EncodeExecuteBatch and lets sponsors inspect call targets.
+func DecodeExecuteBatch(calldata []byte) ([]Call, error) {
+	parsedABI, err := getExecuteBatchABI()
+	if err != nil {
+		return nil, fmt.Errorf("parse executeBatch ABI: %w", err)
+	}
+	method := parsedABI.Methods["executeBatch"]
+	if len(calldata) < 4 || !bytes.Equal(calldata[:4], method.ID) {
+		return nil, errors.New("calldata is not executeBatch")
+	}
+	values, err := method.Inputs.Unpack(calldata[4:])
+	if err != nil {
+		return nil, fmt.Errorf("unpack executeBatch calldata: %w", err)
+	}
+	execCalls := *abi.ConvertType(values[0], new([]executeCall)).(*[]executeCall)
+	calls := make([]Call, len(execCalls))
+	for i, c := range execCalls {
+		calls[i] = Call{Target: c.Target, Value: c.Value, Data: c.Data}
+	}
+	return calls, nil
+}
@@ -33,3 +33,32 @@ func TestEncodeExecuteBatchRejectsEmptyCalls(t *testing.T) {
 		t.Fatal("expected error for empty call list")
 	}
 }
+
+func TestDecodeExecuteBatchRoundtrip(t *testing.T) {
+	calls := []batching.Call{
+		{Target: common.HexToAddress("0x0000000000000000000000000000000000000001"), Value: big.NewInt(0), Data: []byte{0x01}},
+		{Target: common.HexToAddress("0x0000000000000000000000000000000000000002"), Value: big.NewInt(7), Data: nil},
+	}
+	calldata, err := batching.EncodeExecuteBatch(calls)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	decoded, err := batching.DecodeExecuteBatch(calldata)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(decoded) != len(calls) {
+		t.Fatalf("decoded %d calls, want %d", len(decoded), len(calls))
+	}
+	for i := range calls {
+		if decoded[i].Target != calls[i].Target || decoded[i].Value.Cmp(calls[i].Value) != 0 || !bytes.Equal(decoded[i].Data, calls[i].Data) {
+			t.Fatalf("call %d = %+v, want %+v", i, decoded[i], calls[i])
+		}
+	}
+	if _, err := batching.DecodeExecuteBatch([]byte{0xde, 0xad, 0xbe, 0xef}); err == nil {
+		t.Fatal("expected error for foreign selector")
+	}
+	if _, err := batching.DecodeExecuteBatch(calldata[:40]); err == nil {
+		t.Fatal("expected error for truncated calldata")
+	}
+}
@@ -0,0 +1,181 @@
+package batching
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Operation is the Safe MultiSend operation flag.
+type Operation uint8
+
+const (
+	// OperationCall executes the entry with CALL.
+	OperationCall Operation = 0
+	// OperationDelegateCall executes the entry with DELEGATECALL in the account's context.
+	OperationDelegateCall Operation = 1
+
+	// multiSendHeaderSize is operation(1) + to(20) + value(32) + dataLength(32).
+	multiSendHeaderSize = 1 + common.AddressLength + 32 + 32
+)
+
+var (
+	multiSendSelector = crypto.Keccak256([]byte("multiSend(bytes)"))[:4]
+
+	// ErrDelegateCallNotAllowed is returned when a delegatecall entry is used without opt-in.
+	ErrDelegateCallNotAllowed = errors.New("delegatecall operation requires AllowDelegateCall")
+)
+
+// MultiSendTx is one entry of a MultiSend payload.
+type MultiSendTx struct {
+	Operation Operation
+	Call
+}
+
+// MultiSendOptions controls which operations are accepted when encoding or decoding.
+type MultiSendOptions struct {
+	// AllowDelegateCall permits OperationDelegateCall entries. Delegatecalls run
+	// arbitrary code with the account's storage and should only target trusted libraries.
+	AllowDelegateCall bool
+}
+
+// EncodeMultiSend encodes calls as multiSend(bytes) calldata using OperationCall for every entry.
+func EncodeMultiSend(calls []Call) ([]byte, error) {
+	txs := make([]MultiSendTx, len(calls))
+	for i, c := range calls {
+		txs[i] = MultiSendTx{Operation: OperationCall, Call: c}
+	}
+	return EncodeMultiSendTxs(txs, MultiSendOptions{})
+}
+
+// EncodeMultiSendTxs encodes entries as multiSend(bytes) calldata.
+func EncodeMultiSendTxs(txs []MultiSendTx, opts MultiSendOptions) ([]byte, error) {
+	packed, err := PackMultiSend(txs, opts)
+	if err != nil {
+		return nil, err
+	}
+	padded := (len(packed) + 31) / 32 * 32
+	out := make([]byte, 4+32+32+padded)
+	copy(out, multiSendSelector)
+	big.NewInt(32).FillBytes(out[4:36])
+	new(big.Int).SetInt64(int64(len(packed))).FillBytes(out[36:68])
+	copy(out[68:], packed)
+	return out, nil
+}
+
+// PackMultiSend returns the packed operation|to|value|dataLength|data byte stream.
+func PackMultiSend(txs []MultiSendTx, opts MultiSendOptions) ([]byte, error) {
+	if len(txs) == 0 {
+		return nil, errors.New("calls must not be empty")
+	}
+	var buf bytes.Buffer
+	for i, tx := range txs {
+		if err := opts.check(tx.Operation); err != nil {
+			return nil, fmt.Errorf("entry %d: %w", i, err)
+		}
+		value := tx.Value
+		if value == nil {
+			value = big.NewInt(0)
+		}
+		if value.Sign() < 0 || value.BitLen() > 256 {
+			return nil, fmt.Errorf("entry %d: value must be a uint256", i)
+		}
+		var word [32]byte
+		buf.WriteByte(byte(tx.Operation))
+		buf.Write(tx.Target.Bytes())
+		value.FillBytes(word[:])
+		buf.Write(word[:])
+		new(big.Int).SetInt64(int64(len(tx.Data))).FillBytes(word[:])
+		buf.Write(word[:])
+		buf.Write(tx.Data)
+	}
+	return buf.Bytes(), nil
+}
+
+// DecodeMultiSend decodes multiSend(bytes) calldata back into entries.
+func DecodeMultiSend(calldata []byte, opts MultiSendOptions) ([]MultiSendTx, error) {
+	if len(calldata) < 4+64 {
+		return nil, errors.New("multiSend calldata is too short")
+	}
+	if !bytes.Equal(calldata[:4], multiSendSelector) {
+		return nil, fmt.Errorf("unexpected selector 0x%x", calldata[:4])
+	}
+	args := calldata[4:]
+	offset := new(big.Int).SetBytes(args[:32])
+	if !offset.IsUint64() || offset.Uint64() > uint64(len(args)-32) {
+		return nil, errors.New("multiSend bytes offset is out of range")
+	}
+	start := offset.Uint64()
+	length := new(big.Int).SetBytes(args[start : start+32])
+	if !length.IsUint64() || length.Uint64() > uint64(len(args))-start-32 {
+		return nil, errors.New("multiSend bytes length is out of range")
+	}
+	return UnpackMultiSend(args[start+32:start+32+length.Uint64()], opts)
+}
+
+// UnpackMultiSend decodes a packed MultiSend byte stream.
+func UnpackMultiSend(packed []byte, opts MultiSendOptions) ([]MultiSendTx, error) {
+	if len(packed) == 0 {
+		return nil, errors.New("multiSend payload is empty")
+	}
+	var txs []MultiSendTx
+	for pos := 0; pos < len(packed); {
+		i := len(txs)
+		if len(packed)-pos < multiSendHeaderSize {
+			return nil, fmt.Errorf("entry %d: truncated header", i)
+		}
+		op := Operation(packed[pos])
+		if err := opts.check(op); err != nil {
+			return nil, fmt.Errorf("entry %d: %w", i, err)
+		}
+		pos++
+		target := common.BytesToAddress(packed[pos : pos+common.AddressLength])
+		pos += common.AddressLength
+		value := new(big.Int).SetBytes(packed[pos : pos+32])
+		pos += 32
+		dataLen := new(big.Int).SetBytes(packed[pos : pos+32])
+		pos += 32
+		if !dataLen.IsUint64() || dataLen.Uint64() > uint64(len(packed)-pos) {
+			return nil, fmt.Errorf("entry %d: data length exceeds payload", i)
+		}
+		n := int(dataLen.Uint64())
+		data := make([]byte, n)
+		copy(data, packed[pos:pos+n])
+		pos += n
+		txs = append(txs, MultiSendTx{
+			Operation: op,
+			Call:      Call{Target: target, Value: value, Data: data},
+		})
+	}
+	return txs, nil
+}
+
+// MultiSendCalls strips operation flags, rejecting any delegatecall entry.
+func MultiSendCalls(txs []MultiSendTx) ([]Call, error) {
+	calls := make([]Call, len(txs))
+	for i, tx := range txs {
+		if tx.Operation != OperationCall {
+			return nil, fmt.Errorf("entry %d: %w", i, ErrDelegateCallNotAllowed)
+		}
+		calls[i] = tx.Call
+	}
+	return calls, nil
+}
+
+func (o MultiSendOptions) check(op Operation) error {
+	switch op {
+	case OperationCall:
+		return nil
+	case OperationDelegateCall:
+		if !o.AllowDelegateCall {
+			return ErrDelegateCallNotAllowed
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown operation %d", op)
+	}
+}
@@ -0,0 +1,89 @@
+package batching_test
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/eipcodelab/eip7702-go/pkg/batching"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const multiSendABI = `[
+  {
+    "type": "function",
+    "name": "multiSend",
+    "stateMutability": "payable",
+    "inputs": [{"name": "transactions", "type": "bytes"}],
+    "outputs": []
+  }
+]`
+
+func TestEncodeMultiSendMatchesABI(t *testing.T) {
+	calls := makeCalls(2)
+	calls[1].Value = big.NewInt(7)
+	calldata, err := batching.EncodeMultiSend(calls)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	packed, err := batching.PackMultiSend([]batching.MultiSendTx{{Call: calls[0]}, {Call: calls[1]}}, batching.MultiSendOptions{})
+	if err != nil {
+		t.Fatalf("pack: %v", err)
+	}
+	if len(packed) != 2*(1+20+32+32)+len(calls[0].Data)+len(calls[1].Data) {
+		t.Fatalf("unexpected packed length: %d", len(packed))
+	}
+	want, err := batching.EncodeFunctionCall(multiSendABI, "multiSend", packed)
+	if err != nil {
+		t.Fatalf("abi encode: %v", err)
+	}
+	if !bytes.Equal(calldata, want) {
+		t.Fatalf("calldata mismatch:\n got %x\nwant %x", calldata, want)
+	}
+}
+
+func TestDecodeMultiSendRoundtrip(t *testing.T) {
+	txs := []batching.MultiSendTx{
+		{Operation: batching.OperationCall, Call: batching.Call{Target: common.HexToAddress("0x01"), Value: big.NewInt(5)}},
+		{Operation: batching.OperationDelegateCall, Call: batching.Call{Target: common.HexToAddress("0x02"), Value: big.NewInt(0), Data: []byte{0xca, 0xfe}}},
+	}
+	opts := batching.MultiSendOptions{AllowDelegateCall: true}
+	calldata, err := batching.EncodeMultiSendTxs(txs, opts)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	decoded, err := batching.DecodeMultiSend(calldata, opts)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(decoded) != 2 {
+		t.Fatalf("unexpected entry count: %d", len(decoded))
+	}
+	for i := range txs {
+		if decoded[i].Operation != txs[i].Operation || decoded[i].Target != txs[i].Target ||
+			decoded[i].Value.Cmp(txs[i].Value) != 0 || !bytes.Equal(decoded[i].Data, txs[i].Data) {
+			t.Fatalf("entry %d mismatch: %+v", i, decoded[i])
+		}
+	}
+	if _, err := batching.MultiSendCalls(decoded); !errors.Is(err, batching.ErrDelegateCallNotAllowed) {
+		t.Fatalf("expected delegatecall rejection, got %v", err)
+	}
+}
+
+func TestMultiSendRequiresDelegateCallOptIn(t *testing.T) {
+	txs := []batching.MultiSendTx{{Operation: batching.OperationDelegateCall, Call: batching.Call{Target: common.HexToAddress("0x02")}}}
+	if _, err := batching.PackMultiSend(txs, batching.MultiSendOptions{}); !errors.Is(err, batching.ErrDelegateCallNotAllowed) {
+		t.Fatalf("expected opt-in error on encode, got %v", err)
+	}
+	packed, err := batching.PackMultiSend(txs, batching.MultiSendOptions{AllowDelegateCall: true})
+	if err != nil {
+		t.Fatalf("pack: %v", err)
+	}
+	if _, err := batching.UnpackMultiSend(packed, batching.MultiSendOptions{}); !errors.Is(err, batching.ErrDelegateCallNotAllowed) {
+		t.Fatalf("expected opt-in error on decode, got %v", err)
+	}
+	if _, err := batching.UnpackMultiSend(packed[:40], batching.MultiSendOptions{AllowDelegateCall: true}); err == nil {
+		t.Fatal("expected truncated payload error")
+	}
+}
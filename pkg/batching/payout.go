@@ -0,0 +1,300 @@
+package batching
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const erc20TransferABIJSON = `[
+  {
+    "type": "function",
+    "name": "transfer",
+    "stateMutability": "nonpayable",
+    "inputs": [
+      {"name": "to", "type": "address"},
+      {"name": "value", "type": "uint256"}
+    ],
+    "outputs": [{"name": "", "type": "bool"}]
+  }
+]`
+
+var (
+	onceERC20ABI sync.Once
+	erc20ABI     abi.ABI
+	erc20ABIErr  error
+)
+
+func getERC20ABI() (abi.ABI, error) {
+	onceERC20ABI.Do(func() {
+		erc20ABI, erc20ABIErr = abi.JSON(strings.NewReader(erc20TransferABIJSON))
+	})
+	return erc20ABI, erc20ABIErr
+}
+
+// EncodeERC20Transfer encodes calldata for transfer(address,uint256).
+func EncodeERC20Transfer(to common.Address, amount *big.Int) ([]byte, error) {
+	parsedABI, err := getERC20ABI()
+	if err != nil {
+		return nil, fmt.Errorf("parse ERC-20 ABI: %w", err)
+	}
+	data, err := parsedABI.Pack("transfer", to, amount)
+	if err != nil {
+		return nil, fmt.Errorf("pack transfer calldata: %w", err)
+	}
+	return data, nil
+}
+
+// Token describes one payout asset. A zero Address means the native coin.
+type Token struct {
+	Symbol   string
+	Address  common.Address
+	Decimals uint8
+}
+
+// IsNative reports whether payouts in this token are plain value transfers.
+func (t Token) IsNative() bool {
+	return t.Address == (common.Address{})
+}
+
+// PayoutRow is one unvalidated (recipient, token, amount) record from an input file.
+// Amount is a decimal string in whole token units, e.g. "12.5".
+type PayoutRow struct {
+	Line      int    `json:"-"`
+	Recipient string `json:"recipient"`
+	Token     string `json:"token"`
+	Amount    string `json:"amount"`
+}
+
+// Payout is one validated transfer after duplicate recipients are merged.
+type Payout struct {
+	Recipient common.Address
+	Token     Token
+	Amount    *big.Int
+}
+
+// TokenTotal reconciles one token across the input rows and produced payouts.
+type TokenTotal struct {
+	Token      Token
+	Total      *big.Int
+	Rows       int
+	Recipients int
+}
+
+// PayoutReport summarizes a payout plan for reconciliation.
+type PayoutReport struct {
+	Rows    int
+	Payouts int
+	Chunks  int
+	Tokens  []TokenTotal
+}
+
+// PayoutPlan is the batching output for a payout file.
+type PayoutPlan struct {
+	Payouts []Payout
+	Chunks  []Chunk
+	Report  PayoutReport
+}
+
+// ParsePayoutCSV reads recipient,token,amount records. A leading header row is skipped.
+func ParsePayoutCSV(r io.Reader) ([]PayoutRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 3
+	reader.TrimLeadingSpace = true
+
+	var rows []PayoutRow
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read csv: %w", err)
+		}
+		if line == 1 && strings.EqualFold(strings.TrimSpace(record[0]), "recipient") {
+			continue
+		}
+		rows = append(rows, PayoutRow{
+			Line:      line,
+			Recipient: strings.TrimSpace(record[0]),
+			Token:     strings.TrimSpace(record[1]),
+			Amount:    strings.TrimSpace(record[2]),
+		})
+	}
+	return rows, nil
+}
+
+// ParsePayoutJSON reads a JSON array of {"recipient","token","amount"} objects.
+func ParsePayoutJSON(r io.Reader) ([]PayoutRow, error) {
+	var rows []PayoutRow
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&rows); err != nil {
+		return nil, fmt.Errorf("decode json: %w", err)
+	}
+	for i := range rows {
+		rows[i].Line = i + 1
+	}
+	return rows, nil
+}
+
+// BuildPayoutPlan validates rows, merges duplicate recipients per token and
+// chunks the resulting transfers into executeBatch payloads.
+// tokens is keyed by symbol or hex address; lookups are case-insensitive.
+func BuildPayoutPlan(rows []PayoutRow, tokens map[string]Token, limits SplitLimits) (*PayoutPlan, error) {
+	if len(rows) == 0 {
+		return nil, errors.New("payout rows must not be empty")
+	}
+	lookup := make(map[string]Token, 2*len(tokens))
+	for key, token := range tokens {
+		lookup[strings.ToLower(key)] = token
+		if !token.IsNative() {
+			lookup[strings.ToLower(token.Address.Hex())] = token
+		}
+	}
+
+	type payoutKey struct {
+		recipient common.Address
+		token     common.Address
+	}
+	var (
+		errs    []error
+		payouts []Payout
+		index   = make(map[payoutKey]int)
+		totals  = make(map[common.Address]*TokenTotal)
+		order   []common.Address
+	)
+	for _, row := range rows {
+		recipient, token, amount, err := parsePayoutRow(row, lookup)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("line %d: %w", row.Line, err))
+			continue
+		}
+		total, ok := totals[token.Address]
+		if !ok {
+			total = &TokenTotal{Token: token, Total: new(big.Int)}
+			totals[token.Address] = total
+			order = append(order, token.Address)
+		}
+		key := payoutKey{recipient: recipient, token: token.Address}
+		i, merged := index[key]
+		if merged {
+			sum := new(big.Int).Add(payouts[i].Amount, amount)
+			if sum.BitLen() > 256 {
+				errs = append(errs, fmt.Errorf("line %d: merged amount for %s overflows uint256", row.Line, recipient.Hex()))
+				continue
+			}
+			payouts[i].Amount = sum
+		}
+		sum := new(big.Int).Add(total.Total, amount)
+		if sum.BitLen() > 256 {
+			errs = append(errs, fmt.Errorf("line %d: token total overflows uint256", row.Line))
+			continue
+		}
+		total.Total = sum
+		total.Rows++
+		if merged {
+			continue
+		}
+		index[key] = len(payouts)
+		payouts = append(payouts, Payout{Recipient: recipient, Token: token, Amount: amount})
+		total.Recipients++
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	calls := make([]Call, len(payouts))
+	for i, p := range payouts {
+		call, err := p.Call()
+		if err != nil {
+			return nil, err
+		}
+		calls[i] = call
+	}
+	chunks, err := SplitCalls(calls, limits)
+	if err != nil {
+		return nil, err
+	}
+
+	report := PayoutReport{
+		Rows:    len(rows),
+		Payouts: len(payouts),
+		Chunks:  len(chunks),
+		Tokens:  make([]TokenTotal, len(order)),
+	}
+	for i, addr := range order {
+		report.Tokens[i] = *totals[addr]
+	}
+	return &PayoutPlan{Payouts: payouts, Chunks: chunks, Report: report}, nil
+}
+
+// Call returns the batch call that executes this payout.
+func (p Payout) Call() (Call, error) {
+	if p.Token.IsNative() {
+		return Call{Target: p.Recipient, Value: new(big.Int).Set(p.Amount)}, nil
+	}
+	data, err := EncodeERC20Transfer(p.Recipient, p.Amount)
+	if err != nil {
+		return Call{}, err
+	}
+	return Call{Target: p.Token.Address, Value: big.NewInt(0), Data: data}, nil
+}
+
+func parsePayoutRow(row PayoutRow, tokens map[string]Token) (common.Address, Token, *big.Int, error) {
+	if !common.IsHexAddress(row.Recipient) {
+		return common.Address{}, Token{}, nil, fmt.Errorf("invalid recipient address %q", row.Recipient)
+	}
+	recipient := common.HexToAddress(row.Recipient)
+	if recipient == (common.Address{}) {
+		return common.Address{}, Token{}, nil, errors.New("recipient must not be the zero address")
+	}
+	token, ok := tokens[strings.ToLower(row.Token)]
+	if !ok {
+		return common.Address{}, Token{}, nil, fmt.Errorf("unknown token %q", row.Token)
+	}
+	amount, err := ParseTokenAmount(row.Amount, token.Decimals)
+	if err != nil {
+		return common.Address{}, Token{}, nil, err
+	}
+	return recipient, token, amount, nil
+}
+
+// ParseTokenAmount converts a positive decimal string into base units.
+func ParseTokenAmount(amount string, decimals uint8) (*big.Int, error) {
+	whole, frac, hasFrac := strings.Cut(amount, ".")
+	if whole == "" && frac == "" {
+		return nil, fmt.Errorf("invalid amount %q", amount)
+	}
+	if hasFrac && frac == "" {
+		return nil, fmt.Errorf("invalid amount %q", amount)
+	}
+	if len(frac) > int(decimals) {
+		return nil, fmt.Errorf("amount %q has more than %d decimals", amount, decimals)
+	}
+	digits := whole + frac + strings.Repeat("0", int(decimals)-len(frac))
+	for _, r := range digits {
+		if r < '0' || r > '9' {
+			return nil, fmt.Errorf("invalid amount %q", amount)
+		}
+	}
+	out, ok := new(big.Int).SetString(digits, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid amount %q", amount)
+	}
+	if out.Sign() == 0 {
+		return nil, fmt.Errorf("amount %q must be positive", amount)
+	}
+	if out.BitLen() > 256 {
+		return nil, fmt.Errorf("amount %q overflows uint256", amount)
+	}
+	return out, nil
+}
@@ -0,0 +1,127 @@
+package batching_test
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/eipcodelab/eip7702-go/pkg/batching"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var payoutTokens = map[string]batching.Token{
+	"USDC": {Symbol: "USDC", Address: common.HexToAddress("0xA0b86991c6218b36c1d19d4a2e9eb0ce3606eb48"), Decimals: 6},
+	"ETH":  {Symbol: "ETH", Decimals: 18},
+}
+
+func TestBuildPayoutPlanFromCSV(t *testing.T) {
+	input := `recipient,token,amount
+0x2000000000000000000000000000000000000002,USDC,10.5
+0x3000000000000000000000000000000000000003,ETH,0.25
+0x2000000000000000000000000000000000000002,0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48,1.5
+`
+	rows, err := batching.ParsePayoutCSV(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	plan, err := batching.BuildPayoutPlan(rows, payoutTokens, batching.SplitLimits{MaxCalls: 1})
+	if err != nil {
+		t.Fatalf("plan: %v", err)
+	}
+	if len(plan.Payouts) != 2 || len(plan.Chunks) != 2 {
+		t.Fatalf("unexpected plan: %d payouts, %d chunks", len(plan.Payouts), len(plan.Chunks))
+	}
+	if plan.Payouts[0].Amount.Cmp(big.NewInt(12_000_000)) != 0 {
+		t.Fatalf("duplicate recipient not merged: %s", plan.Payouts[0].Amount)
+	}
+	native := plan.Chunks[1].Calls[0]
+	if native.Target != common.HexToAddress("0x3000000000000000000000000000000000000003") || len(native.Data) != 0 {
+		t.Fatal("native payout must be a plain value transfer")
+	}
+
+	report := plan.Report
+	if report.Rows != 3 || report.Payouts != 2 || len(report.Tokens) != 2 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	usdc := report.Tokens[0]
+	if usdc.Total.Cmp(big.NewInt(12_000_000)) != 0 || usdc.Rows != 2 || usdc.Recipients != 1 {
+		t.Fatalf("unexpected USDC totals: %+v", usdc)
+	}
+}
+
+func TestBuildPayoutPlanFromJSON(t *testing.T) {
+	input := `[{"recipient":"0x2000000000000000000000000000000000000002","token":"usdc","amount":"3"}]`
+	rows, err := batching.ParsePayoutJSON(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	plan, err := batching.BuildPayoutPlan(rows, payoutTokens, batching.SplitLimits{})
+	if err != nil {
+		t.Fatalf("plan: %v", err)
+	}
+	if len(plan.Chunks) != 1 || plan.Chunks[0].Calls[0].Target != payoutTokens["USDC"].Address {
+		t.Fatal("expected one ERC-20 transfer chunk")
+	}
+}
+
+func TestBuildPayoutPlanRejectsInvalidRows(t *testing.T) {
+	rows := []batching.PayoutRow{
+		{Line: 1, Recipient: "0x123", Token: "USDC", Amount: "1"},
+		{Line: 2, Recipient: "0x2000000000000000000000000000000000000002", Token: "DAI", Amount: "1"},
+		{Line: 3, Recipient: "0x2000000000000000000000000000000000000002", Token: "USDC", Amount: "0.0000001"},
+		{Line: 4, Recipient: "0x2000000000000000000000000000000000000002", Token: "USDC", Amount: "-1"},
+	}
+	_, err := batching.BuildPayoutPlan(rows, payoutTokens, batching.SplitLimits{})
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+	for _, line := range []string{"line 1", "line 2", "line 3", "line 4"} {
+		if !strings.Contains(err.Error(), line) {
+			t.Fatalf("missing error for %s: %v", line, err)
+		}
+	}
+}
+
+func TestBuildPayoutPlanRejectsOverflowingSums(t *testing.T) {
+	maxUint256 := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+	raw := batching.Token{Symbol: "RAW", Address: common.HexToAddress("0x4000000000000000000000000000000000000004")}
+	tokens := map[string]batching.Token{"RAW": raw}
+
+	tests := []struct {
+		name string
+		rows []batching.PayoutRow
+		want string
+	}{
+		{"duplicate recipient", []batching.PayoutRow{
+			{Line: 1, Recipient: "0x2000000000000000000000000000000000000002", Token: "RAW", Amount: maxUint256.String()},
+			{Line: 2, Recipient: "0x2000000000000000000000000000000000000002", Token: "RAW", Amount: "1"},
+		}, "line 2: merged amount"},
+		{"token total", []batching.PayoutRow{
+			{Line: 1, Recipient: "0x2000000000000000000000000000000000000002", Token: "RAW", Amount: maxUint256.String()},
+			{Line: 2, Recipient: "0x3000000000000000000000000000000000000003", Token: "RAW", Amount: "1"},
+		}, "line 2: token total"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := batching.BuildPayoutPlan(tt.rows, tokens, batching.SplitLimits{})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTokenAmount(t *testing.T) {
+	got, err := batching.ParseTokenAmount("1.25", 6)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if got.Cmp(big.NewInt(1_250_000)) != 0 {
+		t.Fatalf("unexpected amount: %s", got)
+	}
+	for _, bad := range []string{"", ".", "1.", "0", "1e6", "0.0000001"} {
+		if _, err := batching.ParseTokenAmount(bad, 6); err == nil {
+			t.Fatalf("expected error for %q", bad)
+		}
+	}
+}
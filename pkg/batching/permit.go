@@ -0,0 +1,556 @@
+package batching
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// Permit2Address is the canonical Uniswap Permit2 deployment shared by most chains.
+var Permit2Address = common.HexToAddress("0x000000000022D473030F116dDEE9F6B43aC78BA3")
+
+var (
+	eip712DomainTypeHash       = crypto.Keccak256Hash([]byte("EIP712Domain(string name,string version,uint256 chainId,address verifyingContract)"))
+	eip712DomainNoVersionHash  = crypto.Keccak256Hash([]byte("EIP712Domain(string name,uint256 chainId,address verifyingContract)"))
+	permitTypeHash             = crypto.Keccak256Hash([]byte("Permit(address owner,address spender,uint256 value,uint256 nonce,uint256 deadline)"))
+	permitDetailsTypeHash      = crypto.Keccak256Hash([]byte(permitDetailsType))
+	permitSingleTypeHash       = crypto.Keccak256Hash([]byte("PermitSingle(PermitDetails details,address spender,uint256 sigDeadline)" + permitDetailsType))
+	permitBatchTypeHash        = crypto.Keccak256Hash([]byte("PermitBatch(PermitDetails[] details,address spender,uint256 sigDeadline)" + permitDetailsType))
+	tokenPermissionsTypeHash   = crypto.Keccak256Hash([]byte(tokenPermissionsType))
+	permitTransferFromTypeHash = crypto.Keccak256Hash([]byte("PermitTransferFrom(TokenPermissions permitted,address spender,uint256 nonce,uint256 deadline)" + tokenPermissionsType))
+
+	maxUint48  = new(big.Int).SetUint64(1<<48 - 1)
+	maxUint160 = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 160), big.NewInt(1))
+
+	errPermitValueOutOfRange    = errors.New("permit amount, expiration or nonce is out of range")
+	errPermitSignatureMalformed = errors.New("permit signature must be 65 bytes")
+)
+
+const (
+	permitDetailsType    = "PermitDetails(address token,uint160 amount,uint48 expiration,uint48 nonce)"
+	tokenPermissionsType = "TokenPermissions(address token,uint256 amount)"
+)
+
+const permitABIJSON = `[
+  {
+    "type": "function",
+    "name": "permit",
+    "stateMutability": "nonpayable",
+    "inputs": [
+      {"name": "owner", "type": "address"},
+      {"name": "spender", "type": "address"},
+      {"name": "value", "type": "uint256"},
+      {"name": "deadline", "type": "uint256"},
+      {"name": "v", "type": "uint8"},
+      {"name": "r", "type": "bytes32"},
+      {"name": "s", "type": "bytes32"}
+    ],
+    "outputs": []
+  }
+]`
+
+// permit2ABIJSON keeps the PermitSingle overload first so it packs as "permit"
+// and the PermitBatch overload as "permit0".
+const permit2ABIJSON = `[
+  {
+    "type": "function",
+    "name": "permit",
+    "stateMutability": "nonpayable",
+    "inputs": [
+      {"name": "owner", "type": "address"},
+      {"name": "permitSingle", "type": "tuple", "components": [
+        {"name": "details", "type": "tuple", "components": [
+          {"name": "token", "type": "address"},
+          {"name": "amount", "type": "uint160"},
+          {"name": "expiration", "type": "uint48"},
+          {"name": "nonce", "type": "uint48"}
+        ]},
+        {"name": "spender", "type": "address"},
+        {"name": "sigDeadline", "type": "uint256"}
+      ]},
+      {"name": "signature", "type": "bytes"}
+    ],
+    "outputs": []
+  },
+  {
+    "type": "function",
+    "name": "permit",
+    "stateMutability": "nonpayable",
+    "inputs": [
+      {"name": "owner", "type": "address"},
+      {"name": "permitBatch", "type": "tuple", "components": [
+        {"name": "details", "type": "tuple[]", "components": [
+          {"name": "token", "type": "address"},
+          {"name": "amount", "type": "uint160"},
+          {"name": "expiration", "type": "uint48"},
+          {"name": "nonce", "type": "uint48"}
+        ]},
+        {"name": "spender", "type": "address"},
+        {"name": "sigDeadline", "type": "uint256"}
+      ]},
+      {"name": "signature", "type": "bytes"}
+    ],
+    "outputs": []
+  },
+  {
+    "type": "function",
+    "name": "permitTransferFrom",
+    "stateMutability": "nonpayable",
+    "inputs": [
+      {"name": "permit", "type": "tuple", "components": [
+        {"name": "permitted", "type": "tuple", "components": [
+          {"name": "token", "type": "address"},
+          {"name": "amount", "type": "uint256"}
+        ]},
+        {"name": "nonce", "type": "uint256"},
+        {"name": "deadline", "type": "uint256"}
+      ]},
+      {"name": "transferDetails", "type": "tuple", "components": [
+        {"name": "to", "type": "address"},
+        {"name": "requestedAmount", "type": "uint256"}
+      ]},
+      {"name": "owner", "type": "address"},
+      {"name": "signature", "type": "bytes"}
+    ],
+    "outputs": []
+  }
+]`
+
+var (
+	oncePermitABI sync.Once
+	permitABI     abi.ABI
+	permit2ABI    abi.ABI
+	permitABIErr  error
+)
+
+func getPermitABIs() (abi.ABI, abi.ABI, error) {
+	oncePermitABI.Do(func() {
+		permitABI, permitABIErr = abi.JSON(strings.NewReader(permitABIJSON))
+		if permitABIErr != nil {
+			return
+		}
+		permit2ABI, permitABIErr = abi.JSON(strings.NewReader(permit2ABIJSON))
+	})
+	return permitABI, permit2ABI, permitABIErr
+}
+
+// Domain is an EIP-712 domain. An empty Version is omitted, as in Permit2.
+type Domain struct {
+	Name              string
+	Version           string
+	ChainID           *big.Int
+	VerifyingContract common.Address
+}
+
+// Permit2Domain returns the Permit2 domain for chainID.
+func Permit2Domain(chainID *big.Int) Domain {
+	return Domain{Name: "Permit2", ChainID: chainID, VerifyingContract: Permit2Address}
+}
+
+// Separator computes the EIP-712 domain separator.
+func (d Domain) Separator() common.Hash {
+	chainID := d.ChainID
+	if chainID == nil {
+		chainID = new(big.Int)
+	}
+	if d.Version == "" {
+		return hashWords(
+			eip712DomainNoVersionHash[:],
+			crypto.Keccak256([]byte(d.Name)),
+			uintWord(chainID),
+			addressWord(d.VerifyingContract),
+		)
+	}
+	return hashWords(
+		eip712DomainTypeHash[:],
+		crypto.Keccak256([]byte(d.Name)),
+		crypto.Keccak256([]byte(d.Version)),
+		uintWord(chainID),
+		addressWord(d.VerifyingContract),
+	)
+}
+
+func (d Domain) typedData() apitypes.TypedDataDomain {
+	return apitypes.TypedDataDomain{
+		Name:              d.Name,
+		Version:           d.Version,
+		ChainId:           (*math.HexOrDecimal256)(d.ChainID),
+		VerifyingContract: d.VerifyingContract.Hex(),
+	}
+}
+
+func (d Domain) types() []apitypes.Type {
+	out := []apitypes.Type{{Name: "name", Type: "string"}}
+	if d.Version != "" {
+		out = append(out, apitypes.Type{Name: "version", Type: "string"})
+	}
+	return append(out,
+		apitypes.Type{Name: "chainId", Type: "uint256"},
+		apitypes.Type{Name: "verifyingContract", Type: "address"},
+	)
+}
+
+// TypedDataDigest computes keccak256(0x1901 || domainSeparator || structHash).
+func TypedDataDigest(domainSeparator, structHash common.Hash) common.Hash {
+	return crypto.Keccak256Hash([]byte{0x19, 0x01}, domainSeparator[:], structHash[:])
+}
+
+// SignTypedDigest signs an EIP-712 digest and returns r || s || v with v in {27, 28}.
+func SignTypedDigest(privateKey *ecdsa.PrivateKey, digest common.Hash) ([]byte, error) {
+	if privateKey == nil {
+		return nil, errors.New("private key is required")
+	}
+	sig, err := crypto.Sign(digest[:], privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("sign typed data: %w", err)
+	}
+	sig[64] += 27
+	return sig, nil
+}
+
+// Permit is the EIP-2612 Permit message.
+type Permit struct {
+	Owner    common.Address
+	Spender  common.Address
+	Value    *big.Int
+	Nonce    *big.Int
+	Deadline *big.Int
+}
+
+// StructHash returns the EIP-712 struct hash of the permit.
+func (p Permit) StructHash() common.Hash {
+	return hashWords(
+		permitTypeHash[:],
+		addressWord(p.Owner),
+		addressWord(p.Spender),
+		uintWord(p.Value),
+		uintWord(p.Nonce),
+		uintWord(p.Deadline),
+	)
+}
+
+// TypedData returns the eth_signTypedData_v4 payload for the permit.
+func (p Permit) TypedData(domain Domain) apitypes.TypedData {
+	return apitypes.TypedData{
+		Types: apitypes.Types{
+			"EIP712Domain": domain.types(),
+			"Permit": {
+				{Name: "owner", Type: "address"},
+				{Name: "spender", Type: "address"},
+				{Name: "value", Type: "uint256"},
+				{Name: "nonce", Type: "uint256"},
+				{Name: "deadline", Type: "uint256"},
+			},
+		},
+		PrimaryType: "Permit",
+		Domain:      domain.typedData(),
+		Message: apitypes.TypedDataMessage{
+			"owner":    p.Owner.Hex(),
+			"spender":  p.Spender.Hex(),
+			"value":    bigString(p.Value),
+			"nonce":    bigString(p.Nonce),
+			"deadline": bigString(p.Deadline),
+		},
+	}
+}
+
+// SignPermit signs an EIP-2612 permit for the token identified by domainSeparator.
+func SignPermit(privateKey *ecdsa.PrivateKey, domainSeparator common.Hash, p Permit) ([]byte, error) {
+	return SignTypedDigest(privateKey, TypedDataDigest(domainSeparator, p.StructHash()))
+}
+
+// PermitCall encodes token.permit(owner, spender, value, deadline, v, r, s) as a batch call.
+func PermitCall(token common.Address, p Permit, signature []byte) (Call, error) {
+	if len(signature) != 65 {
+		return Call{}, errPermitSignatureMalformed
+	}
+	parsedABI, _, err := getPermitABIs()
+	if err != nil {
+		return Call{}, fmt.Errorf("parse permit ABI: %w", err)
+	}
+	v := signature[64]
+	if v < 27 {
+		v += 27
+	}
+	data, err := parsedABI.Pack("permit", p.Owner, p.Spender, orZero(p.Value), orZero(p.Deadline),
+		v, common.BytesToHash(signature[:32]), common.BytesToHash(signature[32:64]))
+	if err != nil {
+		return Call{}, fmt.Errorf("pack permit calldata: %w", err)
+	}
+	return Call{Target: token, Value: big.NewInt(0), Data: data}, nil
+}
+
+// PermitDetails is the Permit2 allowance granted for one token.
+type PermitDetails struct {
+	Token      common.Address `abi:"token"`
+	Amount     *big.Int       `abi:"amount"`
+	Expiration *big.Int       `abi:"expiration"`
+	Nonce      *big.Int       `abi:"nonce"`
+}
+
+func (d PermitDetails) hash() common.Hash {
+	return hashWords(
+		permitDetailsTypeHash[:],
+		addressWord(d.Token),
+		uintWord(d.Amount),
+		uintWord(d.Expiration),
+		uintWord(d.Nonce),
+	)
+}
+
+func (d PermitDetails) validate() error {
+	if orZero(d.Amount).Cmp(maxUint160) > 0 || orZero(d.Expiration).Cmp(maxUint48) > 0 || orZero(d.Nonce).Cmp(maxUint48) > 0 {
+		return errPermitValueOutOfRange
+	}
+	return nil
+}
+
+func (d PermitDetails) normalized() PermitDetails {
+	return PermitDetails{Token: d.Token, Amount: orZero(d.Amount), Expiration: orZero(d.Expiration), Nonce: orZero(d.Nonce)}
+}
+
+func (d PermitDetails) message() apitypes.TypedDataMessage {
+	return apitypes.TypedDataMessage{
+		"token":      d.Token.Hex(),
+		"amount":     bigString(d.Amount),
+		"expiration": bigString(d.Expiration),
+		"nonce":      bigString(d.Nonce),
+	}
+}
+
+var permitDetailsFields = []apitypes.Type{
+	{Name: "token", Type: "address"},
+	{Name: "amount", Type: "uint160"},
+	{Name: "expiration", Type: "uint48"},
+	{Name: "nonce", Type: "uint48"},
+}
+
+// PermitSingle is the Permit2 AllowanceTransfer message for one token.
+type PermitSingle struct {
+	Details     PermitDetails  `abi:"details"`
+	Spender     common.Address `abi:"spender"`
+	SigDeadline *big.Int       `abi:"sigDeadline"`
+}
+
+// StructHash returns the EIP-712 struct hash of the permit.
+func (p PermitSingle) StructHash() common.Hash {
+	detailsHash := p.Details.hash()
+	return hashWords(permitSingleTypeHash[:], detailsHash[:], addressWord(p.Spender), uintWord(p.SigDeadline))
+}
+
+// TypedData returns the eth_signTypedData_v4 payload for the permit.
+func (p PermitSingle) TypedData(domain Domain) apitypes.TypedData {
+	return apitypes.TypedData{
+		Types: apitypes.Types{
+			"EIP712Domain":  domain.types(),
+			"PermitDetails": permitDetailsFields,
+			"PermitSingle": {
+				{Name: "details", Type: "PermitDetails"},
+				{Name: "spender", Type: "address"},
+				{Name: "sigDeadline", Type: "uint256"},
+			},
+		},
+		PrimaryType: "PermitSingle",
+		Domain:      domain.typedData(),
+		Message: apitypes.TypedDataMessage{
+			"details":     map[string]any(p.Details.message()),
+			"spender":     p.Spender.Hex(),
+			"sigDeadline": bigString(p.SigDeadline),
+		},
+	}
+}
+
+// PermitBatch is the Permit2 AllowanceTransfer message for several tokens.
+type PermitBatch struct {
+	Details     []PermitDetails `abi:"details"`
+	Spender     common.Address  `abi:"spender"`
+	SigDeadline *big.Int        `abi:"sigDeadline"`
+}
+
+// StructHash returns the EIP-712 struct hash of the permit.
+func (p PermitBatch) StructHash() common.Hash {
+	hashes := make([][]byte, len(p.Details))
+	for i, d := range p.Details {
+		h := d.hash()
+		hashes[i] = h[:]
+	}
+	detailsHash := crypto.Keccak256(hashes...)
+	return hashWords(permitBatchTypeHash[:], detailsHash, addressWord(p.Spender), uintWord(p.SigDeadline))
+}
+
+// TypedData returns the eth_signTypedData_v4 payload for the permit.
+func (p PermitBatch) TypedData(domain Domain) apitypes.TypedData {
+	details := make([]any, len(p.Details))
+	for i, d := range p.Details {
+		details[i] = map[string]any(d.message())
+	}
+	return apitypes.TypedData{
+		Types: apitypes.Types{
+			"EIP712Domain":  domain.types(),
+			"PermitDetails": permitDetailsFields,
+			"PermitBatch": {
+				{Name: "details", Type: "PermitDetails[]"},
+				{Name: "spender", Type: "address"},
+				{Name: "sigDeadline", Type: "uint256"},
+			},
+		},
+		PrimaryType: "PermitBatch",
+		Domain:      domain.typedData(),
+		Message: apitypes.TypedDataMessage{
+			"details":     details,
+			"spender":     p.Spender.Hex(),
+			"sigDeadline": bigString(p.SigDeadline),
+		},
+	}
+}
+
+// TokenPermissions is the token and maximum amount of a Permit2 signature transfer.
+type TokenPermissions struct {
+	Token  common.Address `abi:"token"`
+	Amount *big.Int       `abi:"amount"`
+}
+
+// PermitTransferFrom is the Permit2 SignatureTransfer message. Spender is the
+// address that will call permitTransferFrom, e.g. the delegated EOA itself.
+type PermitTransferFrom struct {
+	Permitted TokenPermissions
+	Spender   common.Address
+	Nonce     *big.Int
+	Deadline  *big.Int
+}
+
+// StructHash returns the EIP-712 struct hash of the permit.
+func (p PermitTransferFrom) StructHash() common.Hash {
+	permitted := hashWords(tokenPermissionsTypeHash[:], addressWord(p.Permitted.Token), uintWord(p.Permitted.Amount))
+	return hashWords(permitTransferFromTypeHash[:], permitted[:], addressWord(p.Spender), uintWord(p.Nonce), uintWord(p.Deadline))
+}
+
+// TypedData returns the eth_signTypedData_v4 payload for the permit.
+func (p PermitTransferFrom) TypedData(domain Domain) apitypes.TypedData {
+	return apitypes.TypedData{
+		Types: apitypes.Types{
+			"EIP712Domain": domain.types(),
+			"TokenPermissions": {
+				{Name: "token", Type: "address"},
+				{Name: "amount", Type: "uint256"},
+			},
+			"PermitTransferFrom": {
+				{Name: "permitted", Type: "TokenPermissions"},
+				{Name: "spender", Type: "address"},
+				{Name: "nonce", Type: "uint256"},
+				{Name: "deadline", Type: "uint256"},
+			},
+		},
+		PrimaryType: "PermitTransferFrom",
+		Domain:      domain.typedData(),
+		Message: apitypes.TypedDataMessage{
+			"permitted": map[string]any{
+				"token":  p.Permitted.Token.Hex(),
+				"amount": bigString(p.Permitted.Amount),
+			},
+			"spender":  p.Spender.Hex(),
+			"nonce":    bigString(p.Nonce),
+			"deadline": bigString(p.Deadline),
+		},
+	}
+}
+
+// SignPermit2 signs any Permit2 message against the given domain separator.
+func SignPermit2(privateKey *ecdsa.PrivateKey, domainSeparator common.Hash, msg interface{ StructHash() common.Hash }) ([]byte, error) {
+	return SignTypedDigest(privateKey, TypedDataDigest(domainSeparator, msg.StructHash()))
+}
+
+// Permit2PermitCall encodes permit2.permit(owner, permitSingle, signature) as a batch call.
+func Permit2PermitCall(permit2 common.Address, owner common.Address, p PermitSingle, signature []byte) (Call, error) {
+	if err := p.Details.validate(); err != nil {
+		return Call{}, err
+	}
+	p.Details = p.Details.normalized()
+	p.SigDeadline = orZero(p.SigDeadline)
+	return packPermit2Call(permit2, "permit", owner, p, signature)
+}
+
+// Permit2PermitBatchCall encodes permit2.permit(owner, permitBatch, signature) as a batch call.
+func Permit2PermitBatchCall(permit2 common.Address, owner common.Address, p PermitBatch, signature []byte) (Call, error) {
+	if len(p.Details) == 0 {
+		return Call{}, errors.New("permit batch details must not be empty")
+	}
+	details := make([]PermitDetails, len(p.Details))
+	for i, d := range p.Details {
+		if err := d.validate(); err != nil {
+			return Call{}, fmt.Errorf("details %d: %w", i, err)
+		}
+		details[i] = d.normalized()
+	}
+	p.Details = details
+	p.SigDeadline = orZero(p.SigDeadline)
+	return packPermit2Call(permit2, "permit0", owner, p, signature)
+}
+
+// SignatureTransferDetails is the recipient and amount of a Permit2 signature transfer.
+type SignatureTransferDetails struct {
+	To              common.Address `abi:"to"`
+	RequestedAmount *big.Int       `abi:"requestedAmount"`
+}
+
+// Permit2TransferFromCall encodes permit2.permitTransferFrom(permit, transferDetails, owner, signature).
+func Permit2TransferFromCall(permit2 common.Address, owner common.Address, p PermitTransferFrom, transfer SignatureTransferDetails, signature []byte) (Call, error) {
+	permit := struct {
+		Permitted TokenPermissions `abi:"permitted"`
+		Nonce     *big.Int         `abi:"nonce"`
+		Deadline  *big.Int         `abi:"deadline"`
+	}{
+		Permitted: TokenPermissions{Token: p.Permitted.Token, Amount: orZero(p.Permitted.Amount)},
+		Nonce:     orZero(p.Nonce),
+		Deadline:  orZero(p.Deadline),
+	}
+	transfer.RequestedAmount = orZero(transfer.RequestedAmount)
+	return packPermit2Call(permit2, "permitTransferFrom", permit, transfer, owner, signature)
+}
+
+func packPermit2Call(permit2 common.Address, method string, args ...any) (Call, error) {
+	if sig, ok := args[len(args)-1].([]byte); !ok || len(sig) != 65 {
+		return Call{}, errPermitSignatureMalformed
+	}
+	_, parsedABI, err := getPermitABIs()
+	if err != nil {
+		return Call{}, fmt.Errorf("parse Permit2 ABI: %w", err)
+	}
+	data, err := parsedABI.Pack(method, args...)
+	if err != nil {
+		return Call{}, fmt.Errorf("pack %s calldata: %w", method, err)
+	}
+	return Call{Target: permit2, Value: big.NewInt(0), Data: data}, nil
+}
+
+func hashWords(words ...[]byte) common.Hash {
+	return crypto.Keccak256Hash(words...)
+}
+
+func addressWord(addr common.Address) []byte {
+	return common.LeftPadBytes(addr.Bytes(), 32)
+}
+
+func uintWord(v *big.Int) []byte {
+	return math.U256Bytes(new(big.Int).Set(orZero(v)))
+}
+
+func orZero(v *big.Int) *big.Int {
+	if v == nil {
+		return new(big.Int)
+	}
+	return v
+}
+
+func bigString(v *big.Int) string {
+	return orZero(v).String()
+}
@@ -0,0 +1,134 @@
+package batching_test
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/eipcodelab/eip7702-go/pkg/batching"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+type permitMessage interface {
+	StructHash() common.Hash
+	TypedData(batching.Domain) apitypes.TypedData
+}
+
+func TestPermitDigestsMatchTypedData(t *testing.T) {
+	owner := common.HexToAddress("0x1000000000000000000000000000000000000001")
+	spender := common.HexToAddress("0x2000000000000000000000000000000000000002")
+	token := common.HexToAddress("0xA0b86991c6218b36c1d19d4a2e9eb0ce3606eb48")
+	details := batching.PermitDetails{Token: token, Amount: big.NewInt(1_000_000), Expiration: big.NewInt(1_700_000_000), Nonce: big.NewInt(3)}
+
+	cases := []struct {
+		name   string
+		domain batching.Domain
+		msg    permitMessage
+	}{
+		{
+			name:   "eip2612",
+			domain: batching.Domain{Name: "USD Coin", Version: "2", ChainID: big.NewInt(1), VerifyingContract: token},
+			msg:    batching.Permit{Owner: owner, Spender: spender, Value: big.NewInt(5), Nonce: big.NewInt(0), Deadline: big.NewInt(99)},
+		},
+		{
+			name:   "permitSingle",
+			domain: batching.Permit2Domain(big.NewInt(1)),
+			msg:    batching.PermitSingle{Details: details, Spender: spender, SigDeadline: big.NewInt(99)},
+		},
+		{
+			name:   "permitBatch",
+			domain: batching.Permit2Domain(big.NewInt(10)),
+			msg:    batching.PermitBatch{Details: []batching.PermitDetails{details, details}, Spender: spender, SigDeadline: big.NewInt(99)},
+		},
+		{
+			name:   "permitTransferFrom",
+			domain: batching.Permit2Domain(big.NewInt(1)),
+			msg: batching.PermitTransferFrom{
+				Permitted: batching.TokenPermissions{Token: token, Amount: big.NewInt(7)},
+				Spender:   spender,
+				Nonce:     big.NewInt(42),
+				Deadline:  big.NewInt(99),
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			want, _, err := apitypes.TypedDataAndHash(tc.msg.TypedData(tc.domain))
+			if err != nil {
+				t.Fatalf("typed data hash: %v", err)
+			}
+			got := batching.TypedDataDigest(tc.domain.Separator(), tc.msg.StructHash())
+			if !bytes.Equal(got[:], want) {
+				t.Fatalf("digest mismatch: got %s want %s", got.Hex(), hexutil.Encode(want))
+			}
+		})
+	}
+}
+
+func TestSignPermitAndEncodeCalls(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("key: %v", err)
+	}
+	owner := crypto.PubkeyToAddress(key.PublicKey)
+	token := common.HexToAddress("0xA0b86991c6218b36c1d19d4a2e9eb0ce3606eb48")
+	spender := common.HexToAddress("0x2000000000000000000000000000000000000002")
+	separator := batching.Domain{Name: "Token", Version: "1", ChainID: big.NewInt(1), VerifyingContract: token}.Separator()
+
+	permit := batching.Permit{Owner: owner, Spender: spender, Value: big.NewInt(5), Nonce: big.NewInt(0), Deadline: big.NewInt(99)}
+	sig, err := batching.SignPermit(key, separator, permit)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	if sig[64] != 27 && sig[64] != 28 {
+		t.Fatalf("unexpected v: %d", sig[64])
+	}
+	recoverSig := append([]byte{}, sig...)
+	recoverSig[64] -= 27
+	digest := batching.TypedDataDigest(separator, permit.StructHash())
+	pub, err := crypto.SigToPub(digest[:], recoverSig)
+	if err != nil || crypto.PubkeyToAddress(*pub) != owner {
+		t.Fatalf("signature does not recover owner: %v", err)
+	}
+
+	call, err := batching.PermitCall(token, permit, sig)
+	if err != nil {
+		t.Fatalf("permit call: %v", err)
+	}
+	assertSelector(t, call.Data, "0xd505accf")
+
+	details := batching.PermitDetails{Token: token, Amount: big.NewInt(1), Expiration: big.NewInt(2), Nonce: big.NewInt(0)}
+	call, err = batching.Permit2PermitCall(batching.Permit2Address, owner, batching.PermitSingle{Details: details, Spender: spender}, sig)
+	if err != nil {
+		t.Fatalf("permit2 single call: %v", err)
+	}
+	assertSelector(t, call.Data, "0x2b67b570")
+
+	call, err = batching.Permit2PermitBatchCall(batching.Permit2Address, owner, batching.PermitBatch{Details: []batching.PermitDetails{details}, Spender: spender}, sig)
+	if err != nil {
+		t.Fatalf("permit2 batch call: %v", err)
+	}
+	assertSelector(t, call.Data, "0x2a2d80d1")
+
+	transfer := batching.PermitTransferFrom{Permitted: batching.TokenPermissions{Token: token, Amount: big.NewInt(1)}, Spender: owner}
+	call, err = batching.Permit2TransferFromCall(batching.Permit2Address, owner, transfer, batching.SignatureTransferDetails{To: spender, RequestedAmount: big.NewInt(1)}, sig)
+	if err != nil {
+		t.Fatalf("permit2 transfer call: %v", err)
+	}
+	assertSelector(t, call.Data, "0x30f28b7a")
+
+	details.Expiration = new(big.Int).Lsh(big.NewInt(1), 48)
+	if _, err := batching.Permit2PermitCall(batching.Permit2Address, owner, batching.PermitSingle{Details: details, Spender: spender}, sig); err == nil {
+		t.Fatal("expected uint48 overflow error")
+	}
+}
+
+func assertSelector(t *testing.T, data []byte, want string) {
+	t.Helper()
+	if len(data) < 4 || hexutil.Encode(data[:4]) != want {
+		t.Fatalf("unexpected selector: got %x want %s", data[:4], want)
+	}
+}
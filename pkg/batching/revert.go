@@ -0,0 +1,226 @@
+package batching
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// RevertKind classifies decoded revert data.
+type RevertKind int
+
+const (
+	// RevertEmpty is a revert without return data, e.g. require(false) or out of gas.
+	RevertEmpty RevertKind = iota
+	// RevertError is Error(string).
+	RevertError
+	// RevertPanic is Panic(uint256).
+	RevertPanic
+	// RevertCustom is a custom error found in a registered ABI.
+	RevertCustom
+	// RevertUnknown is return data with an unrecognized selector.
+	RevertUnknown
+)
+
+// executorErrorsABIJSON lists executor errors that bubble up the failing call index and its revert data.
+const executorErrorsABIJSON = `[
+  {"type": "error", "name": "CallFailed", "inputs": [{"name": "index", "type": "uint256"}, {"name": "reason", "type": "bytes"}]},
+  {"type": "error", "name": "CallReverted", "inputs": [{"name": "index", "type": "uint256"}, {"name": "reason", "type": "bytes"}]},
+  {"type": "error", "name": "ExecutionFailed", "inputs": [{"name": "index", "type": "uint256"}, {"name": "reason", "type": "bytes"}]},
+  {"type": "error", "name": "BatchCallFailed", "inputs": [{"name": "index", "type": "uint256"}, {"name": "reason", "type": "bytes"}]}
+]`
+
+var (
+	errorStringSelector = crypto.Keccak256([]byte("Error(string)"))[:4]
+	panicSelector       = crypto.Keccak256([]byte("Panic(uint256)"))[:4]
+
+	stringType, _  = abi.NewType("string", "", nil)
+	uint256Type, _ = abi.NewType("uint256", "", nil)
+
+	defaultRevertDecoder = NewRevertDecoder()
+)
+
+// PanicReasons names the Solidity Panic(uint256) codes.
+var PanicReasons = map[uint64]string{
+	0x00: "generic compiler panic",
+	0x01: "assertion failed",
+	0x11: "arithmetic underflow or overflow",
+	0x12: "division or modulo by zero",
+	0x21: "invalid enum conversion",
+	0x22: "invalid storage byte array encoding",
+	0x31: "pop on empty array",
+	0x32: "array index out of bounds",
+	0x41: "out of memory",
+	0x51: "call to zero-initialized function",
+}
+
+// Revert is a structured view of revert data returned by a batch execution.
+type Revert struct {
+	Kind RevertKind
+	Data []byte
+
+	// Message is set for Error(string).
+	Message string
+	// PanicCode and PanicName are set for Panic(uint256).
+	PanicCode *big.Int
+	PanicName string
+	// ErrorName and Args are set for custom errors.
+	ErrorName string
+	Args      []any
+
+	// CallIndex is the failing call reported by the executor, or -1 when unknown.
+	CallIndex int
+	// Call is the failing call when CallIndex is known and within the batch.
+	Call *Call
+	// Inner is the failing call's own revert data.
+	Inner *Revert
+}
+
+// Error renders the revert reason, following Inner down to the root cause.
+func (r *Revert) Error() string {
+	var reason string
+	switch r.Kind {
+	case RevertEmpty:
+		reason = "execution reverted"
+	case RevertError:
+		reason = fmt.Sprintf("execution reverted: %s", r.Message)
+	case RevertPanic:
+		reason = fmt.Sprintf("panic 0x%x (%s)", r.PanicCode, r.PanicName)
+	case RevertCustom:
+		args := make([]string, len(r.Args))
+		for i, arg := range r.Args {
+			args[i] = fmt.Sprintf("%v", arg)
+		}
+		reason = fmt.Sprintf("%s(%s)", r.ErrorName, strings.Join(args, ", "))
+	default:
+		reason = fmt.Sprintf("unknown revert 0x%x", r.Data)
+	}
+	if r.CallIndex < 0 {
+		return reason
+	}
+	if r.Inner == nil {
+		return fmt.Sprintf("call %d failed: %s", r.CallIndex, reason)
+	}
+	return fmt.Sprintf("call %d failed: %s", r.CallIndex, r.Inner.Error())
+}
+
+// RevertDecoder decodes revert data against built-in and registered errors.
+// It is safe for concurrent use.
+type RevertDecoder struct {
+	mu     sync.RWMutex
+	errors map[[4]byte]abi.Error
+}
+
+// NewRevertDecoder returns a decoder that knows the built-in executor errors.
+func NewRevertDecoder() *RevertDecoder {
+	d := &RevertDecoder{errors: make(map[[4]byte]abi.Error)}
+	if err := d.RegisterABI(executorErrorsABIJSON); err != nil {
+		panic(fmt.Sprintf("parse executor errors ABI: %v", err))
+	}
+	return d
+}
+
+// RegisterABI adds every error definition in abiJSON to the decoder.
+func (d *RevertDecoder) RegisterABI(abiJSON string) error {
+	parsedABI, err := abi.JSON(strings.NewReader(abiJSON))
+	if err != nil {
+		return fmt.Errorf("parse ABI: %w", err)
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, e := range parsedABI.Errors {
+		var id [4]byte
+		copy(id[:], e.ID[:4])
+		d.errors[id] = e
+	}
+	return nil
+}
+
+// DecodeRevert decodes data with the default decoder. See RevertDecoder.Decode.
+func DecodeRevert(data []byte, calls []Call) *Revert {
+	return defaultRevertDecoder.Decode(data, calls)
+}
+
+// Decode turns revert data into a Revert. calls is the batch that was executed
+// and is used to resolve the failing call; it may be nil.
+// A custom error whose arguments are exactly (uint256, bytes) is treated as an
+// executor error carrying the failing call index and its inner revert data.
+func (d *RevertDecoder) Decode(data []byte, calls []Call) *Revert {
+	r := &Revert{Data: data, CallIndex: -1}
+	if len(data) == 0 {
+		r.Kind = RevertEmpty
+		return r
+	}
+	if len(data) < 4 {
+		r.Kind = RevertUnknown
+		return r
+	}
+	var id [4]byte
+	copy(id[:], data[:4])
+
+	switch {
+	case id == [4]byte(errorStringSelector):
+		out, err := abi.Arguments{{Type: stringType}}.Unpack(data[4:])
+		if err != nil || len(out) != 1 {
+			r.Kind = RevertUnknown
+			return r
+		}
+		r.Kind = RevertError
+		r.Message = out[0].(string)
+		return r
+	case id == [4]byte(panicSelector):
+		out, err := abi.Arguments{{Type: uint256Type}}.Unpack(data[4:])
+		if err != nil || len(out) != 1 {
+			r.Kind = RevertUnknown
+			return r
+		}
+		r.Kind = RevertPanic
+		r.PanicCode = out[0].(*big.Int)
+		r.PanicName = "unknown panic code"
+		if r.PanicCode.IsUint64() {
+			if name, ok := PanicReasons[r.PanicCode.Uint64()]; ok {
+				r.PanicName = name
+			}
+		}
+		return r
+	}
+
+	d.mu.RLock()
+	errDef, ok := d.errors[id]
+	d.mu.RUnlock()
+	if !ok {
+		r.Kind = RevertUnknown
+		return r
+	}
+	args, err := errDef.Inputs.Unpack(data[4:])
+	if err != nil {
+		r.Kind = RevertUnknown
+		return r
+	}
+	r.Kind = RevertCustom
+	r.ErrorName = errDef.Name
+	r.Args = args
+
+	if isExecutorError(errDef) {
+		index := args[0].(*big.Int)
+		if index.IsInt64() && index.Int64() <= int64(^uint(0)>>1) {
+			r.CallIndex = int(index.Int64())
+		}
+		if r.CallIndex >= 0 && r.CallIndex < len(calls) {
+			call := calls[r.CallIndex]
+			r.Call = &call
+		}
+		r.Inner = d.Decode(args[1].([]byte), nil)
+	}
+	return r
+}
+
+func isExecutorError(e abi.Error) bool {
+	return len(e.Inputs) == 2 &&
+		e.Inputs[0].Type.T == abi.UintTy && e.Inputs[0].Type.Size == 256 &&
+		e.Inputs[1].Type.T == abi.BytesTy
+}
@@ -0,0 +1,85 @@
+package batching_test
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/eipcodelab/eip7702-go/pkg/batching"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const revertTestABI = `[
+  {"type": "error", "name": "Error", "inputs": [{"name": "message", "type": "string"}]},
+  {"type": "error", "name": "Panic", "inputs": [{"name": "code", "type": "uint256"}]},
+  {"type": "error", "name": "CallFailed", "inputs": [{"name": "index", "type": "uint256"}, {"name": "reason", "type": "bytes"}]},
+  {"type": "error", "name": "InsufficientBalance", "inputs": [{"name": "available", "type": "uint256"}, {"name": "required", "type": "uint256"}]}
+]`
+
+func packError(t *testing.T, name string, args ...any) []byte {
+	t.Helper()
+	parsed, err := abi.JSON(strings.NewReader(revertTestABI))
+	if err != nil {
+		t.Fatalf("parse abi: %v", err)
+	}
+	e := parsed.Errors[name]
+	data, err := e.Inputs.Pack(args...)
+	if err != nil {
+		t.Fatalf("pack %s: %v", name, err)
+	}
+	return append(e.ID[:4:4], data...)
+}
+
+func TestDecodeRevertErrorAndPanic(t *testing.T) {
+	r := batching.DecodeRevert(packError(t, "Error", "insufficient allowance"), nil)
+	if r.Kind != batching.RevertError || r.Message != "insufficient allowance" {
+		t.Fatalf("unexpected error decode: %+v", r)
+	}
+
+	r = batching.DecodeRevert(packError(t, "Panic", big.NewInt(0x11)), nil)
+	if r.Kind != batching.RevertPanic || r.PanicName != "arithmetic underflow or overflow" {
+		t.Fatalf("unexpected panic decode: %+v", r)
+	}
+
+	if r := batching.DecodeRevert(nil, nil); r.Kind != batching.RevertEmpty {
+		t.Fatalf("expected empty revert, got %v", r.Kind)
+	}
+	if r := batching.DecodeRevert([]byte{1, 2, 3, 4, 5}, nil); r.Kind != batching.RevertUnknown {
+		t.Fatalf("expected unknown revert, got %v", r.Kind)
+	}
+}
+
+func TestDecodeRevertCustomErrorFromRegisteredABI(t *testing.T) {
+	data := packError(t, "InsufficientBalance", big.NewInt(1), big.NewInt(2))
+	if r := batching.DecodeRevert(data, nil); r.Kind != batching.RevertUnknown {
+		t.Fatal("unregistered custom error must stay unknown")
+	}
+
+	decoder := batching.NewRevertDecoder()
+	if err := decoder.RegisterABI(revertTestABI); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	r := decoder.Decode(data, nil)
+	if r.Kind != batching.RevertCustom || r.ErrorName != "InsufficientBalance" || len(r.Args) != 2 {
+		t.Fatalf("unexpected custom decode: %+v", r)
+	}
+}
+
+func TestDecodeRevertExecutorCallIndex(t *testing.T) {
+	calls := []batching.Call{
+		{Target: common.HexToAddress("0x01")},
+		{Target: common.HexToAddress("0x02")},
+	}
+	inner := packError(t, "Error", "transfer failed")
+	r := batching.DecodeRevert(packError(t, "CallFailed", big.NewInt(1), inner), calls)
+	if r.CallIndex != 1 || r.Call == nil || r.Call.Target != calls[1].Target {
+		t.Fatalf("failing call not resolved: %+v", r)
+	}
+	if r.Inner == nil || r.Inner.Message != "transfer failed" {
+		t.Fatalf("inner revert not decoded: %+v", r.Inner)
+	}
+	if got := r.Error(); got != "call 1 failed: execution reverted: transfer failed" {
+		t.Fatalf("unexpected error string: %s", got)
+	}
+}
@@ -0,0 +1,199 @@
+package batching
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	// DefaultCallGas is the execution allowance assumed for a call without an estimate.
+	DefaultCallGas uint64 = 35_000
+	// CallValueGas is the extra gas charged when a call transfers value.
+	CallValueGas uint64 = 9_000
+
+	calldataZeroByteGas    uint64 = 4
+	calldataNonZeroByteGas uint64 = 16
+)
+
+// SplitLimits bounds one executeBatch payload. Zero values disable a limit.
+type SplitLimits struct {
+	// MaxGas caps BaseGas plus the summed gas of the calls in one chunk.
+	MaxGas uint64
+	// BaseGas is the fixed per-transaction overhead counted against MaxGas.
+	BaseGas uint64
+	// MaxCalldataBytes caps the encoded executeBatch calldata length.
+	MaxCalldataBytes int
+	// MaxCalls caps the number of calls in one chunk.
+	MaxCalls int
+}
+
+// BatchItem is one call plus its gas estimate and optional atomic group.
+type BatchItem struct {
+	Call
+	// Gas is the estimated gas for this call. Zero uses EstimateCallGas.
+	Gas uint64
+	// Group keeps consecutive items with the same non-empty value in one chunk.
+	Group string
+}
+
+// Chunk is one executeBatch payload produced by the splitter.
+type Chunk struct {
+	Calls    []Call
+	Gas      uint64
+	Calldata []byte
+}
+
+// EstimateCallGas is the heuristic used for items without an explicit estimate.
+func EstimateCallGas(c Call) uint64 {
+	gas := DefaultCallGas
+	if c.Value != nil && c.Value.Sign() > 0 {
+		gas += CallValueGas
+	}
+	for _, b := range c.Data {
+		if b == 0 {
+			gas += calldataZeroByteGas
+		} else {
+			gas += calldataNonZeroByteGas
+		}
+	}
+	return gas
+}
+
+// ExecuteBatchSize returns the executeBatch calldata length for calls without encoding it.
+func ExecuteBatchSize(calls []Call) int {
+	// selector + offset + array length
+	size := 4 + 32 + 32
+	for _, c := range calls {
+		size += encodedCallSize(c)
+	}
+	return size
+}
+
+// encodedCallSize is the tuple offset word, three head words, data length and padded data.
+func encodedCallSize(c Call) int {
+	return 32 + 3*32 + 32 + (len(c.Data)+31)/32*32
+}
+
+// SplitCalls splits calls into chunks using EstimateCallGas for every call.
+func SplitCalls(calls []Call, limits SplitLimits) ([]Chunk, error) {
+	items := make([]BatchItem, len(calls))
+	for i, c := range calls {
+		items[i] = BatchItem{Call: c}
+	}
+	return SplitBatch(items, limits)
+}
+
+// SplitBatch packs items into ordered executeBatch chunks that respect limits.
+// Items sharing a group must be contiguous and are never split across chunks.
+func SplitBatch(items []BatchItem, limits SplitLimits) ([]Chunk, error) {
+	if len(items) == 0 {
+		return nil, errors.New("items must not be empty")
+	}
+	units, err := groupItems(items)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		chunks  []Chunk
+		current []Call
+		gas     uint64
+		size    int
+	)
+	flush := func() error {
+		if len(current) == 0 {
+			return nil
+		}
+		calldata, err := EncodeExecuteBatch(current)
+		if err != nil {
+			return err
+		}
+		chunks = append(chunks, Chunk{Calls: current, Gas: gas, Calldata: calldata})
+		current, gas, size = nil, 0, 0
+		return nil
+	}
+
+	for _, u := range units {
+		if !limits.fits(len(u.calls), u.gas, ExecuteBatchSize(u.calls)) {
+			return nil, fmt.Errorf("item %d: %s exceeds split limits on its own", u.start, u.describe())
+		}
+		if len(current) > 0 && !limits.fits(len(current)+len(u.calls), gas+u.gas, size+u.size) {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+		if len(current) == 0 {
+			size = ExecuteBatchSize(nil)
+		}
+		current = append(current, u.calls...)
+		gas += u.gas
+		size += u.size
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return chunks, nil
+}
+
+func (l SplitLimits) fits(calls int, gas uint64, size int) bool {
+	if l.MaxCalls > 0 && calls > l.MaxCalls {
+		return false
+	}
+	if l.MaxGas > 0 && l.BaseGas+gas > l.MaxGas {
+		return false
+	}
+	if l.MaxCalldataBytes > 0 && size > l.MaxCalldataBytes {
+		return false
+	}
+	return true
+}
+
+// splitUnit is one call or one atomic group that the splitter places as a whole.
+type splitUnit struct {
+	start int
+	group string
+	calls []Call
+	gas   uint64
+	size  int
+}
+
+func (u splitUnit) describe() string {
+	if u.group == "" {
+		return "call"
+	}
+	return fmt.Sprintf("atomic group %q", u.group)
+}
+
+func groupItems(items []BatchItem) ([]splitUnit, error) {
+	var units []splitUnit
+	closed := make(map[string]bool)
+	for i, item := range items {
+		gas := item.Gas
+		if gas == 0 {
+			gas = EstimateCallGas(item.Call)
+		}
+		size := encodedCallSize(item.Call)
+
+		if item.Group != "" && len(units) > 0 && units[len(units)-1].group == item.Group {
+			last := &units[len(units)-1]
+			last.calls = append(last.calls, item.Call)
+			last.gas += gas
+			last.size += size
+			continue
+		}
+		if item.Group != "" {
+			if closed[item.Group] {
+				return nil, fmt.Errorf("item %d: atomic group %q is not contiguous", i, item.Group)
+			}
+			closed[item.Group] = true
+		}
+		units = append(units, splitUnit{
+			start: i,
+			group: item.Group,
+			calls: []Call{item.Call},
+			gas:   gas,
+			size:  size,
+		})
+	}
+	return units, nil
+}
@@ -0,0 +1,110 @@
+package batching_test
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/eipcodelab/eip7702-go/pkg/batching"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func makeCalls(n int) []batching.Call {
+	calls := make([]batching.Call, n)
+	for i := range calls {
+		calls[i] = batching.Call{
+			Target: common.BigToAddress(big.NewInt(int64(i + 1))),
+			Value:  big.NewInt(0),
+			Data:   []byte{byte(i), 0xaa, 0xbb},
+		}
+	}
+	return calls
+}
+
+func TestExecuteBatchSizeMatchesEncoding(t *testing.T) {
+	calls := makeCalls(3)
+	calls[1].Data = make([]byte, 70)
+	calldata, err := batching.EncodeExecuteBatch(calls)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	if got := batching.ExecuteBatchSize(calls); got != len(calldata) {
+		t.Fatalf("unexpected size: got %d want %d", got, len(calldata))
+	}
+}
+
+func TestSplitCallsPreservesOrder(t *testing.T) {
+	calls := makeCalls(7)
+	chunks, err := batching.SplitCalls(calls, batching.SplitLimits{MaxCalls: 3})
+	if err != nil {
+		t.Fatalf("split: %v", err)
+	}
+	if len(chunks) != 3 {
+		t.Fatalf("unexpected chunk count: %d", len(chunks))
+	}
+	var i int
+	for _, chunk := range chunks {
+		if len(chunk.Calldata) != batching.ExecuteBatchSize(chunk.Calls) {
+			t.Fatal("chunk calldata does not match its calls")
+		}
+		for _, c := range chunk.Calls {
+			if c.Target != calls[i].Target {
+				t.Fatalf("call %d out of order", i)
+			}
+			i++
+		}
+	}
+}
+
+func TestSplitBatchRespectsGasAndCalldata(t *testing.T) {
+	calls := makeCalls(4)
+	items := make([]batching.BatchItem, len(calls))
+	for i, c := range calls {
+		items[i] = batching.BatchItem{Call: c, Gas: 40_000}
+	}
+	chunks, err := batching.SplitBatch(items, batching.SplitLimits{MaxGas: 101_000, BaseGas: 21_000})
+	if err != nil {
+		t.Fatalf("split: %v", err)
+	}
+	if len(chunks) != 2 || chunks[0].Gas != 80_000 {
+		t.Fatalf("unexpected gas split: %d chunks", len(chunks))
+	}
+
+	limit := batching.ExecuteBatchSize(calls[:2])
+	chunks, err = batching.SplitCalls(calls, batching.SplitLimits{MaxCalldataBytes: limit})
+	if err != nil {
+		t.Fatalf("split: %v", err)
+	}
+	for _, chunk := range chunks {
+		if len(chunk.Calldata) > limit {
+			t.Fatalf("chunk calldata %d exceeds limit %d", len(chunk.Calldata), limit)
+		}
+	}
+}
+
+func TestSplitBatchKeepsAtomicGroups(t *testing.T) {
+	calls := makeCalls(5)
+	items := []batching.BatchItem{
+		{Call: calls[0]},
+		{Call: calls[1], Group: "swap"},
+		{Call: calls[2], Group: "swap"},
+		{Call: calls[3], Group: "swap"},
+		{Call: calls[4]},
+	}
+	chunks, err := batching.SplitBatch(items, batching.SplitLimits{MaxCalls: 3})
+	if err != nil {
+		t.Fatalf("split: %v", err)
+	}
+	if len(chunks) != 3 || len(chunks[1].Calls) != 3 {
+		t.Fatalf("atomic group was split: %d chunks", len(chunks))
+	}
+
+	if _, err := batching.SplitBatch(items, batching.SplitLimits{MaxCalls: 2}); err == nil {
+		t.Fatal("expected error for oversized atomic group")
+	}
+
+	items[4].Group = "swap"
+	items[3].Group = ""
+	if _, err := batching.SplitBatch(items, batching.SplitLimits{}); err == nil {
+		t.Fatal("expected error for non-contiguous group")
+	}
+}
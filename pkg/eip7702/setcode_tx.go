@@ -1,8 +1,13 @@
 package eip7702
 
 import (
+	"crypto/ecdsa"
+	"errors"
 	"fmt"
+	"math/big"
 
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/rlp"
 )
 
@@ -43,3 +48,75 @@ func (tx *SetCodeTx) EncodeTypedTransaction() ([]byte, error) {
 	copy(out[1:], payload)
 	return out, nil
 }
+
+// SigningHash returns keccak(0x04 || rlp([chain_id, ..., access_list, authorization_list])),
+// the digest the transaction sender signs.
+func (tx *SetCodeTx) SigningHash() (common.Hash, error) {
+	if err := tx.validateUnsigned(); err != nil {
+		return common.Hash{}, err
+	}
+	enc, err := rlp.EncodeToBytes([]any{
+		tx.ChainID,
+		tx.Nonce,
+		tx.MaxPriorityFeePerGas,
+		tx.MaxFeePerGas,
+		tx.GasLimit,
+		tx.Destination,
+		tx.Value,
+		tx.Data,
+		tx.AccessList,
+		tx.AuthorizationList,
+	})
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("encode set-code signing payload: %w", err)
+	}
+	return crypto.Keccak256Hash([]byte{SetCodeTxType}, enc), nil
+}
+
+// Sign signs the transaction with privateKey and sets the signature fields.
+func (tx *SetCodeTx) Sign(privateKey *ecdsa.PrivateKey) error {
+	if privateKey == nil {
+		return errors.New("private key is required")
+	}
+	hash, err := tx.SigningHash()
+	if err != nil {
+		return err
+	}
+	sig, err := crypto.Sign(hash[:], privateKey)
+	if err != nil {
+		return fmt.Errorf("sign set-code tx: %w", err)
+	}
+	tx.SignatureR = new(big.Int).SetBytes(sig[:32])
+	tx.SignatureS = new(big.Int).SetBytes(sig[32:64])
+	tx.SignatureYParity = sig[64]
+	return nil
+}
+
+// Sender recovers the address that signed the transaction.
+func (tx *SetCodeTx) Sender() (common.Address, error) {
+	if err := tx.ValidateBasic(); err != nil {
+		return common.Address{}, err
+	}
+	hash, err := tx.SigningHash()
+	if err != nil {
+		return common.Address{}, err
+	}
+	sig := make([]byte, crypto.SignatureLength)
+	tx.SignatureR.FillBytes(sig[:32])
+	tx.SignatureS.FillBytes(sig[32:64])
+	sig[64] = tx.SignatureYParity
+	pub, err := crypto.SigToPub(hash[:], sig)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("recover sender: %w", err)
+	}
+	return crypto.PubkeyToAddress(*pub), nil
+}
+
+// Hash returns the transaction hash, keccak of the typed encoding.
+func (tx *SetCodeTx) Hash() (common.Hash, error) {
+	raw, err := tx.EncodeTypedTransaction()
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return crypto.Keccak256Hash(raw), nil
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/eipcodelab/eip7702-go/pkg/eip7702"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
 )
 
 func TestSetCodeTxEncoding(t *testing.T) {
@@ -45,3 +46,51 @@ func TestSetCodeTxEncoding(t *testing.T) {
 		t.Fatalf("unexpected tx type: got 0x%x", raw[0])
 	}
 }
+
+func TestSetCodeTxSign(t *testing.T) {
+	authorityKey, _ := crypto.GenerateKey()
+	auth, err := eip7702.SignAuthorization(authorityKey, big.NewInt(1), common.HexToAddress("0x2000000000000000000000000000000000000002"), 0)
+	if err != nil {
+		t.Fatalf("sign authorization: %v", err)
+	}
+	senderKey, _ := crypto.GenerateKey()
+	tx := &eip7702.SetCodeTx{
+		ChainID:              big.NewInt(1),
+		Nonce:                3,
+		MaxPriorityFeePerGas: big.NewInt(2_000_000_000),
+		MaxFeePerGas:         big.NewInt(40_000_000_000),
+		GasLimit:             250_000,
+		Destination:          common.HexToAddress("0x3000000000000000000000000000000000000003"),
+		Value:                big.NewInt(0),
+		Data:                 []byte{0xde, 0xad, 0xbe, 0xef},
+		AuthorizationList:    []eip7702.Authorization{auth},
+	}
+	if _, err := tx.EncodeTypedTransaction(); err == nil {
+		t.Fatal("unsigned transaction must not encode")
+	}
+	if err := tx.Sign(senderKey); err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	sender, err := tx.Sender()
+	if err != nil || sender != crypto.PubkeyToAddress(senderKey.PublicKey) {
+		t.Fatalf("Sender() = %s, %v", sender.Hex(), err)
+	}
+
+	// The signing payload is the signed payload without its last three fields.
+	raw, err := tx.EncodeTypedTransaction()
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	var fields []rlp.RawValue
+	if err := rlp.DecodeBytes(raw[1:], &fields); err != nil || len(fields) != 13 {
+		t.Fatalf("decode payload: %d fields, %v", len(fields), err)
+	}
+	unsigned, _ := rlp.EncodeToBytes(fields[:10])
+	signingHash, err := tx.SigningHash()
+	if err != nil || signingHash != crypto.Keccak256Hash([]byte{eip7702.SetCodeTxType}, unsigned) {
+		t.Fatalf("SigningHash() = %s, %v", signingHash.Hex(), err)
+	}
+	if hash, err := tx.Hash(); err != nil || hash != crypto.Keccak256Hash(raw) {
+		t.Fatalf("Hash() = %s, %v", hash.Hex(), err)
+	}
+}
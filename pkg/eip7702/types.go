@@ -87,6 +87,26 @@ type SetCodeTx struct {
 
 // ValidateBasic validates required set-code fields before encoding/signing.
 func (tx *SetCodeTx) ValidateBasic() error {
+	if err := tx.validateUnsigned(); err != nil {
+		return err
+	}
+	if tx.SignatureYParity > 1 {
+		return ErrInvalidYParity
+	}
+	if tx.SignatureR == nil || tx.SignatureS == nil {
+		return ErrNilSignatureValue
+	}
+	if tx.SignatureR.Sign() <= 0 || tx.SignatureS.Sign() <= 0 {
+		return ErrInvalidSignature
+	}
+	if tx.SignatureR.BitLen() > 256 || tx.SignatureS.BitLen() > 256 {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// validateUnsigned checks every field except the transaction signature.
+func (tx *SetCodeTx) validateUnsigned() error {
 	if tx.ChainID == nil {
 		return ErrNilChainID
 	}
@@ -107,18 +127,6 @@ func (tx *SetCodeTx) ValidateBasic() error {
 	if tx.MaxPriorityFeePerGas == nil || tx.MaxFeePerGas == nil || tx.Value == nil {
 		return errors.New("maxPriorityFeePerGas, maxFeePerGas and value are required")
 	}
-	if tx.SignatureYParity > 1 {
-		return ErrInvalidYParity
-	}
-	if tx.SignatureR == nil || tx.SignatureS == nil {
-		return ErrNilSignatureValue
-	}
-	if tx.SignatureR.Sign() <= 0 || tx.SignatureS.Sign() <= 0 {
-		return ErrInvalidSignature
-	}
-	if tx.SignatureR.BitLen() > 256 || tx.SignatureS.BitLen() > 256 {
-		return ErrInvalidSignature
-	}
 	return nil
 }
 
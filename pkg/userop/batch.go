@@ -0,0 +1,116 @@
+package userop
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// BatchElem is one call of a JSON-RPC batch. BatchCall fills Result and Error.
+type BatchElem struct {
+	Method string
+	Params []any
+	// Result receives the decoded result; nil discards it.
+	Result any
+	// Error is the per-call error, such as an *RPCError.
+	Error error
+}
+
+// BatchCall sends elems as JSON-RPC batch arrays of at most MaxBatchSize calls.
+// Responses are matched by ID in any order and per-call failures are stored in
+// BatchElem.Error. The returned error reports a failed request; elements of
+// batches sent before it keep their results.
+func (c *BundlerClient) BatchCall(ctx context.Context, elems []BatchElem) error {
+	for start := 0; start < len(elems); start += c.maxBatchSize {
+		end := min(start+c.maxBatchSize, len(elems))
+		if err := c.batchCall(ctx, elems[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *BundlerClient) batchCall(ctx context.Context, elems []BatchElem) error {
+	requests := make([]rpcRequest, len(elems))
+	index := make(map[string]int, len(elems))
+	for i, elem := range elems {
+		params := elem.Params
+		if params == nil {
+			params = []any{}
+		}
+		id := c.nextID.Add(1)
+		requests[i] = rpcRequest{JSONRPC: "2.0", ID: id, Method: elem.Method, Params: params}
+		index[strconv.FormatUint(id, 10)] = i
+	}
+	payload, err := json.Marshal(requests)
+	if err != nil {
+		return fmt.Errorf("marshal rpc batch: %w", err)
+	}
+	body, err := c.post(ctx, payload)
+	if err != nil {
+		return err
+	}
+
+	var responses []rpcResponse
+	if err := json.Unmarshal(body, &responses); err != nil {
+		// Endpoints without batch support answer with a single error object.
+		var single rpcResponse
+		if json.Unmarshal(body, &single) == nil && single.Error != nil {
+			return single.Error
+		}
+		return fmt.Errorf("%w: %v: body %q", ErrInvalidResponse, err, truncateBody(body))
+	}
+
+	answered := make([]bool, len(elems))
+	for _, resp := range responses {
+		i, ok := index[responseID(resp.ID)]
+		if !ok || answered[i] {
+			continue
+		}
+		answered[i] = true
+		elems[i].Error = decodeResult(resp, elems[i].Result)
+	}
+	for i := range elems {
+		if !answered[i] {
+			elems[i].Error = fmt.Errorf("%w: no response for request id %d", ErrInvalidResponse, requests[i].ID)
+		}
+	}
+	return nil
+}
+
+// UserOperationReceiptResult is one entry of GetUserOperationReceipts.
+// Err is ErrNotFound for pending or unknown operations.
+type UserOperationReceiptResult struct {
+	Hash    common.Hash
+	Receipt *UserOperationReceipt
+	Err     error
+}
+
+// GetUserOperationReceipts batches eth_getUserOperationReceipt for hashes and
+// returns one result per hash in the same order.
+func (c *BundlerClient) GetUserOperationReceipts(ctx context.Context, hashes []common.Hash) ([]UserOperationReceiptResult, error) {
+	receipts := make([]*UserOperationReceipt, len(hashes))
+	elems := make([]BatchElem, len(hashes))
+	for i, hash := range hashes {
+		elems[i] = BatchElem{Method: "eth_getUserOperationReceipt", Params: []any{hash}, Result: &receipts[i]}
+	}
+	if err := c.BatchCall(ctx, elems); err != nil {
+		return nil, err
+	}
+	out := make([]UserOperationReceiptResult, len(hashes))
+	for i, hash := range hashes {
+		out[i] = UserOperationReceiptResult{Hash: hash, Receipt: receipts[i], Err: elems[i].Error}
+		switch {
+		case out[i].Err != nil:
+			out[i].Receipt = nil
+		case receipts[i] == nil:
+			out[i].Err = ErrNotFound
+		default:
+			receipts[i].Event = findUserOperationEvent(receipts[i].Receipt.Logs, receipts[i].EntryPoint, hash)
+		}
+	}
+	return out, nil
+}
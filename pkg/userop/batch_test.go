@@ -0,0 +1,137 @@
+package userop_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync/atomic"
+	"testing"
+
+	"github.com/eipcodelab/eip7702-go/pkg/userop"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// newBatchServer answers batch arrays in reverse order using handler per call.
+func newBatchServer(t *testing.T, requests *atomic.Int32, handler rpcHandler) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		var calls []rpcCall
+		if err := json.NewDecoder(r.Body).Decode(&calls); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		out := make([]map[string]any, 0, len(calls))
+		for _, call := range calls {
+			result, rpcErr := handler(call)
+			resp := map[string]any{"jsonrpc": "2.0", "id": call.ID}
+			if rpcErr != nil {
+				resp["error"] = rpcErr
+			} else {
+				resp["result"] = result
+			}
+			out = append(out, resp)
+		}
+		slices.Reverse(out)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(out)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestBatchCallMatchesIDsAndSplits(t *testing.T) {
+	var requests atomic.Int32
+	srv := newBatchServer(t, &requests, func(call rpcCall) (any, map[string]any) {
+		var n int
+		_ = json.Unmarshal(call.Params[0], &n)
+		if n == 4 {
+			return nil, map[string]any{"code": userop.CodeInvalidParams, "message": "bad"}
+		}
+		return n * 10, nil
+	})
+	client := userop.NewBundlerClientWithOptions(srv.URL, userop.ClientOptions{MaxBatchSize: 3})
+
+	results := make([]int, 7)
+	elems := make([]userop.BatchElem, len(results))
+	for i := range elems {
+		elems[i] = userop.BatchElem{Method: "test_times10", Params: []any{i}, Result: &results[i]}
+	}
+	if err := client.BatchCall(context.Background(), elems); err != nil {
+		t.Fatalf("BatchCall() error = %v", err)
+	}
+	if got := requests.Load(); got != 3 {
+		t.Fatalf("requests = %d, want 3", got)
+	}
+	for i, elem := range elems {
+		if i == 4 {
+			if !errors.Is(elem.Error, userop.ErrInvalidParams) {
+				t.Fatalf("elem 4 error = %v, want ErrInvalidParams", elem.Error)
+			}
+			continue
+		}
+		if elem.Error != nil || results[i] != i*10 {
+			t.Fatalf("elem %d = %d, %v", i, results[i], elem.Error)
+		}
+	}
+}
+
+func TestBatchCallMissingResponse(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var calls []rpcCall
+		_ = json.NewDecoder(r.Body).Decode(&calls)
+		_ = json.NewEncoder(w).Encode([]map[string]any{{"jsonrpc": "2.0", "id": calls[0].ID, "result": "0x1"}})
+	}))
+	t.Cleanup(srv.Close)
+
+	elems := []userop.BatchElem{{Method: "eth_chainId"}, {Method: "eth_chainId"}}
+	if err := userop.NewBundlerClient(srv.URL).BatchCall(context.Background(), elems); err != nil {
+		t.Fatalf("BatchCall() error = %v", err)
+	}
+	if elems[0].Error != nil || !errors.Is(elems[1].Error, userop.ErrInvalidResponse) {
+		t.Fatalf("errors = %v, %v", elems[0].Error, elems[1].Error)
+	}
+}
+
+func TestBatchCallUnsupported(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":null,"error":{"code":-32600,"message":"batch not supported"}}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	var rpcErr *userop.RPCError
+	err := userop.NewBundlerClient(srv.URL).BatchCall(context.Background(), []userop.BatchElem{{Method: "eth_chainId"}})
+	if !errors.As(err, &rpcErr) || rpcErr.Code != -32600 {
+		t.Fatalf("BatchCall() error = %v, want rpc error", err)
+	}
+}
+
+func TestGetUserOperationReceipts(t *testing.T) {
+	pending := common.HexToHash("0x01")
+	var requests atomic.Int32
+	srv := newBatchServer(t, &requests, func(call rpcCall) (any, map[string]any) {
+		var hash common.Hash
+		_ = json.Unmarshal(call.Params[0], &hash)
+		if hash == pending {
+			return nil, nil
+		}
+		return receiptFixture(true), nil
+	})
+
+	results, err := userop.NewBundlerClient(srv.URL).GetUserOperationReceipts(context.Background(), []common.Hash{testUserOpHash, pending})
+	if err != nil {
+		t.Fatalf("GetUserOperationReceipts() error = %v", err)
+	}
+	if len(results) != 2 || results[0].Hash != testUserOpHash || results[1].Hash != pending {
+		t.Fatalf("results = %+v", results)
+	}
+	if results[0].Err != nil || results[0].Receipt == nil || results[0].Receipt.Event == nil {
+		t.Fatalf("first result = %+v, want decoded receipt", results[0])
+	}
+	if !errors.Is(results[1].Err, userop.ErrNotFound) || results[1].Receipt != nil {
+		t.Fatalf("second result = %+v, want ErrNotFound", results[1])
+	}
+}
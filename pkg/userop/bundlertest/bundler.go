@@ -0,0 +1,640 @@
+package bundlertest
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"github.com/eipcodelab/eip7702-go/pkg/userop"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const (
+	codeParseError     = -32700
+	codeMethodNotFound = -32601
+)
+
+// DefaultChainID is used when Options.ChainID is nil.
+var DefaultChainID = big.NewInt(1337)
+
+// DefaultGasEstimate is returned by eth_estimateUserOperationGas unless overridden.
+var DefaultGasEstimate = userop.GasEstimate{
+	PreVerificationGas:   50_000,
+	VerificationGasLimit: 150_000,
+	CallGasLimit:         100_000,
+}
+
+// BundlerAddress is the beneficiary reported as the sender of bundle transactions.
+var BundlerAddress = common.HexToAddress("0x000000000000000000000000000000000000b0b0")
+
+var getNonceSelector = crypto.Keccak256([]byte("getNonce(address,uint192)"))[:4]
+
+// Options configures New. Zero values use the defaults.
+type Options struct {
+	// ChainID defaults to DefaultChainID.
+	ChainID *big.Int
+	// EntryPoints defaults to the canonical v0.6, v0.7 and v0.8 deployments.
+	// Other addresses are served with the v0.7 operation shape.
+	EntryPoints []common.Address
+	// GasEstimate overrides DefaultGasEstimate.
+	GasEstimate *userop.GasEstimate
+	// ManualMining keeps operations pending until Mine is called.
+	// By default every accepted operation is included in its own block at once.
+	ManualMining bool
+}
+
+// Fault scripts a failure. Empty filters match every request.
+type Fault struct {
+	// Method restricts the fault to one RPC method.
+	Method string
+	// Sender restricts the fault to operations from one account
+	// (eth_sendUserOperation and eth_estimateUserOperationGas only).
+	Sender *common.Address
+	// Times is how often the fault fires; zero means every time.
+	Times int
+
+	// Delay is applied before the request is answered.
+	Delay time.Duration
+	// Error is returned instead of a result when non-nil.
+	Error *userop.RPCError
+	// HTTPStatus, when non-zero, is used as the response status.
+	HTTPStatus int
+	// Drop accepts an eth_sendUserOperation but never includes it.
+	Drop bool
+	// Revert includes the operation with success=false and this revert reason.
+	Revert []byte
+}
+
+// Entry is one accepted user operation.
+type Entry struct {
+	Hash       common.Hash
+	EntryPoint common.Address
+	Sender     common.Address
+	Nonce      *big.Int
+	// Op is a userop.UserOperation or userop.UserOperationV07.
+	Op any
+	// Block is the inclusion block, or zero while pending or dropped.
+	Block   uint64
+	Dropped bool
+	Success bool
+	Revert  []byte
+
+	gasUsed uint64
+	maxFee  *big.Int
+	pm      common.Address
+	raw     json.RawMessage
+}
+
+// Bundler is an in-memory bundler served over HTTP. It is safe for concurrent use.
+type Bundler struct {
+	// URL is the JSON-RPC endpoint, as for httptest.Server.
+	URL string
+
+	srv         *httptest.Server
+	chainID     *big.Int
+	entryPoints []common.Address
+	estimate    userop.GasEstimate
+	manual      bool
+
+	mu      sync.Mutex
+	entries map[common.Hash]*Entry
+	order   []common.Hash
+	pending []common.Hash
+	block   uint64
+	faults  []*Fault
+}
+
+// New starts a bundler. Call Close when done.
+func New(opts Options) *Bundler {
+	b := &Bundler{
+		chainID:     DefaultChainID,
+		entryPoints: opts.EntryPoints,
+		estimate:    DefaultGasEstimate,
+		manual:      opts.ManualMining,
+		entries:     make(map[common.Hash]*Entry),
+	}
+	if opts.ChainID != nil {
+		b.chainID = new(big.Int).Set(opts.ChainID)
+	}
+	if len(b.entryPoints) == 0 {
+		b.entryPoints = []common.Address{userop.EntryPointV06Address, userop.EntryPointV07Address, userop.EntryPointV08Address}
+	}
+	if opts.GasEstimate != nil {
+		b.estimate = *opts.GasEstimate
+	}
+	b.srv = httptest.NewServer(http.HandlerFunc(b.serveHTTP))
+	b.URL = b.srv.URL
+	return b
+}
+
+// Close shuts the server down.
+func (b *Bundler) Close() {
+	b.srv.Close()
+}
+
+// Client returns a BundlerClient for URL.
+func (b *Bundler) Client() *userop.BundlerClient {
+	return userop.NewBundlerClient(b.URL)
+}
+
+// ChainID returns the chain ID the bundler hashes operations for.
+func (b *Bundler) ChainID() *big.Int {
+	return new(big.Int).Set(b.chainID)
+}
+
+// Inject adds a fault. Faults are checked in the order they were added.
+func (b *Bundler) Inject(f Fault) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.faults = append(b.faults, &f)
+}
+
+// ClearFaults removes every injected fault.
+func (b *Bundler) ClearFaults() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.faults = nil
+}
+
+// Mine includes all pending operations in one new block and returns their hashes.
+func (b *Bundler) Mine() []common.Hash {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.mineLocked()
+}
+
+// Entries returns accepted operations in submission order.
+func (b *Bundler) Entries() []Entry {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	out := make([]Entry, len(b.order))
+	for i, hash := range b.order {
+		out[i] = *b.entries[hash]
+	}
+	return out
+}
+
+// Entry returns one accepted operation by hash.
+func (b *Bundler) Entry(hash common.Hash) (Entry, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	e, ok := b.entries[hash]
+	if !ok {
+		return Entry{}, false
+	}
+	return *e, true
+}
+
+type rpcRequest struct {
+	ID     json.RawMessage   `json:"id"`
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+}
+
+type rpcResponse struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      json.RawMessage  `json:"id"`
+	Result  any              `json:"result"`
+	Error   *userop.RPCError `json:"error,omitempty"`
+}
+
+// MarshalJSON drops result when an error is set, as JSON-RPC requires.
+func (r rpcResponse) MarshalJSON() ([]byte, error) {
+	type plain rpcResponse
+	if r.Error != nil {
+		return json.Marshal(struct {
+			plain
+			Result any `json:"result,omitempty"`
+		}{plain: plain(r)})
+	}
+	return json.Marshal(plain(r))
+}
+
+func (b *Bundler) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	var batch []rpcRequest
+	if json.Unmarshal(body, &batch) == nil {
+		out := make([]rpcResponse, len(batch))
+		status := http.StatusOK
+		for i, req := range batch {
+			var s int
+			out[i], s = b.handle(r.Context(), req)
+			status = max(status, s)
+		}
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(out)
+		return
+	}
+	var req rpcRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		_ = json.NewEncoder(w).Encode(rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &userop.RPCError{Code: codeParseError, Message: err.Error()}})
+		return
+	}
+	resp, status := b.handle(r.Context(), req)
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+func (b *Bundler) handle(ctx context.Context, req rpcRequest) (rpcResponse, int) {
+	resp := rpcResponse{JSONRPC: "2.0", ID: req.ID}
+	if len(resp.ID) == 0 {
+		resp.ID = json.RawMessage("null")
+	}
+	fault := b.matchFault(req)
+	status := http.StatusOK
+	if fault != nil {
+		if fault.Delay > 0 {
+			select {
+			case <-time.After(fault.Delay):
+			case <-ctx.Done():
+			}
+		}
+		if fault.HTTPStatus != 0 {
+			status = fault.HTTPStatus
+		}
+		if fault.Error != nil {
+			resp.Error = fault.Error
+			return resp, status
+		}
+	}
+	resp.Result, resp.Error = b.dispatch(req, fault)
+	return resp, status
+}
+
+func (b *Bundler) dispatch(req rpcRequest, fault *Fault) (any, *userop.RPCError) {
+	switch req.Method {
+	case "eth_chainId":
+		return (*hexutil.Big)(b.chainID), nil
+	case "eth_supportedEntryPoints":
+		return b.entryPoints, nil
+	case "eth_blockNumber":
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		return hexutil.Uint64(b.block), nil
+	case "eth_sendUserOperation":
+		return b.sendUserOperation(req.Params, fault)
+	case "eth_estimateUserOperationGas":
+		return b.estimateUserOperationGas(req.Params)
+	case "eth_getUserOperationByHash":
+		return b.lookup(req.Params, b.userOperationByHash)
+	case "eth_getUserOperationReceipt":
+		return b.lookup(req.Params, b.receipt)
+	case "eth_call":
+		return b.call(req.Params)
+	default:
+		return nil, &userop.RPCError{Code: codeMethodNotFound, Message: "method not found: " + req.Method}
+	}
+}
+
+func (b *Bundler) matchFault(req rpcRequest) *Fault {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i, f := range b.faults {
+		if f.Method != "" && f.Method != req.Method {
+			continue
+		}
+		if f.Sender != nil && (len(req.Params) == 0 || senderOf(req.Params[0]) != *f.Sender) {
+			continue
+		}
+		if f.Times > 0 {
+			f.Times--
+			if f.Times == 0 {
+				b.faults = append(b.faults[:i:i], b.faults[i+1:]...)
+			}
+		}
+		return f
+	}
+	return nil
+}
+
+func senderOf(raw json.RawMessage) common.Address {
+	var op struct {
+		Sender common.Address `json:"sender"`
+	}
+	_ = json.Unmarshal(raw, &op)
+	return op.Sender
+}
+
+// decoded is an operation after shape-specific decoding and validation.
+type decoded struct {
+	op      any
+	hash    common.Hash
+	sender  common.Address
+	nonce   *big.Int
+	gas     uint64
+	maxFee  *big.Int
+	pm      common.Address
+	hasPm   bool
+	version userop.EntryPointVersion
+}
+
+func (b *Bundler) decode(params []json.RawMessage, validate bool) (*decoded, common.Address, *userop.RPCError) {
+	if len(params) < 2 {
+		return nil, common.Address{}, invalidParams(errors.New("expected [userOp, entryPoint]"))
+	}
+	var entryPoint common.Address
+	if err := json.Unmarshal(params[1], &entryPoint); err != nil {
+		return nil, common.Address{}, invalidParams(fmt.Errorf("decode entry point: %v", err))
+	}
+	if !b.supports(entryPoint) {
+		return nil, common.Address{}, invalidParams(fmt.Errorf("unsupported entry point %s", entryPoint.Hex()))
+	}
+	version, ok := userop.EntryPointVersionOf(entryPoint)
+	if !ok {
+		version = userop.EntryPointV07
+	}
+
+	if version == userop.EntryPointV06 {
+		var op userop.UserOperation
+		if err := json.Unmarshal(params[0], &op); err != nil {
+			return nil, entryPoint, invalidParams(fmt.Errorf("decode user operation: %v", err))
+		}
+		if validate {
+			if err := op.ValidateBasic(); err != nil {
+				return nil, entryPoint, invalidParams(err)
+			}
+		}
+		d := &decoded{op: op, sender: op.Sender, version: version,
+			gas: uint64(op.CallGasLimit) + uint64(op.VerificationGasLimit) + uint64(op.PreVerificationGas)}
+		if len(op.PaymasterAndData) >= common.AddressLength {
+			d.pm, d.hasPm = common.BytesToAddress(op.PaymasterAndData[:common.AddressLength]), true
+		}
+		if !validate {
+			return d, entryPoint, nil
+		}
+		hash, err := userop.UserOpHash(op, entryPoint, b.chainID)
+		if err != nil {
+			return nil, entryPoint, invalidParams(err)
+		}
+		d.hash, d.nonce, d.maxFee = hash, op.Nonce.ToInt(), op.MaxFeePerGas.ToInt()
+		return d, entryPoint, nil
+	}
+
+	var op userop.UserOperationV07
+	if err := json.Unmarshal(params[0], &op); err != nil {
+		return nil, entryPoint, invalidParams(fmt.Errorf("decode user operation: %v", err))
+	}
+	d := &decoded{op: op, sender: op.Sender, version: version,
+		gas: uint64(op.CallGasLimit) + uint64(op.VerificationGasLimit) + uint64(op.PreVerificationGas) +
+			uint64(op.PaymasterVerificationGasLimit) + uint64(op.PaymasterPostOpGasLimit)}
+	if op.Paymaster != nil {
+		d.pm, d.hasPm = *op.Paymaster, true
+	}
+	if !validate {
+		return d, entryPoint, nil
+	}
+	if err := op.ValidateBasic(); err != nil {
+		return nil, entryPoint, invalidParams(err)
+	}
+	if op.EIP7702Auth != nil {
+		if err := userop.ValidateEIP7702(op, entryPoint, b.chainID); err != nil {
+			return nil, entryPoint, invalidParams(err)
+		}
+	}
+	hash, err := userop.UserOpHashForEntryPoint(op, entryPoint, b.chainID)
+	if err != nil {
+		return nil, entryPoint, invalidParams(err)
+	}
+	d.hash, d.nonce, d.maxFee = hash, op.Nonce.ToInt(), op.MaxFeePerGas.ToInt()
+	return d, entryPoint, nil
+}
+
+func (b *Bundler) supports(entryPoint common.Address) bool {
+	for _, ep := range b.entryPoints {
+		if ep == entryPoint {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *Bundler) sendUserOperation(params []json.RawMessage, fault *Fault) (any, *userop.RPCError) {
+	d, entryPoint, rpcErr := b.decode(params, true)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.entries[d.hash]; ok {
+		// Resubmitting the same operation is idempotent.
+		return d.hash, nil
+	}
+	e := &Entry{
+		Hash:       d.hash,
+		EntryPoint: entryPoint,
+		Sender:     d.sender,
+		Nonce:      new(big.Int).Set(d.nonce),
+		Op:         d.op,
+		Success:    true,
+		gasUsed:    d.gas,
+		maxFee:     new(big.Int).Set(d.maxFee),
+		pm:         d.pm,
+		raw:        append(json.RawMessage(nil), params[0]...),
+	}
+	if fault != nil {
+		e.Dropped = fault.Drop
+		if fault.Revert != nil {
+			e.Success = false
+			e.Revert = append([]byte(nil), fault.Revert...)
+		}
+	}
+	b.entries[d.hash] = e
+	b.order = append(b.order, d.hash)
+	if !e.Dropped {
+		b.pending = append(b.pending, d.hash)
+		if !b.manual {
+			b.mineLocked()
+		}
+	}
+	return d.hash, nil
+}
+
+// call serves EntryPoint.getNonce from included operations, the only eth_call the bundler knows.
+func (b *Bundler) call(params []json.RawMessage) (any, *userop.RPCError) {
+	var msg struct {
+		To   *common.Address `json:"to"`
+		Data hexutil.Bytes   `json:"data"`
+	}
+	if len(params) == 0 || json.Unmarshal(params[0], &msg) != nil {
+		return nil, invalidParams(errors.New("expected [call, block]"))
+	}
+	if msg.To == nil || !b.supports(*msg.To) || len(msg.Data) != 4+2*32 || !bytes.Equal(msg.Data[:4], getNonceSelector) {
+		return nil, invalidParams(errors.New("only EntryPoint.getNonce is supported"))
+	}
+	sender := common.BytesToAddress(msg.Data[4:36])
+	key := new(big.Int).SetBytes(msg.Data[36:68])
+	var next uint64
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, e := range b.entries {
+		if e.EntryPoint != *msg.To || e.Sender != sender || e.Block == 0 {
+			continue
+		}
+		if k, seq := userop.DecodeNonce(e.Nonce); k.Cmp(key) == 0 && seq >= next {
+			next = seq + 1
+		}
+	}
+	nonce, err := userop.EncodeNonce(key, next)
+	if err != nil {
+		return nil, invalidParams(err)
+	}
+	return hexutil.Bytes(common.LeftPadBytes(nonce.Bytes(), 32)), nil
+}
+
+func (b *Bundler) estimateUserOperationGas(params []json.RawMessage) (any, *userop.RPCError) {
+	d, _, rpcErr := b.decode(params, false)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+	estimate := b.estimate
+	if d.version != userop.EntryPointV06 && d.hasPm {
+		if estimate.PaymasterVerificationGasLimit == nil {
+			v := hexutil.Uint64(50_000)
+			estimate.PaymasterVerificationGasLimit = &v
+		}
+		if estimate.PaymasterPostOpGasLimit == nil {
+			v := hexutil.Uint64(0)
+			estimate.PaymasterPostOpGasLimit = &v
+		}
+	} else {
+		estimate.PaymasterVerificationGasLimit, estimate.PaymasterPostOpGasLimit = nil, nil
+	}
+	return estimate, nil
+}
+
+func (b *Bundler) lookup(params []json.RawMessage, fn func(*Entry) any) (any, *userop.RPCError) {
+	if len(params) < 1 {
+		return nil, invalidParams(errors.New("expected [userOpHash]"))
+	}
+	var hash common.Hash
+	if err := json.Unmarshal(params[0], &hash); err != nil {
+		return nil, invalidParams(fmt.Errorf("decode userOp hash: %v", err))
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	e, ok := b.entries[hash]
+	if !ok {
+		return nil, nil
+	}
+	return fn(e), nil
+}
+
+func (b *Bundler) userOperationByHash(e *Entry) any {
+	out := userop.UserOperationByHash{UserOperation: e.raw, EntryPoint: e.EntryPoint}
+	if e.Block != 0 {
+		blockHash, txHash := blockHash(e.Block), txHash(e.Block)
+		out.BlockNumber = userop.HexBig(new(big.Int).SetUint64(e.Block))
+		out.BlockHash = &blockHash
+		out.TransactionHash = &txHash
+	}
+	return out
+}
+
+// receipt returns nil (JSON null) for pending and dropped operations.
+func (b *Bundler) receipt(e *Entry) any {
+	if e.Block == 0 {
+		return nil
+	}
+	block := new(big.Int).SetUint64(e.Block)
+	gasUsed := new(big.Int).SetUint64(e.gasUsed)
+	gasCost := new(big.Int).Mul(gasUsed, e.maxFee)
+	logBase := types.Log{
+		Address:     e.EntryPoint,
+		BlockNumber: e.Block,
+		TxHash:      txHash(e.Block),
+		BlockHash:   blockHash(e.Block),
+	}
+
+	event := logBase
+	event.Topics = []common.Hash{userop.UserOperationEventTopic, e.Hash, common.BytesToHash(e.Sender.Bytes()), common.BytesToHash(e.pm.Bytes())}
+	success := new(big.Int)
+	if e.Success {
+		success.SetUint64(1)
+	}
+	event.Data = concatWords(e.Nonce, success, gasCost, gasUsed)
+	logs := []*types.Log{&event}
+	if !e.Success {
+		revert := logBase
+		revert.Topics = []common.Hash{userop.UserOperationRevertReasonTopic, e.Hash, common.BytesToHash(e.Sender.Bytes())}
+		revert.Data = append(concatWords(e.Nonce, big.NewInt(64), big.NewInt(int64(len(e.Revert)))), common.RightPadBytes(e.Revert, (len(e.Revert)+31)/32*32)...)
+		revert.Index = 0
+		event.Index = 1
+		logs = []*types.Log{&revert, &event}
+	}
+
+	entryPoint := e.EntryPoint
+	return userop.UserOperationReceipt{
+		UserOpHash:    e.Hash,
+		EntryPoint:    e.EntryPoint,
+		Sender:        e.Sender,
+		Nonce:         userop.HexBig(e.Nonce),
+		Paymaster:     e.pm,
+		ActualGasCost: userop.HexBig(gasCost),
+		ActualGasUsed: userop.HexBig(gasUsed),
+		Success:       e.Success,
+		Logs:          []*types.Log{},
+		Receipt: userop.TransactionReceipt{
+			TransactionHash:   txHash(e.Block),
+			BlockHash:         blockHash(e.Block),
+			BlockNumber:       userop.HexBig(block),
+			From:              BundlerAddress,
+			To:                &entryPoint,
+			GasUsed:           hexutil.Uint64(e.gasUsed),
+			EffectiveGasPrice: userop.HexBig(e.maxFee),
+			Status:            1,
+			Logs:              logs,
+		},
+	}
+}
+
+func (b *Bundler) mineLocked() []common.Hash {
+	if len(b.pending) == 0 {
+		return nil
+	}
+	b.block++
+	mined := b.pending
+	b.pending = nil
+	for _, hash := range mined {
+		b.entries[hash].Block = b.block
+	}
+	return mined
+}
+
+// blockHash and txHash are deterministic stand-ins derived from the block number.
+func blockHash(block uint64) common.Hash {
+	return crypto.Keccak256Hash([]byte("bundlertest-block"), binary.BigEndian.AppendUint64(nil, block))
+}
+
+func txHash(block uint64) common.Hash {
+	return crypto.Keccak256Hash([]byte("bundlertest-tx"), binary.BigEndian.AppendUint64(nil, block))
+}
+
+func concatWords(values ...*big.Int) []byte {
+	out := make([]byte, 0, 32*len(values))
+	for _, v := range values {
+		out = append(out, common.LeftPadBytes(v.Bytes(), 32)...)
+	}
+	return out
+}
+
+func invalidParams(err error) *userop.RPCError {
+	return &userop.RPCError{Code: userop.CodeInvalidParams, Message: err.Error()}
+}
@@ -0,0 +1,249 @@
+package bundlertest_test
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/eipcodelab/eip7702-go/pkg/batching"
+	"github.com/eipcodelab/eip7702-go/pkg/userop"
+	"github.com/eipcodelab/eip7702-go/pkg/userop/bundlertest"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var fastWait = userop.WaitOptions{PollInterval: time.Millisecond, MaxPollInterval: 5 * time.Millisecond, Timeout: time.Second}
+
+func newBundler(t *testing.T, opts bundlertest.Options) *bundlertest.Bundler {
+	t.Helper()
+	b := bundlertest.New(opts)
+	t.Cleanup(b.Close)
+	return b
+}
+
+// signedOp returns a v0.7 operation signed by a fresh key for b's chain.
+func signedOp(t *testing.T, b *bundlertest.Bundler, nonce int64) userop.UserOperationV07 {
+	t.Helper()
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	op := userop.UserOperationV07{
+		Sender:               crypto.PubkeyToAddress(key.PublicKey),
+		Nonce:                userop.HexBig(big.NewInt(nonce)),
+		CallData:             []byte{0x01},
+		CallGasLimit:         100_000,
+		VerificationGasLimit: 150_000,
+		PreVerificationGas:   50_000,
+		MaxFeePerGas:         userop.HexBig(big.NewInt(2_000_000_000)),
+		MaxPriorityFeePerGas: userop.HexBig(big.NewInt(1_000_000_000)),
+	}
+	if err := userop.SignUserOperationV07(&op, key, userop.EntryPointV07Address, b.ChainID(), userop.SignatureRawHash); err != nil {
+		t.Fatal(err)
+	}
+	return op
+}
+
+func TestBundlerSendAndWait(t *testing.T) {
+	b := newBundler(t, bundlertest.Options{})
+	client := b.Client()
+	ctx := context.Background()
+	op := signedOp(t, b, 0)
+
+	hash, err := client.SendUserOperationV07(ctx, op, userop.EntryPointV07Address)
+	if err != nil {
+		t.Fatalf("SendUserOperationV07() error = %v", err)
+	}
+	want, _ := userop.UserOpHashForEntryPoint(op, userop.EntryPointV07Address, b.ChainID())
+	if hash != want {
+		t.Fatalf("hash = %s, want %s", hash.Hex(), want.Hex())
+	}
+	if again, err := client.SendUserOperationV07(ctx, op, userop.EntryPointV07Address); err != nil || again != hash {
+		t.Fatalf("resubmission = %s, %v", again.Hex(), err)
+	}
+
+	result, err := client.WaitForUserOperation(ctx, hash, fastWait)
+	if err != nil {
+		t.Fatalf("WaitForUserOperation() error = %v", err)
+	}
+	if !result.Success || result.Receipt.Event == nil || result.Receipt.Event.Sender != op.Sender {
+		t.Fatalf("result = %+v", result)
+	}
+	byHash, err := client.GetUserOperationByHash(ctx, hash)
+	if err != nil || byHash.BlockNumber == nil || byHash.BlockNumber.ToInt().Uint64() != 1 {
+		t.Fatalf("GetUserOperationByHash() = %+v, %v", byHash, err)
+	}
+	if entries := b.Entries(); len(entries) != 1 || entries[0].Block != 1 {
+		t.Fatalf("entries = %+v", entries)
+	}
+}
+
+func TestBundlerRejectsInvalidOperations(t *testing.T) {
+	b := newBundler(t, bundlertest.Options{})
+	op := signedOp(t, b, 0)
+	// Bypass client-side validation by calling the method directly.
+	op.Signature = nil
+	err := b.Client().Call(context.Background(), nil, "eth_sendUserOperation", op, userop.EntryPointV07Address)
+	if !errors.Is(err, userop.ErrInvalidParams) {
+		t.Fatalf("error = %v, want ErrInvalidParams", err)
+	}
+	if _, err := b.Client().SendUserOperationV07(context.Background(), signedOp(t, b, 0), common.HexToAddress("0x01")); !errors.Is(err, userop.ErrInvalidParams) {
+		t.Fatalf("unsupported entry point error = %v", err)
+	}
+}
+
+func TestBundlerManualMining(t *testing.T) {
+	b := newBundler(t, bundlertest.Options{ManualMining: true})
+	client := b.Client()
+	ctx := context.Background()
+
+	hash, err := client.SendUserOperationV07(ctx, signedOp(t, b, 0), userop.EntryPointV07Address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.GetUserOperationReceipt(ctx, hash); !errors.Is(err, userop.ErrNotFound) {
+		t.Fatalf("pending receipt error = %v, want ErrNotFound", err)
+	}
+	if mined := b.Mine(); len(mined) != 1 || mined[0] != hash {
+		t.Fatalf("Mine() = %v", mined)
+	}
+	if _, err := client.GetUserOperationReceipt(ctx, hash); err != nil {
+		t.Fatalf("mined receipt error = %v", err)
+	}
+}
+
+func TestBundlerFaults(t *testing.T) {
+	b := newBundler(t, bundlertest.Options{})
+	client := b.Client()
+	ctx := context.Background()
+
+	b.Inject(bundlertest.Fault{
+		Method: "eth_sendUserOperation",
+		Times:  1,
+		Error:  &userop.RPCError{Code: userop.CodeThrottled, Message: "throttled"},
+	})
+	op := signedOp(t, b, 0)
+	if _, err := client.SendUserOperationV07(ctx, op, userop.EntryPointV07Address); !errors.Is(err, userop.ErrThrottled) {
+		t.Fatalf("first send error = %v, want ErrThrottled", err)
+	}
+	if _, err := client.SendUserOperationV07(ctx, op, userop.EntryPointV07Address); err != nil {
+		t.Fatalf("fault should fire once: %v", err)
+	}
+
+	dropped := signedOp(t, b, 0)
+	b.Inject(bundlertest.Fault{Sender: &dropped.Sender, Drop: true})
+	hash, err := client.SendUserOperationV07(ctx, dropped, userop.EntryPointV07Address)
+	if err != nil {
+		t.Fatalf("dropped send error = %v", err)
+	}
+	if _, err := client.GetUserOperationReceipt(ctx, hash); !errors.Is(err, userop.ErrNotFound) {
+		t.Fatalf("dropped receipt error = %v, want ErrNotFound", err)
+	}
+	if entry, ok := b.Entry(hash); !ok || !entry.Dropped {
+		t.Fatalf("entry = %+v", entry)
+	}
+	b.ClearFaults()
+
+	reverting := signedOp(t, b, 0)
+	reason, _ := batching.EncodeFunctionCall(`[{"type":"function","name":"Error","inputs":[{"name":"","type":"string"}]}]`, "Error", "no funds")
+	b.Inject(bundlertest.Fault{Sender: &reverting.Sender, Revert: reason})
+	hash, err = client.SendUserOperationV07(ctx, reverting, userop.EntryPointV07Address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := client.WaitForUserOperation(ctx, hash, fastWait)
+	if err != nil {
+		t.Fatalf("WaitForUserOperation() error = %v", err)
+	}
+	if result.Success || result.Revert == nil || result.Revert.Message != "no funds" {
+		t.Fatalf("revert result = %+v", result.Revert)
+	}
+}
+
+func TestBundlerTransportFaults(t *testing.T) {
+	b := newBundler(t, bundlertest.Options{})
+	client := b.Client()
+
+	b.Inject(bundlertest.Fault{Method: "eth_chainId", Times: 1, HTTPStatus: http.StatusServiceUnavailable})
+	var httpErr *userop.HTTPError
+	if _, err := client.ChainID(context.Background()); !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("error = %v, want 503", err)
+	}
+
+	b.Inject(bundlertest.Fault{Method: "eth_chainId", Times: 1, Delay: time.Second})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := client.ChainID(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("error = %v, want deadline exceeded", err)
+	}
+}
+
+func TestBundlerBatchAndV06(t *testing.T) {
+	b := newBundler(t, bundlertest.Options{})
+	client := b.Client()
+	ctx := context.Background()
+
+	key, _ := crypto.GenerateKey()
+	op := userop.UserOperation{
+		Sender:               crypto.PubkeyToAddress(key.PublicKey),
+		Nonce:                userop.HexBig(big.NewInt(0)),
+		CallData:             []byte{0x01},
+		CallGasLimit:         100_000,
+		VerificationGasLimit: 150_000,
+		PreVerificationGas:   50_000,
+		MaxFeePerGas:         userop.HexBig(big.NewInt(2_000_000_000)),
+		MaxPriorityFeePerGas: userop.HexBig(big.NewInt(1_000_000_000)),
+	}
+	if err := userop.SignUserOperation(&op, key, userop.EntryPointV06Address, b.ChainID(), userop.SignatureRawHash); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := client.SendUserOperation(ctx, op, userop.EntryPointV06Address)
+	if err != nil {
+		t.Fatalf("SendUserOperation() error = %v", err)
+	}
+	results, err := client.GetUserOperationReceipts(ctx, []common.Hash{hash, common.HexToHash("0x01")})
+	if err != nil {
+		t.Fatalf("GetUserOperationReceipts() error = %v", err)
+	}
+	if results[0].Err != nil || results[0].Receipt.EntryPoint != userop.EntryPointV06Address || !errors.Is(results[1].Err, userop.ErrNotFound) {
+		t.Fatalf("results = %+v", results)
+	}
+}
+
+func TestBundlerServesGetNonce(t *testing.T) {
+	b := newBundler(t, bundlertest.Options{})
+	client := b.Client()
+	ctx := context.Background()
+	key, _ := crypto.GenerateKey()
+	sender := crypto.PubkeyToAddress(key.PublicKey)
+	nonces := userop.NewNonceManager(client, userop.EntryPointV07Address)
+
+	for i := 0; i < 2; i++ {
+		nonce, err := nonces.Next(ctx, sender, big.NewInt(1))
+		if err != nil {
+			t.Fatalf("Next() error = %v", err)
+		}
+		op := signedOp(t, b, 0)
+		op.Sender, op.Nonce = sender, userop.HexBig(nonce)
+		if err := userop.SignUserOperationV07(&op, key, userop.EntryPointV07Address, b.ChainID(), userop.SignatureRawHash); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := client.SendUserOperationV07(ctx, op, userop.EntryPointV07Address); err != nil {
+			t.Fatal(err)
+		}
+		nonces.Confirm(sender, nonce)
+	}
+	onChain, err := userop.GetNonce(ctx, client, userop.EntryPointV07Address, sender, big.NewInt(1))
+	if err != nil {
+		t.Fatalf("GetNonce() error = %v", err)
+	}
+	if want, _ := userop.EncodeNonce(big.NewInt(1), 2); onChain.Cmp(want) != 0 {
+		t.Fatalf("on-chain nonce = %x, want %x", onChain, want)
+	}
+	if other, _ := userop.GetNonce(ctx, client, userop.EntryPointV07Address, sender, big.NewInt(0)); other.Sign() != 0 {
+		t.Fatalf("lane 0 nonce = %s, want 0", other)
+	}
+}
@@ -0,0 +1,2 @@
+// Package bundlertest provides an in-memory ERC-4337 bundler with scriptable faults for tests.
+package bundlertest
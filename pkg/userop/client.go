@@ -4,8 +4,13 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -18,56 +23,181 @@ type rpcRequest struct {
 	Params  []any  `json:"params"`
 }
 
-type rpcError struct {
-	Code    int             `json:"code"`
-	Message string          `json:"message"`
-	Data    json.RawMessage `json:"data,omitempty"`
-}
-
 type rpcResponse struct {
 	JSONRPC string          `json:"jsonrpc"`
-	ID      uint64          `json:"id"`
+	ID      json.RawMessage `json:"id"`
 	Result  json.RawMessage `json:"result,omitempty"`
-	Error   *rpcError       `json:"error,omitempty"`
+	Error   *RPCError       `json:"error,omitempty"`
+}
+
+const (
+	// DefaultMaxResponseBytes caps how much of a bundler response is read.
+	DefaultMaxResponseBytes = 10 << 20
+	// DefaultMaxBatchSize is the largest JSON-RPC batch sent in one request.
+	DefaultMaxBatchSize = 100
+	// DefaultAPIKeyHeader carries ClientOptions.APIKey when APIKeyHeader is empty.
+	DefaultAPIKeyHeader = "X-API-Key"
+
+	defaultHTTPTimeout = 20 * time.Second
+	httpErrorBodyLimit = 512
+)
+
+var (
+	// ErrInvalidResponse is returned for bodies that are not a matching JSON-RPC response.
+	ErrInvalidResponse = errors.New("invalid rpc response")
+	// ErrResponseTooLarge is returned when a body exceeds MaxResponseBytes.
+	ErrResponseTooLarge = errors.New("rpc response exceeds size limit")
+)
+
+// HTTPError is returned for non-2xx responses. When the body carries a JSON-RPC
+// error it is kept in RPCError and matched through errors.Is and errors.As.
+type HTTPError struct {
+	StatusCode int
+	Status     string
+	Body       []byte
+	RPCError   *RPCError
+}
+
+func (e *HTTPError) Error() string {
+	if e.RPCError != nil {
+		return fmt.Sprintf("http %s: %v", e.Status, e.RPCError)
+	}
+	return fmt.Sprintf("http %s: %s", e.Status, bytes.TrimSpace(e.Body))
+}
+
+func (e *HTTPError) Unwrap() error {
+	if e.RPCError == nil {
+		return nil
+	}
+	return e.RPCError
+}
+
+// ClientOptions configures NewBundlerClientWithOptions. Zero values use the defaults.
+type ClientOptions struct {
+	// HTTPClient defaults to a client with a 20s timeout.
+	HTTPClient *http.Client
+	// Headers are added to every request.
+	Headers http.Header
+	// BearerToken sets "Authorization: Bearer <token>".
+	BearerToken string
+	// APIKey is sent in APIKeyHeader (default X-API-Key).
+	APIKey       string
+	APIKeyHeader string
+	// MaxResponseBytes defaults to DefaultMaxResponseBytes.
+	MaxResponseBytes int64
+	// MaxBatchSize splits BatchCall into several requests. Default DefaultMaxBatchSize.
+	MaxBatchSize int
+	// Validation, when set, runs Validate before every send and rejects operations
+	// with error findings locally. EntryPoint defaults to the send target.
+	Validation *ValidationOptions
 }
 
 // BundlerClient is a tiny JSON-RPC client for ERC-4337 calls.
+// It is safe for concurrent use by multiple goroutines.
 type BundlerClient struct {
-	endpoint   string
-	httpClient *http.Client
+	endpoint         string
+	httpClient       *http.Client
+	headers          http.Header
+	maxResponseBytes int64
+	maxBatchSize     int
+	validation       *ValidationOptions
+	nextID           atomic.Uint64
 }
 
 // NewBundlerClient creates a client for eth_sendUserOperation requests.
 func NewBundlerClient(endpoint string) *BundlerClient {
-	return &BundlerClient{
-		endpoint: endpoint,
-		httpClient: &http.Client{
-			Timeout: 20 * time.Second,
-		},
-	}
+	return NewBundlerClientWithOptions(endpoint, ClientOptions{})
 }
 
 // NewBundlerClientWithHTTPClient is useful for tests and custom transport wiring.
 func NewBundlerClientWithHTTPClient(endpoint string, httpClient *http.Client) *BundlerClient {
+	return NewBundlerClientWithOptions(endpoint, ClientOptions{HTTPClient: httpClient})
+}
+
+// NewBundlerClientWithOptions creates a client with custom headers, auth and limits.
+// opts is copied, so later changes to opts.Headers do not affect the client.
+func NewBundlerClientWithOptions(endpoint string, opts ClientOptions) *BundlerClient {
+	httpClient := opts.HTTPClient
 	if httpClient == nil {
-		httpClient = &http.Client{Timeout: 20 * time.Second}
+		httpClient = &http.Client{Timeout: defaultHTTPTimeout}
+	}
+	headers := opts.Headers.Clone()
+	if headers == nil {
+		headers = make(http.Header)
+	}
+	if opts.BearerToken != "" {
+		headers.Set("Authorization", "Bearer "+opts.BearerToken)
+	}
+	if opts.APIKey != "" {
+		name := opts.APIKeyHeader
+		if name == "" {
+			name = DefaultAPIKeyHeader
+		}
+		headers.Set(name, opts.APIKey)
+	}
+	maxBytes := opts.MaxResponseBytes
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxResponseBytes
+	}
+	maxBatch := opts.MaxBatchSize
+	if maxBatch <= 0 {
+		maxBatch = DefaultMaxBatchSize
 	}
 	return &BundlerClient{
-		endpoint:   endpoint,
-		httpClient: httpClient,
+		endpoint:         endpoint,
+		httpClient:       httpClient,
+		headers:          headers,
+		maxResponseBytes: maxBytes,
+		maxBatchSize:     maxBatch,
+		validation:       opts.Validation,
 	}
 }
 
 // BuildSendUserOperationRequest returns JSON payload for eth_sendUserOperation.
+// It rejects canonical EntryPoints that expect a newer operation shape.
 func BuildSendUserOperationRequest(op UserOperation, entryPoint common.Address) ([]byte, error) {
-	if err := op.ValidateBasic(); err != nil {
+	if err := validateSendUserOperation(op, entryPoint); err != nil {
+		return nil, err
+	}
+	return buildRPCRequest(1, "eth_sendUserOperation", op, entryPoint)
+}
+
+// BuildSendUserOperationV07Request returns JSON payload for eth_sendUserOperation
+// against a v0.7 or v0.8 EntryPoint. Operations carrying eip7702Auth require v0.8.
+func BuildSendUserOperationV07Request(op UserOperationV07, entryPoint common.Address) ([]byte, error) {
+	if err := validateSendUserOperationV07(op, entryPoint); err != nil {
 		return nil, err
 	}
+	return buildRPCRequest(1, "eth_sendUserOperation", op, entryPoint)
+}
+
+func validateSendUserOperation(op UserOperation, entryPoint common.Address) error {
+	if err := op.ValidateBasic(); err != nil {
+		return err
+	}
+	return checkEntryPoint(entryPoint, EntryPointV06)
+}
+
+func validateSendUserOperationV07(op UserOperationV07, entryPoint common.Address) error {
+	if err := op.ValidateBasic(); err != nil {
+		return err
+	}
+	allowed := []EntryPointVersion{EntryPointV07, EntryPointV08}
+	if op.EIP7702Auth != nil {
+		allowed = []EntryPointVersion{EntryPointV08}
+	}
+	return checkEntryPoint(entryPoint, allowed...)
+}
+
+func buildRPCRequest(id uint64, method string, params ...any) ([]byte, error) {
+	if params == nil {
+		params = []any{}
+	}
 	body := rpcRequest{
 		JSONRPC: "2.0",
-		ID:      1,
-		Method:  "eth_sendUserOperation",
-		Params:  []any{op, entryPoint},
+		ID:      id,
+		Method:  method,
+		Params:  params,
 	}
 	enc, err := json.MarshalIndent(body, "", "  ")
 	if err != nil {
@@ -78,33 +208,149 @@ func BuildSendUserOperationRequest(op UserOperation, entryPoint common.Address)
 
 // SendUserOperation sends one user operation and returns the userOp hash.
 func (c *BundlerClient) SendUserOperation(ctx context.Context, op UserOperation, entryPoint common.Address) (common.Hash, error) {
-	payload, err := BuildSendUserOperationRequest(op, entryPoint)
-	if err != nil {
+	if err := validateSendUserOperation(op, entryPoint); err != nil {
+		return common.Hash{}, err
+	}
+	if err := c.validate(op, entryPoint); err != nil {
+		return common.Hash{}, err
+	}
+	var hash common.Hash
+	if err := c.Call(ctx, &hash, "eth_sendUserOperation", op, entryPoint); err != nil {
 		return common.Hash{}, err
 	}
+	return hash, nil
+}
 
+// SendUserOperationV07 sends one v0.7-shaped user operation and returns the userOp hash.
+func (c *BundlerClient) SendUserOperationV07(ctx context.Context, op UserOperationV07, entryPoint common.Address) (common.Hash, error) {
+	if err := validateSendUserOperationV07(op, entryPoint); err != nil {
+		return common.Hash{}, err
+	}
+	if err := c.validate(op, entryPoint); err != nil {
+		return common.Hash{}, err
+	}
+	var hash common.Hash
+	if err := c.Call(ctx, &hash, "eth_sendUserOperation", op, entryPoint); err != nil {
+		return common.Hash{}, err
+	}
+	return hash, nil
+}
+
+// validate runs the configured deep validation, if any, with EntryPoint defaulted to entryPoint.
+func (c *BundlerClient) validate(op interface {
+	Validate(ValidationOptions) Findings
+}, entryPoint common.Address) error {
+	if c.validation == nil {
+		return nil
+	}
+	opts := *c.validation
+	if opts.EntryPoint == (common.Address{}) {
+		opts.EntryPoint = entryPoint
+	}
+	return op.Validate(opts).Err()
+}
+
+// Call performs one JSON-RPC call and decodes the result into result.
+// A nil result discards the response value. Every call gets a fresh request ID
+// and the response must echo it.
+func (c *BundlerClient) Call(ctx context.Context, result any, method string, params ...any) error {
+	id := c.nextID.Add(1)
+	payload, err := buildRPCRequest(id, method, params...)
+	if err != nil {
+		return err
+	}
+	body, err := c.post(ctx, payload)
+	if err != nil {
+		return err
+	}
+	var rpcResp rpcResponse
+	if err := json.Unmarshal(body, &rpcResp); err != nil {
+		return fmt.Errorf("%w: %v: body %q", ErrInvalidResponse, err, truncateBody(body))
+	}
+	if rpcResp.Error != nil && isNullID(rpcResp.ID) {
+		return rpcResp.Error
+	}
+	if !idMatches(rpcResp.ID, id) {
+		return fmt.Errorf("%w: response id %s does not match request id %d", ErrInvalidResponse, rpcResp.ID, id)
+	}
+	return decodeResult(rpcResp, result)
+}
+
+func decodeResult(rpcResp rpcResponse, result any) error {
+	if rpcResp.Error != nil {
+		return rpcResp.Error
+	}
+	if result == nil {
+		return nil
+	}
+	if len(rpcResp.Result) == 0 {
+		rpcResp.Result = json.RawMessage("null")
+	}
+	if err := json.Unmarshal(rpcResp.Result, result); err != nil {
+		return fmt.Errorf("decode result: %w", err)
+	}
+	return nil
+}
+
+// post sends payload and returns the response body of a 2xx response.
+func (c *BundlerClient) post(ctx context.Context, payload []byte) ([]byte, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(payload))
 	if err != nil {
-		return common.Hash{}, fmt.Errorf("create request: %w", err)
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	for name, values := range c.headers {
+		for _, v := range values {
+			req.Header.Add(name, v)
+		}
 	}
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return common.Hash{}, fmt.Errorf("post request: %w", err)
+		return nil, fmt.Errorf("post request: %w", err)
 	}
 	defer resp.Body.Close()
 
-	var rpcResp rpcResponse
-	if err := json.NewDecoder(resp.Body).Decode(&rpcResp); err != nil {
-		return common.Hash{}, fmt.Errorf("decode rpc response: %w", err)
+	body, err := io.ReadAll(io.LimitReader(resp.Body, c.maxResponseBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
 	}
-	if rpcResp.Error != nil {
-		return common.Hash{}, fmt.Errorf("rpc error %d: %s", rpcResp.Error.Code, rpcResp.Error.Message)
+	if int64(len(body)) > c.maxResponseBytes {
+		return nil, fmt.Errorf("%w: more than %d bytes", ErrResponseTooLarge, c.maxResponseBytes)
 	}
-	var hash common.Hash
-	if err := json.Unmarshal(rpcResp.Result, &hash); err != nil {
-		return common.Hash{}, fmt.Errorf("decode result hash: %w", err)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		httpErr := &HTTPError{StatusCode: resp.StatusCode, Status: resp.Status, Body: truncateBody(body)}
+		var rpcResp rpcResponse
+		if json.Unmarshal(body, &rpcResp) == nil && rpcResp.Error != nil {
+			httpErr.RPCError = rpcResp.Error
+		}
+		return nil, httpErr
 	}
-	return hash, nil
+	return body, nil
+}
+
+func idMatches(raw json.RawMessage, id uint64) bool {
+	return responseID(raw) == strconv.FormatUint(id, 10)
+}
+
+// responseID normalises a numeric or string response ID for comparison.
+func responseID(raw json.RawMessage) string {
+	text := strings.TrimSpace(string(raw))
+	if unquoted, err := strconv.Unquote(text); err == nil {
+		text = unquoted
+	}
+	return text
+}
+
+func isNullID(raw json.RawMessage) bool {
+	text := strings.TrimSpace(string(raw))
+	return text == "" || text == "null"
+}
+
+func truncateBody(body []byte) []byte {
+	if len(body) > httpErrorBodyLimit {
+		body = body[:httpErrorBodyLimit]
+	}
+	return append([]byte(nil), body...)
 }
@@ -3,10 +3,13 @@ package userop_test
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"math/big"
 	"net/http"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/eipcodelab/eip7702-go/pkg/userop"
@@ -42,6 +45,22 @@ func TestBuildSendUserOperationRequest(t *testing.T) {
 	}
 }
 
+func TestBuildSendUserOperationRequestChecksEntryPointVersion(t *testing.T) {
+	if _, err := userop.BuildSendUserOperationRequest(makeUserOp(), userop.EntryPointV07Address); !errors.Is(err, userop.ErrEntryPointVersion) {
+		t.Fatalf("expected version error for v0.6 op on v0.7 entry point, got %v", err)
+	}
+	if _, err := userop.BuildSendUserOperationV07Request(makeUserOpV07(), userop.EntryPointV06Address); !errors.Is(err, userop.ErrEntryPointVersion) {
+		t.Fatalf("expected version error for v0.7 op on v0.6 entry point, got %v", err)
+	}
+	payload, err := userop.BuildSendUserOperationV07Request(makeUserOpV07(), userop.EntryPointV07Address)
+	if err != nil {
+		t.Fatalf("build v0.7 request: %v", err)
+	}
+	if !strings.Contains(string(payload), `"factory"`) || strings.Contains(string(payload), `"initCode"`) {
+		t.Fatalf("unexpected v0.7 payload: %s", payload)
+	}
+}
+
 func TestSendUserOperation(t *testing.T) {
 	client := userop.NewBundlerClientWithHTTPClient(
 		"https://bundler.example",
@@ -77,6 +96,133 @@ func TestSendUserOperation(t *testing.T) {
 	}
 }
 
+func TestBundlerClientHeadersAndAuth(t *testing.T) {
+	var got http.Header
+	client := userop.NewBundlerClientWithOptions("https://bundler.example", userop.ClientOptions{
+		HTTPClient: &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			got = req.Header.Clone()
+			return jsonResponse(http.StatusOK, `{"jsonrpc":"2.0","id":1,"result":"0x1"}`), nil
+		})},
+		Headers:     http.Header{"X-Team": {"payments"}},
+		BearerToken: "secret",
+		APIKey:      "key-1",
+	})
+	if err := client.Call(context.Background(), nil, "eth_chainId"); err != nil {
+		t.Fatalf("Call() error = %v", err)
+	}
+	if got.Get("Authorization") != "Bearer secret" || got.Get(userop.DefaultAPIKeyHeader) != "key-1" || got.Get("X-Team") != "payments" {
+		t.Fatalf("headers = %v", got)
+	}
+}
+
+func TestBundlerClientResponseErrors(t *testing.T) {
+	cases := []struct {
+		name   string
+		status int
+		body   string
+		check  func(error) bool
+	}{
+		{
+			name:   "http status",
+			status: http.StatusBadGateway,
+			body:   "<html>bad gateway</html>",
+			check: func(err error) bool {
+				var httpErr *userop.HTTPError
+				return errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusBadGateway && httpErr.RPCError == nil
+			},
+		},
+		{
+			name:   "http status with rpc error",
+			status: http.StatusBadRequest,
+			body:   `{"jsonrpc":"2.0","id":1,"error":{"code":-32507,"message":"bad sig"}}`,
+			check: func(err error) bool {
+				var httpErr *userop.HTTPError
+				return errors.As(err, &httpErr) && errors.Is(err, userop.ErrInvalidSignature)
+			},
+		},
+		{
+			name:   "non json",
+			status: http.StatusOK,
+			body:   "not json",
+			check:  func(err error) bool { return errors.Is(err, userop.ErrInvalidResponse) },
+		},
+		{
+			name:   "id mismatch",
+			status: http.StatusOK,
+			body:   `{"jsonrpc":"2.0","id":7,"result":"0x1"}`,
+			check:  func(err error) bool { return errors.Is(err, userop.ErrInvalidResponse) },
+		},
+		{
+			name:   "too large",
+			status: http.StatusOK,
+			body:   `{"jsonrpc":"2.0","id":1,"result":"` + strings.Repeat("a", 256) + `"}`,
+			check:  func(err error) bool { return errors.Is(err, userop.ErrResponseTooLarge) },
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := userop.NewBundlerClientWithOptions("https://bundler.example", userop.ClientOptions{
+				HTTPClient: &http.Client{Transport: roundTripFunc(func(*http.Request) (*http.Response, error) {
+					return jsonResponse(tc.status, tc.body), nil
+				})},
+				MaxResponseBytes: 128,
+			})
+			err := client.Call(context.Background(), nil, "eth_chainId")
+			if err == nil || !tc.check(err) {
+				t.Fatalf("Call() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestBundlerClientConcurrentCalls(t *testing.T) {
+	var mu sync.Mutex
+	seen := make(map[string]bool)
+	srv := newRPCServer(t, func(call rpcCall) (any, map[string]any) {
+		mu.Lock()
+		defer mu.Unlock()
+		if seen[string(call.ID)] {
+			return nil, map[string]any{"code": -32600, "message": "duplicate id " + string(call.ID)}
+		}
+		seen[string(call.ID)] = true
+		var n int
+		_ = json.Unmarshal(call.Params[0], &n)
+		return n * 2, nil
+	})
+	client := userop.NewBundlerClient(srv.URL)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 32)
+	for i := 0; i < 32; i++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			var got int
+			if err := client.Call(context.Background(), &got, "test_double", n); err != nil {
+				errs <- err
+				return
+			}
+			if got != n*2 {
+				errs <- fmt.Errorf("test_double(%d) = %d", n, got)
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func jsonResponse(status int, body string) *http.Response {
+	return &http.Response{
+		StatusCode: status,
+		Status:     fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}
+}
+
 type roundTripFunc func(*http.Request) (*http.Response, error)
 
 func (fn roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
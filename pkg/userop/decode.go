@@ -0,0 +1,395 @@
+package userop
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// Bundlers return operations in slightly different shapes. The decoders below accept
+// numbers as hex strings, decimal strings or JSON numbers, field names in any case,
+// and treat null, "" and "0x" as absent. Factory and paymaster set to the zero address
+// also count as absent.
+
+// DecodedUserOperation is an operation decoded in whichever shape it was sent.
+type DecodedUserOperation struct {
+	Version EntryPointVersion
+	// V06 is set for EntryPointV06 operations and V07 for v0.7 and v0.8 operations.
+	V06 *UserOperation
+	V07 *UserOperationV07
+}
+
+// DecodeUserOperation decodes a JSON operation in the shape of entryPoint, or in
+// the shape its fields indicate when entryPoint is not a canonical deployment.
+func DecodeUserOperation(data []byte, entryPoint common.Address) (*DecodedUserOperation, error) {
+	version, ok := EntryPointVersionOf(entryPoint)
+	if !ok {
+		var err error
+		if version, err = DetectUserOperationVersion(data); err != nil {
+			return nil, err
+		}
+	}
+	out := &DecodedUserOperation{Version: version}
+	if version == EntryPointV06 {
+		out.V06 = new(UserOperation)
+		return out, json.Unmarshal(data, out.V06)
+	}
+	out.V07 = new(UserOperationV07)
+	return out, json.Unmarshal(data, out.V07)
+}
+
+// DetectUserOperationVersion reports the shape of a JSON operation: EntryPointV08 with
+// eip7702Auth, EntryPointV07 with factory, paymaster or packed gas fields, EntryPointV06
+// with initCode or paymasterAndData, and EntryPointV07 when none of these are present.
+func DetectUserOperationVersion(data []byte) (EntryPointVersion, error) {
+	fields, err := parseFields(data)
+	if err != nil {
+		return EntryPointUnknown, err
+	}
+	version, _ := fields.version()
+	return version, nil
+}
+
+// Decode decodes UserOperation in the shape of EntryPoint.
+func (r *UserOperationByHash) Decode() (*DecodedUserOperation, error) {
+	return DecodeUserOperation(r.UserOperation, r.EntryPoint)
+}
+
+// UnmarshalJSON accepts lenient block fields. Empty and zero hashes are treated as
+// absent, as some bundlers report them for operations still in the mempool.
+func (r *UserOperationByHash) UnmarshalJSON(data []byte) error {
+	fields, err := parseFields(data)
+	if err != nil {
+		return err
+	}
+	d := &fieldDecoder{fields: fields}
+	out := UserOperationByHash{
+		UserOperation:   append(json.RawMessage(nil), fields["useroperation"]...),
+		BlockNumber:     d.big("blockNumber"),
+		BlockHash:       d.hash("blockHash"),
+		TransactionHash: d.hash("transactionHash"),
+	}
+	if entryPoint := d.address("entryPoint"); entryPoint != nil {
+		out.EntryPoint = *entryPoint
+	}
+	if d.err != nil {
+		return d.err
+	}
+	*r = out
+	return nil
+}
+
+// UnmarshalJSON decodes the v0.6 shape leniently. Operations carrying v0.7-only
+// fields are rejected with ErrEntryPointVersion.
+func (u *UserOperation) UnmarshalJSON(data []byte) error {
+	fields, err := parseFields(data)
+	if err != nil {
+		return err
+	}
+	if version, explicit := fields.version(); explicit && version != EntryPointV06 {
+		return fmt.Errorf("%w: %s operation decoded as v0.6", ErrEntryPointVersion, version)
+	}
+	d := &fieldDecoder{fields: fields}
+	out := UserOperation{
+		Nonce:                d.big("nonce"),
+		InitCode:             d.bytes("initCode"),
+		CallData:             d.bytes("callData"),
+		CallGasLimit:         d.uint64("callGasLimit"),
+		VerificationGasLimit: d.uint64("verificationGasLimit"),
+		PreVerificationGas:   d.uint64("preVerificationGas"),
+		MaxFeePerGas:         d.big("maxFeePerGas"),
+		MaxPriorityFeePerGas: d.big("maxPriorityFeePerGas"),
+		PaymasterAndData:     d.bytes("paymasterAndData"),
+		Signature:            d.bytes("signature"),
+	}
+	if sender := d.address("sender"); sender != nil {
+		out.Sender = *sender
+	}
+	if d.err != nil {
+		return d.err
+	}
+	*u = out
+	return nil
+}
+
+// UnmarshalJSON decodes the v0.7 shape leniently, including the packed form with
+// initCode, accountGasLimits, gasFees and paymasterAndData. Operations in the v0.6
+// shape are rejected with ErrEntryPointVersion.
+func (u *UserOperationV07) UnmarshalJSON(data []byte) error {
+	fields, err := parseFields(data)
+	if err != nil {
+		return err
+	}
+	if version, explicit := fields.version(); explicit && version == EntryPointV06 {
+		return fmt.Errorf("%w: v0.6 operation decoded as v0.7", ErrEntryPointVersion)
+	}
+	d := &fieldDecoder{fields: fields}
+	var out UserOperationV07
+	if fields.has("accountGasLimits", "gasFees") {
+		out, err = d.packed()
+		if err != nil {
+			return err
+		}
+	} else {
+		out = UserOperationV07{
+			Nonce:                         d.big("nonce"),
+			Factory:                       d.factory("factory"),
+			FactoryData:                   d.bytes("factoryData"),
+			CallData:                      d.bytes("callData"),
+			CallGasLimit:                  d.uint64("callGasLimit"),
+			VerificationGasLimit:          d.uint64("verificationGasLimit"),
+			PreVerificationGas:            d.uint64("preVerificationGas"),
+			MaxFeePerGas:                  d.big("maxFeePerGas"),
+			MaxPriorityFeePerGas:          d.big("maxPriorityFeePerGas"),
+			Paymaster:                     d.optionalAddress("paymaster"),
+			PaymasterVerificationGasLimit: d.uint64("paymasterVerificationGasLimit"),
+			PaymasterPostOpGasLimit:       d.uint64("paymasterPostOpGasLimit"),
+			PaymasterData:                 d.bytes("paymasterData"),
+			Signature:                     d.bytes("signature"),
+		}
+		if sender := d.address("sender"); sender != nil {
+			out.Sender = *sender
+		}
+	}
+	out.EIP7702Auth = d.eip7702Auth("eip7702Auth")
+	if d.err != nil {
+		return d.err
+	}
+	*u = out
+	return nil
+}
+
+// jsonFields holds the non-null members of a JSON object by lower-case name.
+type jsonFields map[string]json.RawMessage
+
+func parseFields(data []byte) (jsonFields, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	if raw == nil {
+		return nil, errors.New("expected a JSON object")
+	}
+	fields := make(jsonFields, len(raw))
+	for name, value := range raw {
+		if string(value) != "null" {
+			fields[strings.ToLower(name)] = value
+		}
+	}
+	return fields, nil
+}
+
+func (f jsonFields) has(names ...string) bool {
+	for _, name := range names {
+		if _, ok := f[strings.ToLower(name)]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// version returns the shape indicated by the fields and whether any field
+// was specific to it.
+func (f jsonFields) version() (EntryPointVersion, bool) {
+	switch {
+	case f.has("eip7702Auth"):
+		return EntryPointV08, true
+	case f.has("accountGasLimits", "gasFees", "factory", "factoryData", "paymaster",
+		"paymasterData", "paymasterVerificationGasLimit", "paymasterPostOpGasLimit"):
+		return EntryPointV07, true
+	case f.has("initCode", "paymasterAndData"):
+		return EntryPointV06, true
+	default:
+		return EntryPointV07, false
+	}
+}
+
+// fieldDecoder reads lenient fields and keeps the first error.
+type fieldDecoder struct {
+	fields jsonFields
+	err    error
+}
+
+func (d *fieldDecoder) fail(name string, err error) {
+	if d.err == nil {
+		d.err = fmt.Errorf("%s: %w", name, err)
+	}
+}
+
+func (d *fieldDecoder) big(name string) *hexutil.Big {
+	v, err := parseBigQuantity(d.fields[strings.ToLower(name)])
+	if err != nil {
+		d.fail(name, err)
+	}
+	return (*hexutil.Big)(v)
+}
+
+func (d *fieldDecoder) uint64(name string) hexutil.Uint64 {
+	v, err := parseQuantity(d.fields[strings.ToLower(name)])
+	if err != nil {
+		d.fail(name, err)
+	}
+	return hexutil.Uint64(v)
+}
+
+func (d *fieldDecoder) bytes(name string) hexutil.Bytes {
+	raw, ok := d.fields[strings.ToLower(name)]
+	if !ok {
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		d.fail(name, errors.New("expected a hex string"))
+		return nil
+	}
+	out, err := hex.DecodeString(trimHexPrefix(strings.TrimSpace(s)))
+	if err != nil {
+		d.fail(name, err)
+		return nil
+	}
+	return out
+}
+
+func (d *fieldDecoder) address(name string) *common.Address {
+	data := d.bytes(name)
+	if len(data) == 0 {
+		return nil
+	}
+	if len(data) != common.AddressLength {
+		d.fail(name, fmt.Errorf("address must be %d bytes, got %d", common.AddressLength, len(data)))
+		return nil
+	}
+	addr := common.BytesToAddress(data)
+	return &addr
+}
+
+// optionalAddress is address with the zero address treated as absent.
+func (d *fieldDecoder) optionalAddress(name string) *common.Address {
+	addr := d.address(name)
+	if addr == nil || *addr == (common.Address{}) {
+		return nil
+	}
+	return addr
+}
+
+// factory is optionalAddress that also accepts the short 0x7702 marker.
+func (d *fieldDecoder) factory(name string) *common.Address {
+	if data := d.bytes(name); isShortEIP7702Marker(data) {
+		marker := EIP7702FactoryMarker
+		return &marker
+	}
+	return d.optionalAddress(name)
+}
+
+// isShortEIP7702Marker reports whether data is the unpadded 0x7702 marker
+// some wallets send in place of the 20-byte form.
+func isShortEIP7702Marker(data []byte) bool {
+	return bytes.Equal(data, EIP7702FactoryMarker[:2])
+}
+
+func (d *fieldDecoder) hash(name string) *common.Hash {
+	data := d.bytes(name)
+	if len(data) == 0 {
+		return nil
+	}
+	if len(data) != common.HashLength {
+		d.fail(name, fmt.Errorf("hash must be %d bytes, got %d", common.HashLength, len(data)))
+		return nil
+	}
+	hash := common.BytesToHash(data)
+	if hash == (common.Hash{}) {
+		return nil
+	}
+	return &hash
+}
+
+// packed decodes the on-chain PackedUserOperation form some bundlers return for v0.7.
+func (d *fieldDecoder) packed() (UserOperationV07, error) {
+	p := PackedUserOperation{
+		Nonce:            d.big("nonce").ToInt(),
+		InitCode:         d.bytes("initCode"),
+		CallData:         d.bytes("callData"),
+		PaymasterAndData: d.bytes("paymasterAndData"),
+		Signature:        d.bytes("signature"),
+	}
+	if sender := d.address("sender"); sender != nil {
+		p.Sender = *sender
+	}
+	if pvg := d.big("preVerificationGas"); pvg != nil {
+		p.PreVerificationGas = pvg.ToInt()
+	} else {
+		p.PreVerificationGas = new(big.Int)
+	}
+	if isShortEIP7702Marker(p.InitCode) {
+		p.InitCode = EIP7702FactoryMarker.Bytes()
+	}
+	if !d.fields.has("accountGasLimits") || !d.fields.has("gasFees") {
+		return UserOperationV07{}, errors.New("packed user operation needs both accountGasLimits and gasFees")
+	}
+	p.AccountGasLimits = d.word("accountGasLimits")
+	p.GasFees = d.word("gasFees")
+	if d.err != nil {
+		return UserOperationV07{}, d.err
+	}
+	if p.Nonce == nil {
+		p.Nonce = new(big.Int)
+	}
+	op, err := UnpackUserOperation(p)
+	if err != nil {
+		return UserOperationV07{}, fmt.Errorf("packed user operation: %w", err)
+	}
+	return op, nil
+}
+
+func (d *fieldDecoder) word(name string) [32]byte {
+	var out [32]byte
+	data := d.bytes(name)
+	if len(data) > 32 {
+		d.fail(name, fmt.Errorf("expected 32 bytes, got %d", len(data)))
+		return out
+	}
+	copy(out[32-len(data):], data)
+	return out
+}
+
+// eip7702Auth decodes an authorization tuple, accepting v (27/28) in place of yParity.
+func (d *fieldDecoder) eip7702Auth(name string) *EIP7702Auth {
+	raw, ok := d.fields[strings.ToLower(name)]
+	if !ok || d.err != nil {
+		return nil
+	}
+	fields, err := parseFields(raw)
+	if err != nil {
+		d.fail(name, err)
+		return nil
+	}
+	sub := &fieldDecoder{fields: fields}
+	auth := &EIP7702Auth{
+		ChainID: sub.big("chainId"),
+		Nonce:   sub.uint64("nonce"),
+		R:       sub.big("r"),
+		S:       sub.big("s"),
+	}
+	if addr := sub.address("address"); addr != nil {
+		auth.Address = *addr
+	}
+	if fields.has("yParity") {
+		auth.YParity = sub.uint64("yParity")
+	} else if v := sub.uint64("v"); v >= 27 {
+		auth.YParity = v - 27
+	} else {
+		auth.YParity = v
+	}
+	if sub.err != nil {
+		d.fail(name, sub.err)
+		return nil
+	}
+	return auth
+}
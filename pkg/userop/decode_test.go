@@ -0,0 +1,256 @@
+package userop_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/eipcodelab/eip7702-go/pkg/userop"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Operations as returned by eth_getUserOperationByHash from different bundlers.
+const (
+	// Canonical v0.6 with empty initCode and paymasterAndData.
+	capturedV06 = `{
+		"sender": "0x000000000000000000000000000000000000dEaD",
+		"nonce": "0x1",
+		"initCode": "0x",
+		"callData": "0x0102",
+		"callGasLimit": "0x186a0",
+		"verificationGasLimit": "0x3d090",
+		"preVerificationGas": "0xc350",
+		"maxFeePerGas": "0x6fc23ac00",
+		"maxPriorityFeePerGas": "0x77359400",
+		"paymasterAndData": "0x",
+		"signature": "0xaabb"
+	}`
+	// v0.6 with decimal strings, JSON numbers and no paymasterAndData.
+	capturedV06Decimal = `{
+		"sender": "0x000000000000000000000000000000000000dead",
+		"nonce": "1",
+		"initCode": "",
+		"callData": "0x0102",
+		"callGasLimit": 100000,
+		"verificationGasLimit": "250000",
+		"preVerificationGas": 50000,
+		"maxFeePerGas": "30000000000",
+		"maxPriorityFeePerGas": "2000000000",
+		"signature": "0xAABB"
+	}`
+	// v0.7 with nulls and a zero-address paymaster for the unused optional fields.
+	capturedV07Nulls = `{
+		"sender": "0x000000000000000000000000000000000000dead",
+		"nonce": "0x7",
+		"factory": null,
+		"factoryData": null,
+		"callData": "0x0102",
+		"callGasLimit": "0x186a0",
+		"verificationGasLimit": "0x3d090",
+		"preVerificationGas": "0xc350",
+		"maxFeePerGas": "0x6fc23ac00",
+		"maxPriorityFeePerGas": "0x77359400",
+		"paymaster": "0x0000000000000000000000000000000000000000",
+		"paymasterVerificationGasLimit": "0x",
+		"paymasterPostOpGasLimit": null,
+		"paymasterData": "0x",
+		"signature": "0xaabb"
+	}`
+	// v0.8 with an authorization signed as v instead of yParity.
+	capturedV08 = `{
+		"sender": "0x000000000000000000000000000000000000dead",
+		"nonce": "0x0",
+		"factory": "0x7702000000000000000000000000000000000000",
+		"callData": "0x0102",
+		"callGasLimit": "0x186a0",
+		"verificationGasLimit": "0x3d090",
+		"preVerificationGas": "0xc350",
+		"maxFeePerGas": "0x6fc23ac00",
+		"maxPriorityFeePerGas": "0x77359400",
+		"signature": "0xaabb",
+		"eip7702Auth": {
+			"chainId": "8453",
+			"address": "0x1111111111111111111111111111111111111111",
+			"nonce": 3,
+			"v": "0x1c",
+			"r": "0x01",
+			"s": "0x02"
+		}
+	}`
+)
+
+// capturedV08ShortMarker is capturedV08 with the unpadded 0x7702 factory marker.
+var capturedV08ShortMarker = strings.Replace(capturedV08, "0x7702000000000000000000000000000000000000", "0x7702", 1)
+
+func TestUserOperationUnmarshalV06(t *testing.T) {
+	want, _ := json.Marshal(makeUserOp())
+	for name, data := range map[string]string{"canonical": capturedV06, "decimal": capturedV06Decimal} {
+		var op userop.UserOperation
+		if err := json.Unmarshal([]byte(data), &op); err != nil {
+			t.Fatalf("%s: Unmarshal() error = %v", name, err)
+		}
+		got, _ := json.Marshal(op)
+		if !bytes.Equal(got, want) {
+			t.Fatalf("%s: round trip = %s, want %s", name, got, want)
+		}
+	}
+}
+
+func TestUserOperationV07UnmarshalRoundTrip(t *testing.T) {
+	op := makeUserOpV07()
+	data, _ := json.Marshal(op)
+	var decoded userop.UserOperationV07
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if again, _ := json.Marshal(decoded); !bytes.Equal(again, data) {
+		t.Fatalf("round trip = %s, want %s", again, data)
+	}
+
+	var bare userop.UserOperationV07
+	if err := json.Unmarshal([]byte(capturedV07Nulls), &bare); err != nil {
+		t.Fatalf("Unmarshal(nulls) error = %v", err)
+	}
+	if bare.Factory != nil || bare.Paymaster != nil || bare.CallGasLimit != 100_000 || bare.Nonce.ToInt().Int64() != 7 {
+		t.Fatalf("decoded = %+v", bare)
+	}
+	if err := bare.ValidateBasic(); err != nil {
+		t.Fatalf("ValidateBasic() = %v", err)
+	}
+}
+
+func TestUserOperationV07UnmarshalPacked(t *testing.T) {
+	op := makeUserOpV07()
+	p, err := op.Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	packed, _ := json.Marshal(map[string]any{
+		"sender":             p.Sender,
+		"nonce":              "7",
+		"initCode":           "0x" + common.Bytes2Hex(p.InitCode),
+		"callData":           "0x" + common.Bytes2Hex(p.CallData),
+		"accountGasLimits":   "0x" + common.Bytes2Hex(p.AccountGasLimits[:]),
+		"preVerificationGas": "0xc350",
+		"gasFees":            "0x" + common.Bytes2Hex(p.GasFees[:]),
+		"paymasterAndData":   "0x" + common.Bytes2Hex(p.PaymasterAndData),
+		"signature":          "0xaabb",
+	})
+	var decoded userop.UserOperationV07
+	if err := json.Unmarshal(packed, &decoded); err != nil {
+		t.Fatalf("Unmarshal(packed) error = %v", err)
+	}
+	got, _ := json.Marshal(decoded)
+	want, _ := json.Marshal(op)
+	if !bytes.Equal(got, want) {
+		t.Fatalf("packed = %s, want %s", got, want)
+	}
+}
+
+func TestUserOperationUnmarshalEIP7702(t *testing.T) {
+	for name, data := range map[string]string{"padded marker": capturedV08, "short marker": capturedV08ShortMarker} {
+		var op userop.UserOperationV07
+		if err := json.Unmarshal([]byte(data), &op); err != nil {
+			t.Fatalf("%s: Unmarshal() error = %v", name, err)
+		}
+		auth := op.EIP7702Auth
+		if auth == nil || auth.ChainID.ToInt().Int64() != 8453 || auth.Nonce != 3 || auth.YParity != 1 ||
+			auth.Address != common.HexToAddress("0x1111111111111111111111111111111111111111") {
+			t.Fatalf("%s: eip7702Auth = %+v", name, auth)
+		}
+		if op.Factory == nil || *op.Factory != userop.EIP7702FactoryMarker {
+			t.Fatalf("%s: factory = %v", name, op.Factory)
+		}
+	}
+
+	var packed userop.UserOperationV07
+	data := `{"sender": "0x000000000000000000000000000000000000dead", "nonce": "0x0", "initCode": "0x7702",
+		"callData": "0x", "accountGasLimits": "0x01", "preVerificationGas": "0x1", "gasFees": "0x01"}`
+	if err := json.Unmarshal([]byte(data), &packed); err != nil {
+		t.Fatalf("Unmarshal(packed short marker) error = %v", err)
+	}
+	if packed.Factory == nil || *packed.Factory != userop.EIP7702FactoryMarker {
+		t.Fatalf("packed factory = %v", packed.Factory)
+	}
+}
+
+func TestUserOperationUnmarshalErrors(t *testing.T) {
+	var v06 userop.UserOperation
+	if err := json.Unmarshal([]byte(capturedV07Nulls), &v06); !errors.Is(err, userop.ErrEntryPointVersion) {
+		t.Fatalf("v0.7 into v0.6 error = %v", err)
+	}
+	var v07 userop.UserOperationV07
+	if err := json.Unmarshal([]byte(capturedV06), &v07); !errors.Is(err, userop.ErrEntryPointVersion) {
+		t.Fatalf("v0.6 into v0.7 error = %v", err)
+	}
+
+	tests := map[string]string{
+		"callData":     `{"callData": "0xzz"}`,
+		"nonce":        `{"nonce": "-1"}`,
+		"callGasLimit": `{"callGasLimit": "0x10000000000000000"}`,
+		"sender":       `{"sender": "0x1234"}`,
+	}
+	for _, data := range []string{`{"accountGasLimits": "0x01"}`, `{"gasFees": "0x01"}`} {
+		if err := json.Unmarshal([]byte(data), &v07); err == nil || !strings.Contains(err.Error(), "accountGasLimits and gasFees") {
+			t.Fatalf("%s error = %v", data, err)
+		}
+	}
+	for field, data := range tests {
+		err := json.Unmarshal([]byte(data), &v06)
+		if err == nil || !strings.HasPrefix(err.Error(), field+":") {
+			t.Fatalf("%s error = %v", field, err)
+		}
+	}
+}
+
+func TestDecodeUserOperation(t *testing.T) {
+	tests := []struct {
+		name       string
+		data       string
+		entryPoint common.Address
+		want       userop.EntryPointVersion
+	}{
+		{"v0.6 by shape", capturedV06, common.Address{}, userop.EntryPointV06},
+		{"v0.7 by shape", capturedV07Nulls, common.Address{}, userop.EntryPointV07},
+		{"v0.8 by shape", capturedV08, common.Address{}, userop.EntryPointV08},
+		{"v0.6 without optional fields by entry point", capturedV06Decimal, userop.EntryPointV06Address, userop.EntryPointV06},
+		{"v0.8 by entry point", capturedV07Nulls, userop.EntryPointV08Address, userop.EntryPointV08},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := userop.DecodeUserOperation([]byte(tt.data), tt.entryPoint)
+			if err != nil {
+				t.Fatalf("DecodeUserOperation() error = %v", err)
+			}
+			if got.Version != tt.want || (got.V06 == nil) == (tt.want == userop.EntryPointV06) {
+				t.Fatalf("decoded = %+v, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetUserOperationByHashLenient(t *testing.T) {
+	srv := newRPCServer(t, func(call rpcCall) (any, map[string]any) {
+		return map[string]any{
+			"userOperation":   json.RawMessage(capturedV06Decimal),
+			"entryPoint":      userop.EntryPointV06Address,
+			"blockNumber":     16,
+			"blockHash":       "",
+			"transactionHash": "0x0000000000000000000000000000000000000000000000000000000000000000",
+		}, nil
+	})
+	byHash, err := userop.NewBundlerClient(srv.URL).GetUserOperationByHash(context.Background(), testUserOpHash)
+	if err != nil {
+		t.Fatalf("GetUserOperationByHash() error = %v", err)
+	}
+	if byHash.BlockNumber.ToInt().Int64() != 16 || byHash.BlockHash != nil || byHash.TransactionHash != nil {
+		t.Fatalf("by hash = %+v", byHash)
+	}
+	decoded, err := byHash.Decode()
+	if err != nil || decoded.V06 == nil || decoded.V06.CallGasLimit != 100_000 {
+		t.Fatalf("Decode() = %+v, %v", decoded, err)
+	}
+}
@@ -0,0 +1,123 @@
+package userop
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/eipcodelab/eip7702-go/pkg/eip7702"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// EIP7702FactoryMarker is the v0.8 initCode prefix (0x7702 right-padded to 20 bytes)
+// that marks the sender as an EIP-7702 account instead of naming a factory.
+var EIP7702FactoryMarker = common.Address{0x77, 0x02}
+
+var (
+	// ErrMissingEIP7702Delegate is returned when a 0x7702 initCode is hashed without a known delegate.
+	ErrMissingEIP7702Delegate = errors.New("eip-7702 initCode requires eip7702Auth or an explicit delegate")
+	// ErrInvalidEIP7702Auth is returned for inconsistent eip7702Auth and initCode combinations.
+	ErrInvalidEIP7702Auth = errors.New("invalid eip7702Auth")
+)
+
+// EIP7702Auth is the eip7702Auth tuple carried by v0.8 user operations in JSON-RPC form.
+type EIP7702Auth struct {
+	ChainID *hexutil.Big   `json:"chainId"`
+	Address common.Address `json:"address"`
+	Nonce   hexutil.Uint64 `json:"nonce"`
+	YParity hexutil.Uint64 `json:"yParity"`
+	R       *hexutil.Big   `json:"r"`
+	S       *hexutil.Big   `json:"s"`
+}
+
+// NewEIP7702Auth converts a signed authorization tuple into its JSON-RPC form.
+func NewEIP7702Auth(auth eip7702.Authorization) *EIP7702Auth {
+	return &EIP7702Auth{
+		ChainID: HexBig(auth.ChainID),
+		Address: auth.Address,
+		Nonce:   hexutil.Uint64(auth.Nonce),
+		YParity: hexutil.Uint64(auth.YParity),
+		R:       HexBig(auth.R),
+		S:       HexBig(auth.S),
+	}
+}
+
+// Authorization converts the JSON-RPC tuple back into an eip7702.Authorization.
+func (a EIP7702Auth) Authorization() (eip7702.Authorization, error) {
+	if a.ChainID == nil || a.R == nil || a.S == nil {
+		return eip7702.Authorization{}, fmt.Errorf("%w: chainId, r and s are required", ErrInvalidEIP7702Auth)
+	}
+	if a.YParity > 1 {
+		return eip7702.Authorization{}, eip7702.ErrInvalidYParity
+	}
+	return eip7702.Authorization{
+		ChainID: new(big.Int).Set(a.ChainID.ToInt()),
+		Address: a.Address,
+		Nonce:   uint64(a.Nonce),
+		YParity: uint8(a.YParity),
+		R:       new(big.Int).Set(a.R.ToInt()),
+		S:       new(big.Int).Set(a.S.ToInt()),
+	}, nil
+}
+
+// SetEIP7702Authorization attaches auth and marks the operation with the 0x7702 initCode,
+// so the first operation both delegates the EOA and executes. FactoryData is kept as
+// optional initialization calldata for the delegate.
+func (u *UserOperationV07) SetEIP7702Authorization(auth eip7702.Authorization) {
+	marker := EIP7702FactoryMarker
+	u.Factory = &marker
+	u.EIP7702Auth = NewEIP7702Auth(auth)
+}
+
+// IsEIP7702InitCode reports whether initCode starts with the 0x7702 marker.
+func IsEIP7702InitCode(initCode []byte) bool {
+	return len(initCode) >= 2 && bytes.Equal(padInitCodePrefix(initCode), EIP7702FactoryMarker[:])
+}
+
+// padInitCodePrefix returns the first 20 bytes of initCode, zero-padded like calldataload.
+func padInitCodePrefix(initCode []byte) []byte {
+	out := make([]byte, common.AddressLength)
+	copy(out, initCode)
+	return out
+}
+
+// eip7702InitCodeHash is the v0.8 initCode hash override: keccak(delegate || initCode[20:]).
+func eip7702InitCodeHash(initCode []byte, delegate common.Address) []byte {
+	if len(initCode) <= common.AddressLength {
+		return crypto.Keccak256(delegate.Bytes())
+	}
+	return crypto.Keccak256(delegate.Bytes(), initCode[common.AddressLength:])
+}
+
+// ValidateEIP7702 checks that eip7702Auth, initCode and sender are consistent for entryPoint.
+// The authorization must be signed by the sender for chainID (or chain 0), target a
+// non-zero delegate and be submitted to a v0.8 EntryPoint.
+func ValidateEIP7702(op UserOperationV07, entryPoint common.Address, chainID *big.Int) error {
+	if op.EIP7702Auth == nil {
+		return nil
+	}
+	if op.Factory == nil || *op.Factory != EIP7702FactoryMarker {
+		return fmt.Errorf("%w: factory must be the 0x7702 marker", ErrInvalidEIP7702Auth)
+	}
+	if err := checkEntryPoint(entryPoint, EntryPointV08); err != nil {
+		return err
+	}
+	auth, err := op.EIP7702Auth.Authorization()
+	if err != nil {
+		return err
+	}
+	if eip7702.IsClearCodeAuthorization(auth.Address) {
+		return fmt.Errorf("%w: delegate must not be the zero address", ErrInvalidEIP7702Auth)
+	}
+	authority, err := eip7702.VerifyAuthorization(auth, chainID)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidEIP7702Auth, err)
+	}
+	if authority != op.Sender {
+		return fmt.Errorf("%w: signed by %s, sender is %s", ErrInvalidEIP7702Auth, authority.Hex(), op.Sender.Hex())
+	}
+	return nil
+}
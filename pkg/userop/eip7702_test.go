@@ -0,0 +1,119 @@
+package userop_test
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/eipcodelab/eip7702-go/pkg/eip7702"
+	"github.com/eipcodelab/eip7702-go/pkg/userop"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func makeEIP7702UserOp(t *testing.T) (userop.UserOperationV07, eip7702.Authorization) {
+	t.Helper()
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("key: %v", err)
+	}
+	delegate := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	auth, err := eip7702.SignAuthorization(key, big.NewInt(1), delegate, 0)
+	if err != nil {
+		t.Fatalf("sign authorization: %v", err)
+	}
+	op := makeUserOpV07()
+	op.Sender = crypto.PubkeyToAddress(key.PublicKey)
+	op.Factory, op.FactoryData = nil, nil
+	op.SetEIP7702Authorization(auth)
+	if err := userop.SignUserOperationV07(&op, key, userop.EntryPointV08Address, big.NewInt(1), userop.SignatureRawHash); err != nil {
+		t.Fatalf("sign user op: %v", err)
+	}
+	return op, auth
+}
+
+func TestEIP7702UserOperationRequest(t *testing.T) {
+	op, auth := makeEIP7702UserOp(t)
+	if err := userop.ValidateEIP7702(op, userop.EntryPointV08Address, big.NewInt(1)); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	payload, err := userop.BuildSendUserOperationV07Request(op, userop.EntryPointV08Address)
+	if err != nil {
+		t.Fatalf("build request: %v", err)
+	}
+	for _, field := range []string{`"eip7702Auth"`, `"factory": "0x7702000000000000000000000000000000000000"`, `"yParity"`} {
+		if !strings.Contains(string(payload), field) {
+			t.Fatalf("missing %s in %s", field, payload)
+		}
+	}
+	if _, err := userop.BuildSendUserOperationV07Request(op, userop.EntryPointV07Address); !errors.Is(err, userop.ErrEntryPointVersion) {
+		t.Fatalf("expected v0.8-only error, got %v", err)
+	}
+
+	roundtrip, err := op.EIP7702Auth.Authorization()
+	if err != nil {
+		t.Fatalf("authorization: %v", err)
+	}
+	if roundtrip.Address != auth.Address || roundtrip.R.Cmp(auth.R) != 0 || roundtrip.S.Cmp(auth.S) != 0 {
+		t.Fatal("authorization did not roundtrip")
+	}
+}
+
+func TestUserOpHashV08UsesEIP7702Delegate(t *testing.T) {
+	op, auth := makeEIP7702UserOp(t)
+	entryPoint, chainID := userop.EntryPointV08Address, big.NewInt(1)
+	hash, err := userop.UserOpHashV08(op, entryPoint, chainID)
+	if err != nil {
+		t.Fatalf("hash: %v", err)
+	}
+	withDelegate, err := userop.UserOpHashV08WithDelegate(op, auth.Address, entryPoint, chainID)
+	if err != nil || withDelegate != hash {
+		t.Fatalf("explicit delegate must match eip7702Auth address: %v", err)
+	}
+	other, err := userop.UserOpHashV08WithDelegate(op, common.HexToAddress("0x02"), entryPoint, chainID)
+	if err != nil || other == hash {
+		t.Fatal("delegate must be part of the hash")
+	}
+
+	op.EIP7702Auth = nil
+	if _, err := userop.UserOpHashV08(op, entryPoint, chainID); !errors.Is(err, userop.ErrMissingEIP7702Delegate) {
+		t.Fatalf("expected missing delegate error, got %v", err)
+	}
+}
+
+func TestValidateEIP7702RejectsInconsistentOps(t *testing.T) {
+	op, _ := makeEIP7702UserOp(t)
+
+	wrongSender := op
+	wrongSender.Sender = common.HexToAddress("0x000000000000000000000000000000000000dead")
+	if err := userop.ValidateEIP7702(wrongSender, userop.EntryPointV08Address, big.NewInt(1)); !errors.Is(err, userop.ErrInvalidEIP7702Auth) {
+		t.Fatalf("expected sender mismatch, got %v", err)
+	}
+
+	wrongFactory := op
+	factory := common.HexToAddress("0x00000000000000000000000000000000000fac70")
+	wrongFactory.Factory = &factory
+	if err := wrongFactory.ValidateBasic(); !errors.Is(err, userop.ErrInvalidEIP7702Auth) {
+		t.Fatalf("expected factory marker error, got %v", err)
+	}
+
+	if err := userop.ValidateEIP7702(op, userop.EntryPointV08Address, big.NewInt(10)); !errors.Is(err, userop.ErrInvalidEIP7702Auth) {
+		t.Fatalf("expected chain mismatch, got %v", err)
+	}
+	if err := userop.ValidateEIP7702(op, userop.EntryPointV07Address, big.NewInt(1)); !errors.Is(err, userop.ErrEntryPointVersion) {
+		t.Fatalf("expected entry point version error, got %v", err)
+	}
+}
+
+func TestIsEIP7702InitCode(t *testing.T) {
+	if !userop.IsEIP7702InitCode(userop.EIP7702FactoryMarker.Bytes()) {
+		t.Fatal("marker must be detected")
+	}
+	if !userop.IsEIP7702InitCode([]byte{0x77, 0x02}) {
+		t.Fatal("short marker must be detected")
+	}
+	if userop.IsEIP7702InitCode(common.HexToAddress("0x7702").Bytes()) {
+		t.Fatal("left-padded 0x7702 address is a regular factory")
+	}
+}
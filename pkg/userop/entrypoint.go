@@ -0,0 +1,76 @@
+package userop
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// EntryPointVersion identifies the ERC-4337 EntryPoint release an operation targets.
+type EntryPointVersion int
+
+const (
+	// EntryPointUnknown is used for EntryPoint addresses that are not canonical deployments.
+	EntryPointUnknown EntryPointVersion = iota
+	// EntryPointV06 expects the UserOperation shape.
+	EntryPointV06
+	// EntryPointV07 expects the UserOperationV07 shape and PackedUserOperation on-chain.
+	EntryPointV07
+	// EntryPointV08 uses the v0.7 shape, EIP-712 userOp hashes and EIP-7702 authorizations.
+	EntryPointV08
+)
+
+var (
+	// EntryPointV06Address is the canonical v0.6 EntryPoint deployment.
+	EntryPointV06Address = common.HexToAddress("0x5FF137D4b0FDCD49DcA30c7CF57E578a026d2789")
+	// EntryPointV07Address is the canonical v0.7 EntryPoint deployment.
+	EntryPointV07Address = common.HexToAddress("0x0000000071727De22E5E9d8BAf0edAc6f37da032")
+	// EntryPointV08Address is the canonical v0.8 EntryPoint deployment.
+	EntryPointV08Address = common.HexToAddress("0x4337084D9E255Ff0702461CF8895CE9E3b5Ff108")
+
+	// ErrEntryPointVersion is returned when an operation shape does not match a known EntryPoint.
+	ErrEntryPointVersion = errors.New("user operation shape does not match entry point version")
+)
+
+// EntryPointVersionOf returns the version of a canonical EntryPoint deployment.
+func EntryPointVersionOf(entryPoint common.Address) (EntryPointVersion, bool) {
+	switch entryPoint {
+	case EntryPointV06Address:
+		return EntryPointV06, true
+	case EntryPointV07Address:
+		return EntryPointV07, true
+	case EntryPointV08Address:
+		return EntryPointV08, true
+	default:
+		return EntryPointUnknown, false
+	}
+}
+
+func (v EntryPointVersion) String() string {
+	switch v {
+	case EntryPointV06:
+		return "v0.6"
+	case EntryPointV07:
+		return "v0.7"
+	case EntryPointV08:
+		return "v0.8"
+	default:
+		return "unknown"
+	}
+}
+
+// checkEntryPoint rejects canonical EntryPoints whose version is not in allowed.
+// Unknown addresses are accepted so custom deployments keep working.
+func checkEntryPoint(entryPoint common.Address, allowed ...EntryPointVersion) error {
+	version, ok := EntryPointVersionOf(entryPoint)
+	if !ok {
+		return nil
+	}
+	for _, v := range allowed {
+		if v == version {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s is %s", ErrEntryPointVersion, entryPoint.Hex(), version)
+}
@@ -0,0 +1,230 @@
+package userop
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/eipcodelab/eip7702-go/pkg/batching"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// ERC-4337 bundler JSON-RPC error codes (ERC-7769).
+const (
+	CodeInvalidParams         = -32602
+	CodeValidationRejected    = -32500
+	CodePaymasterRejected     = -32501
+	CodeBannedOpcode          = -32502
+	CodeTimeRange             = -32503
+	CodeThrottled             = -32504
+	CodeInsufficientStake     = -32505
+	CodeUnsupportedAggregator = -32506
+	CodeInvalidSignature      = -32507
+	CodeExecutionReverted     = -32521
+)
+
+// Sentinels matched by RPCError through errors.Is.
+var (
+	ErrInvalidParams         = errors.New("invalid user operation params")
+	ErrValidationRejected    = errors.New("user operation rejected by entry point validation")
+	ErrPaymasterRejected     = errors.New("user operation rejected by paymaster")
+	ErrBannedOpcode          = errors.New("user operation uses a banned opcode")
+	ErrTimeRange             = errors.New("user operation is outside its valid time range")
+	ErrThrottled             = errors.New("user operation entity is throttled or banned")
+	ErrInsufficientStake     = errors.New("user operation entity stake is too low")
+	ErrUnsupportedAggregator = errors.New("user operation aggregator is not supported")
+	ErrInvalidSignature      = errors.New("user operation signature is invalid")
+	ErrExecutionReverted     = errors.New("user operation execution reverted")
+)
+
+var rpcErrorSentinels = map[int]error{
+	CodeInvalidParams:         ErrInvalidParams,
+	CodeValidationRejected:    ErrValidationRejected,
+	CodePaymasterRejected:     ErrPaymasterRejected,
+	CodeBannedOpcode:          ErrBannedOpcode,
+	CodeTimeRange:             ErrTimeRange,
+	CodeThrottled:             ErrThrottled,
+	CodeInsufficientStake:     ErrInsufficientStake,
+	CodeUnsupportedAggregator: ErrUnsupportedAggregator,
+	CodeInvalidSignature:      ErrInvalidSignature,
+	CodeExecutionReverted:     ErrExecutionReverted,
+}
+
+// RPCError is a JSON-RPC error object returned by a bundler.
+type RPCError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("rpc error %d: %s", e.Code, e.Message)
+}
+
+// Is reports whether target is the sentinel for e.Code.
+func (e *RPCError) Is(target error) bool {
+	sentinel, ok := rpcErrorSentinels[e.Code]
+	return ok && sentinel == target
+}
+
+// EntityErrorData names the entities involved in a rejection (-32501, -32504, -32506).
+type EntityErrorData struct {
+	Paymaster  *common.Address `json:"paymaster,omitempty"`
+	Aggregator *common.Address `json:"aggregator,omitempty"`
+	Factory    *common.Address `json:"factory,omitempty"`
+}
+
+// TimeRangeErrorData is the -32503 payload.
+type TimeRangeErrorData struct {
+	ValidUntil uint64
+	ValidAfter uint64
+	Paymaster  *common.Address
+}
+
+// StakeErrorData is the -32505 payload.
+type StakeErrorData struct {
+	EntityErrorData
+	MinimumStake        *big.Int
+	MinimumUnstakeDelay uint64
+}
+
+// Details decodes Data according to Code. It returns *EntityErrorData,
+// *TimeRangeErrorData or *StakeErrorData for the codes that define a payload,
+// and nil for codes without one or an empty Data field.
+func (e *RPCError) Details() (any, error) {
+	if len(e.Data) == 0 || string(e.Data) == "null" {
+		return nil, nil
+	}
+	switch e.Code {
+	case CodePaymasterRejected, CodeThrottled, CodeUnsupportedAggregator:
+		var out EntityErrorData
+		if err := json.Unmarshal(e.Data, &out); err != nil {
+			return nil, fmt.Errorf("decode rpc error data: %w", err)
+		}
+		return &out, nil
+	case CodeTimeRange:
+		var raw struct {
+			ValidUntil json.RawMessage `json:"validUntil"`
+			ValidAfter json.RawMessage `json:"validAfter"`
+			Paymaster  *common.Address `json:"paymaster,omitempty"`
+		}
+		if err := json.Unmarshal(e.Data, &raw); err != nil {
+			return nil, fmt.Errorf("decode rpc error data: %w", err)
+		}
+		out := &TimeRangeErrorData{Paymaster: raw.Paymaster}
+		var err error
+		if out.ValidUntil, err = parseQuantity(raw.ValidUntil); err != nil {
+			return nil, fmt.Errorf("decode validUntil: %w", err)
+		}
+		if out.ValidAfter, err = parseQuantity(raw.ValidAfter); err != nil {
+			return nil, fmt.Errorf("decode validAfter: %w", err)
+		}
+		return out, nil
+	case CodeInsufficientStake:
+		var raw struct {
+			EntityErrorData
+			MinimumStake        json.RawMessage `json:"minimumStake"`
+			MinimumUnstakeDelay json.RawMessage `json:"minimumUnstakeDelay"`
+		}
+		if err := json.Unmarshal(e.Data, &raw); err != nil {
+			return nil, fmt.Errorf("decode rpc error data: %w", err)
+		}
+		out := &StakeErrorData{EntityErrorData: raw.EntityErrorData}
+		var err error
+		if out.MinimumStake, err = parseBigQuantity(raw.MinimumStake); err != nil {
+			return nil, fmt.Errorf("decode minimumStake: %w", err)
+		}
+		if out.MinimumUnstakeDelay, err = parseQuantity(raw.MinimumUnstakeDelay); err != nil {
+			return nil, fmt.Errorf("decode minimumUnstakeDelay: %w", err)
+		}
+		return out, nil
+	default:
+		return nil, nil
+	}
+}
+
+// RevertData returns revert bytes carried in Data, either as a hex string or
+// as a {"revertData": "0x..."} object, as bundlers report failed validation or execution.
+func (e *RPCError) RevertData() ([]byte, bool) {
+	var hexData hexutil.Bytes
+	if err := json.Unmarshal(e.Data, &hexData); err == nil {
+		return hexData, true
+	}
+	var wrapped struct {
+		RevertData *hexutil.Bytes `json:"revertData"`
+		Reason     *hexutil.Bytes `json:"reason"`
+	}
+	if err := json.Unmarshal(e.Data, &wrapped); err != nil {
+		return nil, false
+	}
+	switch {
+	case wrapped.RevertData != nil:
+		return *wrapped.RevertData, true
+	case wrapped.Reason != nil:
+		return *wrapped.Reason, true
+	default:
+		return nil, false
+	}
+}
+
+// Revert decodes RevertData with batching.DecodeRevert, or returns nil without revert data.
+func (e *RPCError) Revert() *batching.Revert {
+	data, ok := e.RevertData()
+	if !ok {
+		return nil
+	}
+	return batching.DecodeRevert(data, nil)
+}
+
+// parseQuantity accepts a JSON number, a decimal string or a 0x-prefixed hex string.
+func parseQuantity(raw json.RawMessage) (uint64, error) {
+	v, err := parseBigQuantity(raw)
+	if err != nil || v == nil {
+		return 0, err
+	}
+	if !v.IsUint64() {
+		return 0, errors.New("value overflows uint64")
+	}
+	return v.Uint64(), nil
+}
+
+func parseBigQuantity(raw json.RawMessage) (*big.Int, error) {
+	text := strings.TrimSpace(string(raw))
+	if text == "" || text == "null" {
+		return nil, nil
+	}
+	if unquoted, err := strconv.Unquote(text); err == nil {
+		text = strings.TrimSpace(unquoted)
+	}
+	if text == "" {
+		return nil, nil
+	}
+	base := 10
+	if digits := trimHexPrefix(text); len(digits) != len(text) {
+		if digits == "" {
+			return new(big.Int), nil
+		}
+		base = 16
+		text = digits
+	}
+	// SetString accepts a leading sign, which quantities never carry.
+	if text[0] == '+' || text[0] == '-' {
+		return nil, fmt.Errorf("invalid quantity %q", text)
+	}
+	v, ok := new(big.Int).SetString(text, base)
+	if !ok {
+		return nil, fmt.Errorf("invalid quantity %q", text)
+	}
+	return v, nil
+}
+
+func trimHexPrefix(s string) string {
+	if len(s) >= 2 && (s[:2] == "0x" || s[:2] == "0X") {
+		return s[2:]
+	}
+	return s
+}
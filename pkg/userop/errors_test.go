@@ -0,0 +1,141 @@
+package userop_test
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/eipcodelab/eip7702-go/pkg/batching"
+	"github.com/eipcodelab/eip7702-go/pkg/userop"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestSendUserOperationReturnsRPCError(t *testing.T) {
+	paymaster := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	srv := newRPCServer(t, func(call rpcCall) (any, map[string]any) {
+		return nil, map[string]any{
+			"code":    userop.CodeTimeRange,
+			"message": "expired",
+			"data":    map[string]any{"validUntil": "0x64", "validAfter": 10, "paymaster": paymaster},
+		}
+	})
+
+	_, err := userop.NewBundlerClient(srv.URL).SendUserOperationV07(context.Background(), makeUserOpV07(), userop.EntryPointV07Address)
+	if !errors.Is(err, userop.ErrTimeRange) {
+		t.Fatalf("error = %v, want ErrTimeRange", err)
+	}
+	if errors.Is(err, userop.ErrThrottled) {
+		t.Fatal("error must not match other sentinels")
+	}
+	var rpcErr *userop.RPCError
+	if !errors.As(err, &rpcErr) {
+		t.Fatalf("error %T is not *RPCError", err)
+	}
+	details, err := rpcErr.Details()
+	if err != nil {
+		t.Fatalf("Details() error = %v", err)
+	}
+	timeRange, ok := details.(*userop.TimeRangeErrorData)
+	if !ok {
+		t.Fatalf("details = %T, want *TimeRangeErrorData", details)
+	}
+	if timeRange.ValidUntil != 100 || timeRange.ValidAfter != 10 || timeRange.Paymaster == nil || *timeRange.Paymaster != paymaster {
+		t.Fatalf("details = %+v", timeRange)
+	}
+}
+
+func TestRPCErrorSentinels(t *testing.T) {
+	cases := map[int]error{
+		userop.CodeValidationRejected:    userop.ErrValidationRejected,
+		userop.CodePaymasterRejected:     userop.ErrPaymasterRejected,
+		userop.CodeBannedOpcode:          userop.ErrBannedOpcode,
+		userop.CodeTimeRange:             userop.ErrTimeRange,
+		userop.CodeThrottled:             userop.ErrThrottled,
+		userop.CodeInsufficientStake:     userop.ErrInsufficientStake,
+		userop.CodeUnsupportedAggregator: userop.ErrUnsupportedAggregator,
+		userop.CodeInvalidSignature:      userop.ErrInvalidSignature,
+	}
+	for code, sentinel := range cases {
+		err := error(&userop.RPCError{Code: code, Message: "x"})
+		if !errors.Is(err, sentinel) {
+			t.Errorf("code %d does not match %v", code, sentinel)
+		}
+	}
+	if errors.Is(&userop.RPCError{Code: -32000}, userop.ErrValidationRejected) {
+		t.Fatal("unknown code must not match a sentinel")
+	}
+}
+
+func TestRPCErrorStakeDetails(t *testing.T) {
+	rpcErr := &userop.RPCError{
+		Code: userop.CodeInsufficientStake,
+		Data: []byte(`{"paymaster":"0x00000000000000000000000000000000000000bb","minimumStake":"0xde0b6b3a7640000","minimumUnstakeDelay":86400}`),
+	}
+	details, err := rpcErr.Details()
+	if err != nil {
+		t.Fatalf("Details() error = %v", err)
+	}
+	stake := details.(*userop.StakeErrorData)
+	if stake.MinimumStake.Cmp(big.NewInt(1e18)) != 0 || stake.MinimumUnstakeDelay != 86400 || stake.Paymaster == nil {
+		t.Fatalf("stake = %+v", stake)
+	}
+}
+
+func TestRPCErrorQuantities(t *testing.T) {
+	tests := []struct {
+		delay   string
+		want    uint64
+		wantErr bool
+	}{
+		{`86400`, 86400, false},
+		{`"86400"`, 86400, false},
+		{`"010"`, 10, false}, // decimal, not octal
+		{`"0x15180"`, 86400, false},
+		{`"0X10"`, 16, false},
+		{`"0x"`, 0, false},
+		{`""`, 0, false},
+		{`"0b101"`, 0, true},
+		{`"0o17"`, 0, true},
+		{`"1_000"`, 0, true},
+		{`"-1"`, 0, true},
+		{`"+5"`, 0, true},
+		{`"0x+5"`, 0, true},
+	}
+	for _, tt := range tests {
+		rpcErr := &userop.RPCError{
+			Code: userop.CodeInsufficientStake,
+			Data: []byte(`{"minimumStake":"1","minimumUnstakeDelay":` + tt.delay + `}`),
+		}
+		details, err := rpcErr.Details()
+		if tt.wantErr {
+			if err == nil {
+				t.Fatalf("%s: expected error", tt.delay)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: Details() error = %v", tt.delay, err)
+		}
+		if got := details.(*userop.StakeErrorData).MinimumUnstakeDelay; got != tt.want {
+			t.Fatalf("%s: minimumUnstakeDelay = %d, want %d", tt.delay, got, tt.want)
+		}
+	}
+}
+
+func TestRPCErrorRevert(t *testing.T) {
+	reason := "0x08c379a0" +
+		"0000000000000000000000000000000000000000000000000000000000000020" +
+		"0000000000000000000000000000000000000000000000000000000000000004" +
+		"6e6f706500000000000000000000000000000000000000000000000000000000"
+	for _, data := range []string{`"` + reason + `"`, `{"revertData":"` + reason + `"}`} {
+		rpcErr := &userop.RPCError{Code: userop.CodeExecutionReverted, Data: []byte(data)}
+		revert := rpcErr.Revert()
+		if revert == nil || revert.Kind != batching.RevertError || revert.Message != "nope" {
+			t.Fatalf("Revert(%s) = %+v", data, revert)
+		}
+	}
+	if (&userop.RPCError{Code: userop.CodeThrottled, Data: []byte(`{"paymaster":"0x00000000000000000000000000000000000000bb"}`)}).Revert() != nil {
+		t.Fatal("entity data must not decode as revert")
+	}
+}
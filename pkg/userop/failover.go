@@ -0,0 +1,328 @@
+package userop
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// SelectionStrategy decides which healthy endpoint a FailoverClient tries next.
+type SelectionStrategy int
+
+const (
+	// SelectPriority always prefers the earliest endpoint in the list.
+	SelectPriority SelectionStrategy = iota
+	// SelectRoundRobin rotates through endpoints on every attempt.
+	SelectRoundRobin
+	// SelectWeighted picks endpoints at random in proportion to Endpoint.Weight.
+	SelectWeighted
+)
+
+// CodeLimitExceeded is the JSON-RPC code most providers use for rate limiting.
+const CodeLimitExceeded = -32005
+
+const (
+	defaultFailoverBackoff    = 200 * time.Millisecond
+	defaultFailoverMaxBackoff = 5 * time.Second
+	defaultFailureThreshold   = 3
+	defaultFailoverCooldown   = 30 * time.Second
+)
+
+// ErrNoEndpoints is returned by NewFailoverClient without endpoints.
+var ErrNoEndpoints = errors.New("at least one bundler endpoint is required")
+
+// Endpoint is one bundler behind a FailoverClient.
+type Endpoint struct {
+	Name   string
+	Client *BundlerClient
+	// Weight is used by SelectWeighted. Zero means 1.
+	Weight int
+}
+
+// FailoverOptions configures NewFailoverClient. Zero values use the defaults.
+type FailoverOptions struct {
+	Strategy SelectionStrategy
+	// MaxAttempts bounds tries per call across all endpoints. Default max(3, len(endpoints)).
+	MaxAttempts int
+	// Backoff is the delay before revisiting endpoints already tried in a call,
+	// doubled on every round up to MaxBackoff. Defaults 200ms and 5s.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// FailureThreshold consecutive retryable failures mark an endpoint unhealthy. Default 3.
+	FailureThreshold int
+	// Cooldown is how long an unhealthy endpoint is skipped before it is tried again. Default 30s.
+	Cooldown time.Duration
+	// Retryable classifies errors. Default IsRetryable.
+	Retryable func(error) bool
+}
+
+// EndpointStats is a snapshot of one endpoint's counters and health.
+// Failures counts retryable errors only; other errors are answers from a working bundler.
+type EndpointStats struct {
+	Name                string
+	Healthy             bool
+	Requests            uint64
+	Successes           uint64
+	Failures            uint64
+	ConsecutiveFailures int
+	LastError           error
+	UnhealthyUntil      time.Time
+}
+
+type endpointState struct {
+	Endpoint
+	stats EndpointStats
+}
+
+// FailoverClient spreads calls over several bundlers, retrying retryable errors
+// on other endpoints and skipping endpoints that keep failing.
+// It is safe for concurrent use by multiple goroutines.
+type FailoverClient struct {
+	opts FailoverOptions
+
+	mu        sync.Mutex
+	endpoints []*endpointState
+	next      int
+}
+
+// NewFailoverClient creates a client over endpoints in priority order.
+func NewFailoverClient(endpoints []Endpoint, opts FailoverOptions) (*FailoverClient, error) {
+	if len(endpoints) == 0 {
+		return nil, ErrNoEndpoints
+	}
+	states := make([]*endpointState, len(endpoints))
+	for i, ep := range endpoints {
+		if ep.Client == nil {
+			return nil, fmt.Errorf("endpoint %d has no client", i)
+		}
+		if ep.Weight < 0 {
+			return nil, fmt.Errorf("endpoint %d has negative weight", i)
+		}
+		if ep.Weight == 0 {
+			ep.Weight = 1
+		}
+		if ep.Name == "" {
+			ep.Name = ep.Client.endpoint
+		}
+		states[i] = &endpointState{Endpoint: ep, stats: EndpointStats{Name: ep.Name, Healthy: true}}
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = max(3, len(endpoints))
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = defaultFailoverBackoff
+	}
+	if opts.MaxBackoff < opts.Backoff {
+		opts.MaxBackoff = max(defaultFailoverMaxBackoff, opts.Backoff)
+	}
+	if opts.FailureThreshold <= 0 {
+		opts.FailureThreshold = defaultFailureThreshold
+	}
+	if opts.Cooldown <= 0 {
+		opts.Cooldown = defaultFailoverCooldown
+	}
+	if opts.Retryable == nil {
+		opts.Retryable = IsRetryable
+	}
+	return &FailoverClient{opts: opts, endpoints: states}, nil
+}
+
+// Do runs fn against selected endpoints until it succeeds, returns a
+// non-retryable error or MaxAttempts is reached. fn may run more than once,
+// so it must be safe to repeat.
+func (f *FailoverClient) Do(ctx context.Context, fn func(*BundlerClient) error) error {
+	tried := make(map[int]bool, len(f.endpoints))
+	backoff := f.opts.Backoff
+	var lastErr error
+	for attempt := 0; attempt < f.opts.MaxAttempts; attempt++ {
+		if len(tried) == len(f.endpoints) {
+			if err := sleepContext(ctx, backoff); err != nil {
+				return fmt.Errorf("%w (last error: %v)", err, lastErr)
+			}
+			backoff = min(backoff*2, f.opts.MaxBackoff)
+			clear(tried)
+		}
+		i := f.pick(tried)
+		tried[i] = true
+
+		err := fn(f.endpoints[i].Client)
+		if err != nil && ctx.Err() != nil {
+			return err
+		}
+		retryable := err != nil && f.opts.Retryable(err)
+		f.record(i, err, retryable)
+		if !retryable {
+			return err
+		}
+		lastErr = fmt.Errorf("bundler %s: %w", f.endpoints[i].Name, err)
+	}
+	return lastErr
+}
+
+// Call performs one JSON-RPC call with failover. See BundlerClient.Call.
+func (f *FailoverClient) Call(ctx context.Context, result any, method string, params ...any) error {
+	return f.Do(ctx, func(c *BundlerClient) error {
+		return c.Call(ctx, result, method, params...)
+	})
+}
+
+// SendUserOperation sends op with failover. Resubmitting to another bundler is
+// safe because the userOp hash, not the bundler, identifies the operation.
+func (f *FailoverClient) SendUserOperation(ctx context.Context, op UserOperation, entryPoint common.Address) (common.Hash, error) {
+	var hash common.Hash
+	err := f.Do(ctx, func(c *BundlerClient) error {
+		var err error
+		hash, err = c.SendUserOperation(ctx, op, entryPoint)
+		return err
+	})
+	return hash, err
+}
+
+// SendUserOperationV07 sends a v0.7-shaped op with failover.
+func (f *FailoverClient) SendUserOperationV07(ctx context.Context, op UserOperationV07, entryPoint common.Address) (common.Hash, error) {
+	var hash common.Hash
+	err := f.Do(ctx, func(c *BundlerClient) error {
+		var err error
+		hash, err = c.SendUserOperationV07(ctx, op, entryPoint)
+		return err
+	})
+	return hash, err
+}
+
+// GetUserOperationReceipt fetches a receipt with failover. ErrNotFound is not retried.
+func (f *FailoverClient) GetUserOperationReceipt(ctx context.Context, hash common.Hash) (*UserOperationReceipt, error) {
+	var receipt *UserOperationReceipt
+	err := f.Do(ctx, func(c *BundlerClient) error {
+		var err error
+		receipt, err = c.GetUserOperationReceipt(ctx, hash)
+		return err
+	})
+	return receipt, err
+}
+
+// Stats returns a snapshot of every endpoint in priority order.
+func (f *FailoverClient) Stats() []EndpointStats {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := time.Now()
+	out := make([]EndpointStats, len(f.endpoints))
+	for i, ep := range f.endpoints {
+		out[i] = ep.stats
+		out[i].Healthy = !now.Before(ep.stats.UnhealthyUntil)
+	}
+	return out
+}
+
+// pick returns the next endpoint index. Healthy endpoints not yet tried in
+// this call come first, then untried unhealthy ones in recovery order.
+func (f *FailoverClient) pick(tried map[int]bool) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := time.Now()
+	var candidates []int
+	for i, ep := range f.endpoints {
+		if !tried[i] && !now.Before(ep.stats.UnhealthyUntil) {
+			candidates = append(candidates, i)
+		}
+	}
+	if len(candidates) == 0 {
+		best := -1
+		for i, ep := range f.endpoints {
+			if !tried[i] && (best < 0 || ep.stats.UnhealthyUntil.Before(f.endpoints[best].stats.UnhealthyUntil)) {
+				best = i
+			}
+		}
+		return best
+	}
+
+	switch f.opts.Strategy {
+	case SelectRoundRobin:
+		// Choose the first candidate at or after the rotating cursor.
+		for _, i := range candidates {
+			if i >= f.next {
+				f.next = i + 1
+				return i
+			}
+		}
+		f.next = candidates[0] + 1
+		return candidates[0]
+	case SelectWeighted:
+		total := 0
+		for _, i := range candidates {
+			total += f.endpoints[i].Weight
+		}
+		n := rand.IntN(total)
+		for _, i := range candidates {
+			n -= f.endpoints[i].Weight
+			if n < 0 {
+				return i
+			}
+		}
+		return candidates[len(candidates)-1]
+	default:
+		return candidates[0]
+	}
+}
+
+func (f *FailoverClient) record(i int, err error, retryable bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	stats := &f.endpoints[i].stats
+	stats.Requests++
+	if !retryable {
+		// Non-retryable errors are answers from a working bundler.
+		if err == nil {
+			stats.Successes++
+		}
+		stats.ConsecutiveFailures = 0
+		stats.UnhealthyUntil = time.Time{}
+		return
+	}
+	stats.Failures++
+	stats.ConsecutiveFailures++
+	stats.LastError = err
+	if stats.ConsecutiveFailures >= f.opts.FailureThreshold {
+		stats.UnhealthyUntil = time.Now().Add(f.opts.Cooldown)
+	}
+}
+
+// IsRetryable reports whether err is worth retrying on another endpoint:
+// transport failures, malformed responses, HTTP 429 and 5xx, and rate-limit
+// or throttling RPC codes. Context cancellation is never retryable.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode == http.StatusTooManyRequests || httpErr.StatusCode >= 500
+	}
+	var rpcErr *RPCError
+	if errors.As(err, &rpcErr) {
+		return rpcErr.Code == CodeThrottled || rpcErr.Code == CodeLimitExceeded
+	}
+	var urlErr *url.Error
+	var netErr net.Error
+	return errors.As(err, &urlErr) || errors.As(err, &netErr) ||
+		errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, ErrInvalidResponse)
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,190 @@
+package userop_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/eipcodelab/eip7702-go/pkg/userop"
+)
+
+// countingBundler serves eth_chainId, or fails with status when fail is set.
+type countingBundler struct {
+	calls  atomic.Int32
+	fail   atomic.Bool
+	status int
+	srv    *httptest.Server
+}
+
+func newCountingBundler(t *testing.T, status int) *countingBundler {
+	t.Helper()
+	b := &countingBundler{status: status}
+	b.srv = newRPCServer(t, func(call rpcCall) (any, map[string]any) {
+		b.calls.Add(1)
+		if b.fail.Load() {
+			return nil, map[string]any{"code": b.status, "message": "failing"}
+		}
+		return "0x1", nil
+	})
+	return b
+}
+
+func (b *countingBundler) endpoint(name string, weight int) userop.Endpoint {
+	return userop.Endpoint{Name: name, Client: userop.NewBundlerClient(b.srv.URL), Weight: weight}
+}
+
+func chainID(t *testing.T, f *userop.FailoverClient) error {
+	t.Helper()
+	return f.Call(context.Background(), nil, "eth_chainId")
+}
+
+func TestFailoverRetriesOnNextEndpoint(t *testing.T) {
+	a := newCountingBundler(t, userop.CodeLimitExceeded)
+	b := newCountingBundler(t, userop.CodeLimitExceeded)
+	a.fail.Store(true)
+
+	f, err := userop.NewFailoverClient([]userop.Endpoint{a.endpoint("a", 0), b.endpoint("b", 0)}, userop.FailoverOptions{})
+	if err != nil {
+		t.Fatalf("NewFailoverClient() error = %v", err)
+	}
+	if err := chainID(t, f); err != nil {
+		t.Fatalf("Call() error = %v", err)
+	}
+	if a.calls.Load() != 1 || b.calls.Load() != 1 {
+		t.Fatalf("calls = %d/%d, want 1/1", a.calls.Load(), b.calls.Load())
+	}
+	stats := f.Stats()
+	if stats[0].Failures != 1 || stats[0].LastError == nil || stats[1].Successes != 1 {
+		t.Fatalf("stats = %+v", stats)
+	}
+}
+
+func TestFailoverDoesNotRetryValidationErrors(t *testing.T) {
+	a := newCountingBundler(t, userop.CodeValidationRejected)
+	b := newCountingBundler(t, userop.CodeValidationRejected)
+	a.fail.Store(true)
+
+	f, _ := userop.NewFailoverClient([]userop.Endpoint{a.endpoint("a", 0), b.endpoint("b", 0)}, userop.FailoverOptions{})
+	if err := chainID(t, f); !errors.Is(err, userop.ErrValidationRejected) {
+		t.Fatalf("Call() error = %v, want ErrValidationRejected", err)
+	}
+	if b.calls.Load() != 0 {
+		t.Fatal("non-retryable error must not fail over")
+	}
+	if stats := f.Stats(); stats[0].Failures != 0 || !stats[0].Healthy {
+		t.Fatalf("stats = %+v", stats[0])
+	}
+}
+
+func TestFailoverMarksUnhealthyAndRecovers(t *testing.T) {
+	a := newCountingBundler(t, userop.CodeLimitExceeded)
+	b := newCountingBundler(t, userop.CodeLimitExceeded)
+	a.fail.Store(true)
+
+	f, _ := userop.NewFailoverClient([]userop.Endpoint{a.endpoint("a", 0), b.endpoint("b", 0)}, userop.FailoverOptions{
+		FailureThreshold: 1,
+		Cooldown:         50 * time.Millisecond,
+	})
+	if err := chainID(t, f); err != nil {
+		t.Fatalf("first Call() error = %v", err)
+	}
+	if f.Stats()[0].Healthy {
+		t.Fatal("endpoint a should be unhealthy")
+	}
+	if err := chainID(t, f); err != nil {
+		t.Fatalf("second Call() error = %v", err)
+	}
+	if a.calls.Load() != 1 {
+		t.Fatalf("unhealthy endpoint was called %d times, want 1", a.calls.Load())
+	}
+
+	a.fail.Store(false)
+	time.Sleep(60 * time.Millisecond)
+	if err := chainID(t, f); err != nil {
+		t.Fatalf("third Call() error = %v", err)
+	}
+	if a.calls.Load() != 2 || !f.Stats()[0].Healthy {
+		t.Fatalf("endpoint a did not recover: calls %d, stats %+v", a.calls.Load(), f.Stats()[0])
+	}
+}
+
+func TestFailoverGivesUpAfterMaxAttempts(t *testing.T) {
+	a := newCountingBundler(t, userop.CodeLimitExceeded)
+	b := newCountingBundler(t, userop.CodeLimitExceeded)
+	a.fail.Store(true)
+	b.fail.Store(true)
+
+	f, _ := userop.NewFailoverClient([]userop.Endpoint{a.endpoint("a", 0), b.endpoint("b", 0)}, userop.FailoverOptions{
+		MaxAttempts: 3,
+		Backoff:     time.Millisecond,
+	})
+	var rpcErr *userop.RPCError
+	if err := chainID(t, f); !errors.As(err, &rpcErr) || rpcErr.Code != userop.CodeLimitExceeded {
+		t.Fatalf("Call() error = %v, want last rpc error", err)
+	}
+	if got := a.calls.Load() + b.calls.Load(); got != 3 {
+		t.Fatalf("attempts = %d, want 3", got)
+	}
+}
+
+func TestFailoverRoundRobin(t *testing.T) {
+	a := newCountingBundler(t, 0)
+	b := newCountingBundler(t, 0)
+	f, _ := userop.NewFailoverClient([]userop.Endpoint{a.endpoint("a", 0), b.endpoint("b", 0)}, userop.FailoverOptions{Strategy: userop.SelectRoundRobin})
+	for i := 0; i < 4; i++ {
+		if err := chainID(t, f); err != nil {
+			t.Fatalf("Call() error = %v", err)
+		}
+	}
+	if a.calls.Load() != 2 || b.calls.Load() != 2 {
+		t.Fatalf("calls = %d/%d, want 2/2", a.calls.Load(), b.calls.Load())
+	}
+}
+
+func TestFailoverWeighted(t *testing.T) {
+	a := newCountingBundler(t, 0)
+	b := newCountingBundler(t, 0)
+	f, _ := userop.NewFailoverClient([]userop.Endpoint{a.endpoint("a", 1), b.endpoint("b", 3)}, userop.FailoverOptions{Strategy: userop.SelectWeighted})
+	for i := 0; i < 400; i++ {
+		if err := chainID(t, f); err != nil {
+			t.Fatalf("Call() error = %v", err)
+		}
+	}
+	if got := b.calls.Load(); got < 240 || got > 360 {
+		t.Fatalf("weighted endpoint got %d of 400 calls, want about 300", got)
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{&userop.HTTPError{StatusCode: http.StatusServiceUnavailable}, true},
+		{&userop.HTTPError{StatusCode: http.StatusTooManyRequests}, true},
+		{&userop.HTTPError{StatusCode: http.StatusUnauthorized}, false},
+		{&userop.RPCError{Code: userop.CodeThrottled}, true},
+		{&userop.RPCError{Code: userop.CodeInvalidSignature}, false},
+		{fmt.Errorf("post request: %w", &url.Error{Op: "Post", URL: "http://x", Err: errors.New("connection refused")}), true},
+		{fmt.Errorf("%w: bad body", userop.ErrInvalidResponse), true},
+		{context.DeadlineExceeded, false},
+		{userop.ErrNotFound, false},
+	}
+	for _, tc := range cases {
+		if got := userop.IsRetryable(tc.err); got != tc.want {
+			t.Errorf("IsRetryable(%v) = %v, want %v", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestNewFailoverClientRequiresEndpoints(t *testing.T) {
+	if _, err := userop.NewFailoverClient(nil, userop.FailoverOptions{}); !errors.Is(err, userop.ErrNoEndpoints) {
+		t.Fatalf("error = %v, want ErrNoEndpoints", err)
+	}
+}
@@ -0,0 +1,256 @@
+package userop
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+const (
+	defaultFeeHistoryBlocks = 10
+	defaultFeePercentile    = 50
+)
+
+// GasPrice is a suggested fee pair for a user operation.
+type GasPrice struct {
+	MaxFeePerGas         *big.Int
+	MaxPriorityFeePerGas *big.Int
+}
+
+// Apply sets the fee fields of a v0.6 operation.
+func (p GasPrice) Apply(op *UserOperation) {
+	op.MaxFeePerGas = HexBig(p.MaxFeePerGas)
+	op.MaxPriorityFeePerGas = HexBig(p.MaxPriorityFeePerGas)
+}
+
+// ApplyV07 sets the fee fields of a v0.7 or v0.8 operation.
+func (p GasPrice) ApplyV07(op *UserOperationV07) {
+	op.MaxFeePerGas = HexBig(p.MaxFeePerGas)
+	op.MaxPriorityFeePerGas = HexBig(p.MaxPriorityFeePerGas)
+}
+
+// FeeOracle suggests user operation gas prices.
+type FeeOracle interface {
+	SuggestGasPrice(ctx context.Context) (GasPrice, error)
+}
+
+// FeeOracleFunc adapts a function to FeeOracle.
+type FeeOracleFunc func(ctx context.Context) (GasPrice, error)
+
+// SuggestGasPrice calls f.
+func (f FeeOracleFunc) SuggestGasPrice(ctx context.Context) (GasPrice, error) {
+	return f(ctx)
+}
+
+// FeePolicy adjusts a raw suggestion. Zero values leave it unchanged.
+type FeePolicy struct {
+	// Percentile is the priority fee percentile read from eth_feeHistory, 0 to 100.
+	// Only FeeHistoryOracle uses it. Default 50.
+	Percentile float64
+	// Multiplier scales both fees before the bounds are applied. Zero means 1.
+	Multiplier float64
+	// PriorityFeeFloor and PriorityFeeCap bound MaxPriorityFeePerGas.
+	PriorityFeeFloor *big.Int
+	PriorityFeeCap   *big.Int
+	// MaxFeeFloor and MaxFeeCap bound MaxFeePerGas.
+	MaxFeeFloor *big.Int
+	MaxFeeCap   *big.Int
+}
+
+// Apply scales and bounds price. The priority fee never exceeds the max fee.
+func (p FeePolicy) Apply(price GasPrice) (GasPrice, error) {
+	if price.MaxFeePerGas == nil || price.MaxPriorityFeePerGas == nil {
+		return GasPrice{}, fmt.Errorf("%w: fee suggestion is incomplete", ErrInvalidResponse)
+	}
+	if p.Multiplier < 0 {
+		return GasPrice{}, errors.New("fee multiplier must be non-negative")
+	}
+	if p.MaxFeeCap != nil && p.MaxFeeFloor != nil && p.MaxFeeCap.Cmp(p.MaxFeeFloor) < 0 {
+		return GasPrice{}, errors.New("max fee cap is below the floor")
+	}
+	if p.PriorityFeeCap != nil && p.PriorityFeeFloor != nil && p.PriorityFeeCap.Cmp(p.PriorityFeeFloor) < 0 {
+		return GasPrice{}, errors.New("priority fee cap is below the floor")
+	}
+	out := GasPrice{
+		MaxFeePerGas:         clampWei(scaleWei(price.MaxFeePerGas, p.Multiplier), p.MaxFeeFloor, p.MaxFeeCap),
+		MaxPriorityFeePerGas: clampWei(scaleWei(price.MaxPriorityFeePerGas, p.Multiplier), p.PriorityFeeFloor, p.PriorityFeeCap),
+	}
+	if out.MaxPriorityFeePerGas.Cmp(out.MaxFeePerGas) > 0 {
+		out.MaxPriorityFeePerGas = new(big.Int).Set(out.MaxFeePerGas)
+	}
+	return out, nil
+}
+
+func (p FeePolicy) percentile() (float64, error) {
+	if p.Percentile == 0 {
+		return defaultFeePercentile, nil
+	}
+	if p.Percentile < 0 || p.Percentile > 100 {
+		return 0, fmt.Errorf("fee percentile %v is outside 0-100", p.Percentile)
+	}
+	return p.Percentile, nil
+}
+
+func scaleWei(v *big.Int, multiplier float64) *big.Int {
+	if multiplier == 0 || multiplier == 1 {
+		return new(big.Int).Set(v)
+	}
+	scaled, _ := new(big.Float).Mul(new(big.Float).SetInt(v), big.NewFloat(multiplier)).Int(nil)
+	return scaled
+}
+
+func clampWei(v, floor, ceiling *big.Int) *big.Int {
+	if floor != nil && v.Cmp(floor) < 0 {
+		return new(big.Int).Set(floor)
+	}
+	if ceiling != nil && v.Cmp(ceiling) > 0 {
+		return new(big.Int).Set(ceiling)
+	}
+	return v
+}
+
+// NodeFeeOracle prices operations from eth_maxPriorityFeePerGas and the latest
+// base fee: maxFee = 2 × baseFee + tip.
+type NodeFeeOracle struct {
+	Node   RPCCaller
+	Policy FeePolicy
+}
+
+// SuggestGasPrice implements FeeOracle.
+func (o NodeFeeOracle) SuggestGasPrice(ctx context.Context) (GasPrice, error) {
+	tip, err := maxPriorityFee(ctx, o.Node, "eth_maxPriorityFeePerGas")
+	if err != nil {
+		return GasPrice{}, err
+	}
+	baseFee, err := latestBaseFee(ctx, o.Node)
+	if err != nil {
+		return GasPrice{}, err
+	}
+	return o.Policy.Apply(GasPrice{MaxFeePerGas: headroomMaxFee(baseFee, tip), MaxPriorityFeePerGas: tip})
+}
+
+// FeeHistoryOracle prices operations from eth_feeHistory: the tip is the median over
+// Blocks of the Policy.Percentile reward and maxFee = 2 × next base fee + tip.
+type FeeHistoryOracle struct {
+	Node RPCCaller
+	// Blocks is the number of recent blocks sampled. Default 10.
+	Blocks uint64
+	Policy FeePolicy
+}
+
+// SuggestGasPrice implements FeeOracle.
+func (o FeeHistoryOracle) SuggestGasPrice(ctx context.Context) (GasPrice, error) {
+	percentile, err := o.Policy.percentile()
+	if err != nil {
+		return GasPrice{}, err
+	}
+	blocks := o.Blocks
+	if blocks == 0 {
+		blocks = defaultFeeHistoryBlocks
+	}
+	var history struct {
+		BaseFeePerGas []*hexutil.Big   `json:"baseFeePerGas"`
+		Reward        [][]*hexutil.Big `json:"reward"`
+	}
+	if err := o.Node.Call(ctx, &history, "eth_feeHistory", hexutil.Uint64(blocks), "latest", []float64{percentile}); err != nil {
+		return GasPrice{}, fmt.Errorf("read fee history: %w", err)
+	}
+	// baseFeePerGas carries one more entry than blocks sampled: the next block's base fee.
+	if len(history.BaseFeePerGas) == 0 || history.BaseFeePerGas[len(history.BaseFeePerGas)-1] == nil {
+		return GasPrice{}, fmt.Errorf("%w: fee history has no base fee", ErrInvalidResponse)
+	}
+	baseFee := history.BaseFeePerGas[len(history.BaseFeePerGas)-1].ToInt()
+
+	rewards := make([]*big.Int, 0, len(history.Reward))
+	for _, reward := range history.Reward {
+		if len(reward) > 0 && reward[0] != nil {
+			rewards = append(rewards, reward[0].ToInt())
+		}
+	}
+	if len(rewards) == 0 {
+		return GasPrice{}, fmt.Errorf("%w: fee history has no rewards", ErrInvalidResponse)
+	}
+	slices.SortFunc(rewards, func(a, b *big.Int) int { return a.Cmp(b) })
+	tip := new(big.Int).Set(rewards[len(rewards)/2])
+	return o.Policy.Apply(GasPrice{MaxFeePerGas: headroomMaxFee(baseFee, tip), MaxPriorityFeePerGas: tip})
+}
+
+// PimlicoFeeOracle reads pimlico_getUserOperationGasPrice from a Pimlico bundler.
+type PimlicoFeeOracle struct {
+	Bundler RPCCaller
+	// Speed is "slow", "standard" or "fast". Default "standard".
+	Speed  string
+	Policy FeePolicy
+}
+
+// SuggestGasPrice implements FeeOracle.
+func (o PimlicoFeeOracle) SuggestGasPrice(ctx context.Context) (GasPrice, error) {
+	type tier struct {
+		MaxFeePerGas         *hexutil.Big `json:"maxFeePerGas"`
+		MaxPriorityFeePerGas *hexutil.Big `json:"maxPriorityFeePerGas"`
+	}
+	var tiers map[string]tier
+	if err := o.Bundler.Call(ctx, &tiers, "pimlico_getUserOperationGasPrice"); err != nil {
+		return GasPrice{}, fmt.Errorf("read pimlico gas price: %w", err)
+	}
+	speed := o.Speed
+	if speed == "" {
+		speed = "standard"
+	}
+	t, ok := tiers[speed]
+	if !ok || t.MaxFeePerGas == nil || t.MaxPriorityFeePerGas == nil {
+		return GasPrice{}, fmt.Errorf("%w: no %q gas price", ErrInvalidResponse, speed)
+	}
+	return o.Policy.Apply(GasPrice{MaxFeePerGas: t.MaxFeePerGas.ToInt(), MaxPriorityFeePerGas: t.MaxPriorityFeePerGas.ToInt()})
+}
+
+// RundlerFeeOracle reads rundler_maxPriorityFeePerGas from an Alchemy (Rundler) bundler,
+// which requires operations to pay at least that tip, and the base fee from the same endpoint.
+type RundlerFeeOracle struct {
+	Bundler RPCCaller
+	Policy  FeePolicy
+}
+
+// SuggestGasPrice implements FeeOracle.
+func (o RundlerFeeOracle) SuggestGasPrice(ctx context.Context) (GasPrice, error) {
+	tip, err := maxPriorityFee(ctx, o.Bundler, "rundler_maxPriorityFeePerGas")
+	if err != nil {
+		return GasPrice{}, err
+	}
+	baseFee, err := latestBaseFee(ctx, o.Bundler)
+	if err != nil {
+		return GasPrice{}, err
+	}
+	return o.Policy.Apply(GasPrice{MaxFeePerGas: headroomMaxFee(baseFee, tip), MaxPriorityFeePerGas: tip})
+}
+
+// headroomMaxFee returns 2 × baseFee + tip, which stays valid through several full blocks.
+func headroomMaxFee(baseFee, tip *big.Int) *big.Int {
+	maxFee := new(big.Int).Mul(baseFee, big.NewInt(2))
+	return maxFee.Add(maxFee, tip)
+}
+
+func maxPriorityFee(ctx context.Context, node RPCCaller, method string) (*big.Int, error) {
+	var tip hexutil.Big
+	if err := node.Call(ctx, &tip, method); err != nil {
+		return nil, fmt.Errorf("read priority fee: %w", err)
+	}
+	return tip.ToInt(), nil
+}
+
+func latestBaseFee(ctx context.Context, node RPCCaller) (*big.Int, error) {
+	var head struct {
+		BaseFeePerGas *hexutil.Big `json:"baseFeePerGas"`
+	}
+	if err := node.Call(ctx, &head, "eth_getBlockByNumber", "latest", false); err != nil {
+		return nil, fmt.Errorf("read base fee: %w", err)
+	}
+	if head.BaseFeePerGas == nil {
+		return nil, fmt.Errorf("%w: latest block has no base fee", ErrInvalidResponse)
+	}
+	return head.BaseFeePerGas.ToInt(), nil
+}
@@ -0,0 +1,175 @@
+package userop_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/eipcodelab/eip7702-go/pkg/userop"
+)
+
+const gwei = 1_000_000_000
+
+func gweiInt(n int64) *big.Int {
+	return big.NewInt(n * gwei)
+}
+
+func TestFeePolicyApply(t *testing.T) {
+	raw := userop.GasPrice{MaxFeePerGas: gweiInt(20), MaxPriorityFeePerGas: gweiInt(2)}
+
+	tests := []struct {
+		name        string
+		policy      userop.FeePolicy
+		maxFee, tip int64
+		wantErr     bool
+	}{
+		{"unchanged", userop.FeePolicy{}, 20, 2, false},
+		{"multiplier", userop.FeePolicy{Multiplier: 1.5}, 30, 3, false},
+		{"floors", userop.FeePolicy{MaxFeeFloor: gweiInt(25), PriorityFeeFloor: gweiInt(3)}, 25, 3, false},
+		{"caps", userop.FeePolicy{MaxFeeCap: gweiInt(10), PriorityFeeCap: gweiInt(1)}, 10, 1, false},
+		{"tip capped by max fee", userop.FeePolicy{MaxFeeCap: gweiInt(1)}, 1, 1, false},
+		{"negative multiplier", userop.FeePolicy{Multiplier: -1}, 0, 0, true},
+		{"cap below floor", userop.FeePolicy{MaxFeeFloor: gweiInt(5), MaxFeeCap: gweiInt(4)}, 0, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.policy.Apply(raw)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Apply() error = %v", err)
+			}
+			if got.MaxFeePerGas.Cmp(gweiInt(tt.maxFee)) != 0 || got.MaxPriorityFeePerGas.Cmp(gweiInt(tt.tip)) != 0 {
+				t.Fatalf("Apply() = %s / %s, want %d / %d gwei", got.MaxFeePerGas, got.MaxPriorityFeePerGas, tt.maxFee, tt.tip)
+			}
+		})
+	}
+	if raw.MaxFeePerGas.Cmp(gweiInt(20)) != 0 {
+		t.Fatal("Apply modified its input")
+	}
+}
+
+func TestGasPriceApply(t *testing.T) {
+	price := userop.GasPrice{MaxFeePerGas: gweiInt(9), MaxPriorityFeePerGas: gweiInt(1)}
+	op := makeUserOp()
+	price.Apply(&op)
+	opV07 := makeUserOpV07()
+	price.ApplyV07(&opV07)
+	if op.MaxFeePerGas.ToInt().Cmp(gweiInt(9)) != 0 || opV07.MaxPriorityFeePerGas.ToInt().Cmp(gweiInt(1)) != 0 {
+		t.Fatalf("fees = %s, %s", op.MaxFeePerGas, opV07.MaxPriorityFeePerGas)
+	}
+}
+
+func TestNodeFeeOracle(t *testing.T) {
+	srv := newRPCServer(t, func(call rpcCall) (any, map[string]any) {
+		switch call.Method {
+		case "eth_maxPriorityFeePerGas":
+			return "0x3b9aca00", nil // 1 gwei
+		case "eth_getBlockByNumber":
+			return map[string]any{"baseFeePerGas": "0x2540be400"}, nil // 10 gwei
+		}
+		return nil, map[string]any{"code": -32601, "message": "method not found"}
+	})
+	oracle := userop.NodeFeeOracle{Node: userop.NewBundlerClient(srv.URL), Policy: userop.FeePolicy{PriorityFeeFloor: gweiInt(2)}}
+	got, err := oracle.SuggestGasPrice(context.Background())
+	if err != nil {
+		t.Fatalf("SuggestGasPrice() error = %v", err)
+	}
+	// 2 × 10 + 1 gwei, then the tip is raised to its floor.
+	if got.MaxFeePerGas.Cmp(gweiInt(21)) != 0 || got.MaxPriorityFeePerGas.Cmp(gweiInt(2)) != 0 {
+		t.Fatalf("SuggestGasPrice() = %+v", got)
+	}
+}
+
+func TestFeeHistoryOracle(t *testing.T) {
+	var params []json.RawMessage
+	srv := newRPCServer(t, func(call rpcCall) (any, map[string]any) {
+		if call.Method != "eth_feeHistory" {
+			return nil, map[string]any{"code": -32601, "message": "method not found"}
+		}
+		params = call.Params
+		return map[string]any{
+			"oldestBlock":   "0x10",
+			"baseFeePerGas": []string{"0x1", "0x2", "0x3", "0x4a817c800"}, // next block: 20 gwei
+			"gasUsedRatio":  []float64{0.5, 0.5, 0.5},
+			"reward":        [][]string{{"0x77359400"}, {"0x3b9aca00"}, {"0xb2d05e00"}}, // 2, 1, 3 gwei
+		}, nil
+	})
+	oracle := userop.FeeHistoryOracle{
+		Node:   userop.NewBundlerClient(srv.URL),
+		Blocks: 3,
+		Policy: userop.FeePolicy{Percentile: 75, Multiplier: 2, MaxFeeCap: gweiInt(60)},
+	}
+	got, err := oracle.SuggestGasPrice(context.Background())
+	if err != nil {
+		t.Fatalf("SuggestGasPrice() error = %v", err)
+	}
+	// Median tip 2 gwei, maxFee 2 × 20 + 2 = 42 gwei; doubled to 84, capped at 60.
+	if got.MaxFeePerGas.Cmp(gweiInt(60)) != 0 || got.MaxPriorityFeePerGas.Cmp(gweiInt(4)) != 0 {
+		t.Fatalf("SuggestGasPrice() = %+v", got)
+	}
+	var blocks string
+	var percentiles []float64
+	if json.Unmarshal(params[0], &blocks) != nil || json.Unmarshal(params[2], &percentiles) != nil ||
+		blocks != "0x3" || len(percentiles) != 1 || percentiles[0] != 75 {
+		t.Fatalf("eth_feeHistory params = %s", params)
+	}
+
+	oracle.Policy.Percentile = 101
+	if _, err := oracle.SuggestGasPrice(context.Background()); err == nil {
+		t.Fatal("expected error for percentile above 100")
+	}
+}
+
+func TestBundlerFeeOracles(t *testing.T) {
+	srv := newRPCServer(t, func(call rpcCall) (any, map[string]any) {
+		switch call.Method {
+		case "pimlico_getUserOperationGasPrice":
+			tier := func(maxFee, tip string) map[string]string {
+				return map[string]string{"maxFeePerGas": maxFee, "maxPriorityFeePerGas": tip}
+			}
+			return map[string]any{
+				"slow":     tier("0x1", "0x1"),
+				"standard": tier("0x2", "0x1"),
+				"fast":     tier("0x3", "0x2"),
+			}, nil
+		case "rundler_maxPriorityFeePerGas":
+			return "0x5", nil
+		case "eth_getBlockByNumber":
+			return map[string]any{"baseFeePerGas": "0x10"}, nil
+		}
+		return nil, map[string]any{"code": -32601, "message": "method not found"}
+	})
+	client := userop.NewBundlerClient(srv.URL)
+
+	tests := []struct {
+		name        string
+		oracle      userop.FeeOracle
+		maxFee, tip int64
+	}{
+		{"pimlico default", userop.PimlicoFeeOracle{Bundler: client}, 2, 1},
+		{"pimlico fast", userop.PimlicoFeeOracle{Bundler: client, Speed: "fast"}, 3, 2},
+		{"rundler", userop.RundlerFeeOracle{Bundler: client}, 2*0x10 + 5, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.oracle.SuggestGasPrice(context.Background())
+			if err != nil {
+				t.Fatalf("SuggestGasPrice() error = %v", err)
+			}
+			if got.MaxFeePerGas.Int64() != tt.maxFee || got.MaxPriorityFeePerGas.Int64() != tt.tip {
+				t.Fatalf("SuggestGasPrice() = %s / %s", got.MaxFeePerGas, got.MaxPriorityFeePerGas)
+			}
+		})
+	}
+
+	if _, err := (userop.PimlicoFeeOracle{Bundler: client, Speed: "instant"}).SuggestGasPrice(context.Background()); !errors.Is(err, userop.ErrInvalidResponse) {
+		t.Fatalf("unknown speed error = %v", err)
+	}
+}
@@ -0,0 +1,268 @@
+package userop
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+
+	"github.com/eipcodelab/eip7702-go/pkg/eip7702"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+)
+
+// GasOverheads are the preVerificationGas parameters used by the reference bundler.
+type GasOverheads struct {
+	// Fixed is the transaction base cost, shared by every operation in a bundle.
+	Fixed uint64
+	// PerUserOp is the EntryPoint's per-operation bookkeeping cost.
+	PerUserOp uint64
+	// PerUserOpWord is charged per 32-byte word of the encoded operation.
+	PerUserOpWord uint64
+	// ZeroByte and NonZeroByte are the calldata costs per byte.
+	ZeroByte    uint64
+	NonZeroByte uint64
+	// BundleSize is the number of operations expected to share Fixed. Zero means 1.
+	BundleSize uint64
+	// SigSize is the length of the dummy signature used when the operation is unsigned.
+	SigSize int
+	// EIP7702Auth is charged for operations carrying eip7702Auth.
+	EIP7702Auth uint64
+}
+
+// DefaultGasOverheads match the eth-infinitism reference bundler.
+var DefaultGasOverheads = GasOverheads{
+	Fixed:         21_000,
+	PerUserOp:     18_300,
+	PerUserOpWord: 4,
+	ZeroByte:      4,
+	NonZeroByte:   16,
+	BundleSize:    1,
+	SigSize:       65,
+	EIP7702Auth:   eip7702.PER_EMPTY_ACCOUNT_COST,
+}
+
+// placeholderPreVerificationGas is encoded for operations whose preVerificationGas is still zero.
+const placeholderPreVerificationGas = 21_000
+
+var (
+	packedUserOpArgsV06 = mustArguments("address", "uint256", "bytes", "bytes", "uint256", "uint256", "uint256", "uint256", "uint256", "bytes", "bytes")
+	packedUserOpArgsV07 = mustArguments("address", "uint256", "bytes", "bytes", "bytes32", "uint256", "bytes32", "bytes", "bytes")
+)
+
+func mustArguments(types ...string) abi.Arguments {
+	args := make(abi.Arguments, len(types))
+	for i, name := range types {
+		typ, err := abi.NewType(name, "", nil)
+		if err != nil {
+			panic(err)
+		}
+		args[i] = abi.Argument{Type: typ}
+	}
+	return args
+}
+
+// PreVerificationGas computes the offline preVerificationGas of a v0.6 operation:
+// the calldata cost of the ABI-encoded operation plus ov's fixed and per-op overheads.
+// Unset fee fields count as zero and an empty signature as SigSize dummy bytes.
+func PreVerificationGas(op UserOperation, ov GasOverheads) (uint64, error) {
+	packed, err := encodeForGas(op, ov)
+	if err != nil {
+		return 0, err
+	}
+	return ov.gas(packed, false), nil
+}
+
+// PreVerificationGasV07 computes the offline preVerificationGas of a v0.7 or v0.8
+// operation, encoded as the PackedUserOperation the EntryPoint receives.
+func PreVerificationGasV07(op UserOperationV07, ov GasOverheads) (uint64, error) {
+	packed, err := encodeForGasV07(op, ov)
+	if err != nil {
+		return 0, err
+	}
+	return ov.gas(packed, op.EIP7702Auth != nil), nil
+}
+
+// gas mirrors the reference calcPreVerificationGas, including its fractional word count.
+func (ov GasOverheads) gas(packed []byte, eip7702Auth bool) uint64 {
+	bundleSize := ov.BundleSize
+	if bundleSize == 0 {
+		bundleSize = 1
+	}
+	words := float64(len(packed)+31) / 32
+	total := float64(calldataGas(packed, ov.ZeroByte, ov.NonZeroByte)) +
+		float64(ov.Fixed)/float64(bundleSize) +
+		float64(ov.PerUserOp) +
+		float64(ov.PerUserOpWord)*words
+	gas := uint64(math.Round(total))
+	if eip7702Auth {
+		gas += ov.EIP7702Auth
+	}
+	return gas
+}
+
+func calldataGas(data []byte, zeroByte, nonZeroByte uint64) uint64 {
+	zeros := uint64(bytes.Count(data, []byte{0}))
+	return zeros*zeroByte + (uint64(len(data))-zeros)*nonZeroByte
+}
+
+func encodeForGas(op UserOperation, ov GasOverheads) ([]byte, error) {
+	pvg := uint64(op.PreVerificationGas)
+	if pvg == 0 {
+		pvg = placeholderPreVerificationGas
+	}
+	packed, err := packedUserOpArgsV06.Pack(
+		op.Sender,
+		bigOrZero(op.Nonce.ToInt()),
+		[]byte(op.InitCode),
+		[]byte(op.CallData),
+		new(big.Int).SetUint64(uint64(op.CallGasLimit)),
+		new(big.Int).SetUint64(uint64(op.VerificationGasLimit)),
+		new(big.Int).SetUint64(pvg),
+		bigOrZero(op.MaxFeePerGas.ToInt()),
+		bigOrZero(op.MaxPriorityFeePerGas.ToInt()),
+		[]byte(op.PaymasterAndData),
+		gasSignature(op.Signature, ov.SigSize),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("encode user operation: %w", err)
+	}
+	return packed, nil
+}
+
+func encodeForGasV07(op UserOperationV07, ov GasOverheads) ([]byte, error) {
+	op.Nonce = HexBig(bigOrZero(op.Nonce.ToInt()))
+	op.MaxFeePerGas = HexBig(bigOrZero(op.MaxFeePerGas.ToInt()))
+	op.MaxPriorityFeePerGas = HexBig(bigOrZero(op.MaxPriorityFeePerGas.ToInt()))
+	if op.PreVerificationGas == 0 {
+		op.PreVerificationGas = placeholderPreVerificationGas
+	}
+	p, err := op.Pack()
+	if err != nil {
+		return nil, err
+	}
+	packed, err := packedUserOpArgsV07.Pack(
+		p.Sender, p.Nonce, p.InitCode, p.CallData, p.AccountGasLimits,
+		p.PreVerificationGas, p.GasFees, p.PaymasterAndData,
+		gasSignature(p.Signature, ov.SigSize),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("encode user operation: %w", err)
+	}
+	return packed, nil
+}
+
+// gasSignature substitutes a non-zero dummy signature for an unsigned operation.
+func gasSignature(signature []byte, size int) []byte {
+	if len(signature) > 0 || size <= 0 {
+		return signature
+	}
+	return bytes.Repeat([]byte{0x01}, size)
+}
+
+func bigOrZero(v *big.Int) *big.Int {
+	if v == nil {
+		return new(big.Int)
+	}
+	return v
+}
+
+// ChainOverhead adds chain-specific preVerificationGas, such as an L2's L1 data fee
+// expressed in L2 gas. packed is the ABI-encoded operation the calldata cost is based on.
+type ChainOverhead interface {
+	ExtraGas(ctx context.Context, packed []byte, maxFeePerGas *big.Int) (uint64, error)
+}
+
+// ChainOverheadFunc adapts a function to ChainOverhead.
+type ChainOverheadFunc func(ctx context.Context, packed []byte, maxFeePerGas *big.Int) (uint64, error)
+
+// ExtraGas calls f.
+func (f ChainOverheadFunc) ExtraGas(ctx context.Context, packed []byte, maxFeePerGas *big.Int) (uint64, error) {
+	return f(ctx, packed, maxFeePerGas)
+}
+
+// L1DataFee estimates the L1 data fee of rollups that post calldata to L1 and
+// recovers it through preVerificationGas. The fee is
+// (calldata gas of packed + FixedGas) × L1GasPrice × Scalar, converted to L2 gas
+// at the operation's maxFeePerGas.
+type L1DataFee struct {
+	L1GasPrice *big.Int
+	// FixedGas is the operation's share of per-transaction L1 overhead.
+	FixedGas uint64
+	// Scalar adjusts for compression or fee multipliers. Zero means 1.
+	Scalar float64
+}
+
+// ExtraGas implements ChainOverhead.
+func (f L1DataFee) ExtraGas(_ context.Context, packed []byte, maxFeePerGas *big.Int) (uint64, error) {
+	if f.L1GasPrice == nil || f.L1GasPrice.Sign() == 0 {
+		return 0, nil
+	}
+	if maxFeePerGas == nil || maxFeePerGas.Sign() <= 0 {
+		return 0, errors.New("maxFeePerGas is required to convert the L1 data fee to gas")
+	}
+	scalar := f.Scalar
+	if scalar == 0 {
+		scalar = 1
+	}
+	l1Gas := new(big.Int).SetUint64(calldataGas(packed, 4, 16) + f.FixedGas)
+	fee := new(big.Float).SetInt(new(big.Int).Mul(l1Gas, f.L1GasPrice))
+	fee.Mul(fee, big.NewFloat(scalar))
+	feeWei, _ := fee.Int(nil)
+	// Round up so the bundler is never short.
+	gas := new(big.Int).Add(feeWei, new(big.Int).Sub(maxFeePerGas, big.NewInt(1)))
+	gas.Div(gas, maxFeePerGas)
+	if !gas.IsUint64() {
+		return 0, errors.New("L1 data fee does not fit in uint64 gas")
+	}
+	return gas.Uint64(), nil
+}
+
+// PreVerificationGasCalculator adds an optional ChainOverhead to the offline estimate.
+type PreVerificationGasCalculator struct {
+	// Overheads defaults to DefaultGasOverheads when zero.
+	Overheads GasOverheads
+	Chain     ChainOverhead
+}
+
+// Calculate returns preVerificationGas for a v0.6 operation.
+func (c PreVerificationGasCalculator) Calculate(ctx context.Context, op UserOperation) (uint64, error) {
+	ov := c.overheads()
+	packed, err := encodeForGas(op, ov)
+	if err != nil {
+		return 0, err
+	}
+	return c.withChain(ctx, ov.gas(packed, false), packed, op.MaxFeePerGas.ToInt())
+}
+
+// CalculateV07 returns preVerificationGas for a v0.7 or v0.8 operation.
+func (c PreVerificationGasCalculator) CalculateV07(ctx context.Context, op UserOperationV07) (uint64, error) {
+	ov := c.overheads()
+	packed, err := encodeForGasV07(op, ov)
+	if err != nil {
+		return 0, err
+	}
+	return c.withChain(ctx, ov.gas(packed, op.EIP7702Auth != nil), packed, op.MaxFeePerGas.ToInt())
+}
+
+func (c PreVerificationGasCalculator) overheads() GasOverheads {
+	if c.Overheads == (GasOverheads{}) {
+		return DefaultGasOverheads
+	}
+	return c.Overheads
+}
+
+func (c PreVerificationGasCalculator) withChain(ctx context.Context, gas uint64, packed []byte, maxFeePerGas *big.Int) (uint64, error) {
+	if c.Chain == nil {
+		return gas, nil
+	}
+	extra, err := c.Chain.ExtraGas(ctx, packed, maxFeePerGas)
+	if err != nil {
+		return 0, fmt.Errorf("chain overhead: %w", err)
+	}
+	if extra > math.MaxUint64-gas {
+		return 0, errors.New("preVerificationGas overflows uint64")
+	}
+	return gas + extra, nil
+}
@@ -0,0 +1,146 @@
+package userop_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/eipcodelab/eip7702-go/pkg/userop"
+)
+
+// referencePreVerificationGas restates calcPreVerificationGas from the reference
+// bundler for default overheads: calldata cost + 21000 + 18300 + round(4 × (len+31)/32).
+func referencePreVerificationGas(packed []byte) uint64 {
+	var cost uint64
+	for _, b := range packed {
+		if b == 0 {
+			cost += 4
+		} else {
+			cost += 16
+		}
+	}
+	return cost + 21_000 + 18_300 + (uint64(len(packed))+31+4)/8
+}
+
+func TestPreVerificationGasV06(t *testing.T) {
+	op := makeUserOp()
+	got, err := userop.PreVerificationGas(op, userop.DefaultGasOverheads)
+	if err != nil {
+		t.Fatalf("PreVerificationGas() error = %v", err)
+	}
+	packed := abiEncode(t,
+		[]string{"address", "uint256", "bytes", "bytes", "uint256", "uint256", "uint256", "uint256", "uint256", "bytes", "bytes"},
+		op.Sender, op.Nonce.ToInt(), []byte{}, []byte(op.CallData),
+		big.NewInt(100_000), big.NewInt(250_000), big.NewInt(50_000),
+		op.MaxFeePerGas.ToInt(), op.MaxPriorityFeePerGas.ToInt(), []byte{}, []byte(op.Signature),
+	)
+	if want := referencePreVerificationGas(packed); got != want {
+		t.Fatalf("preVerificationGas = %d, want %d", got, want)
+	}
+
+	// An unsigned operation is priced with a 65-byte dummy signature.
+	op.Signature = nil
+	unsigned, _ := userop.PreVerificationGas(op, userop.DefaultGasOverheads)
+	op.Signature = bytes.Repeat([]byte{0x01}, 65)
+	signed, _ := userop.PreVerificationGas(op, userop.DefaultGasOverheads)
+	if unsigned != signed || unsigned <= got {
+		t.Fatalf("unsigned = %d, signed = %d, 2-byte signature = %d", unsigned, signed, got)
+	}
+}
+
+func TestPreVerificationGasV07(t *testing.T) {
+	op := makeUserOpV07()
+	got, err := userop.PreVerificationGasV07(op, userop.DefaultGasOverheads)
+	if err != nil {
+		t.Fatalf("PreVerificationGasV07() error = %v", err)
+	}
+	p, err := op.Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	packed := abiEncode(t,
+		[]string{"address", "uint256", "bytes", "bytes", "bytes32", "uint256", "bytes32", "bytes", "bytes"},
+		p.Sender, p.Nonce, p.InitCode, p.CallData, p.AccountGasLimits, p.PreVerificationGas, p.GasFees, p.PaymasterAndData, p.Signature,
+	)
+	if want := referencePreVerificationGas(packed); got != want {
+		t.Fatalf("preVerificationGas = %d, want %d", got, want)
+	}
+
+	op.CallData = append(op.CallData, bytes.Repeat([]byte{0xff}, 64)...)
+	longer, _ := userop.PreVerificationGasV07(op, userop.DefaultGasOverheads)
+	// 64 non-zero bytes and two words, plus shifted offsets of the later dynamic fields.
+	if longer < got+64*16+8 {
+		t.Fatalf("64 more non-zero bytes cost %d, want at least %d", longer-got, 64*16+8)
+	}
+}
+
+func TestPreVerificationGasOverheads(t *testing.T) {
+	op := makeUserOpV07()
+	base, _ := userop.PreVerificationGasV07(op, userop.DefaultGasOverheads)
+
+	shared := userop.DefaultGasOverheads
+	shared.BundleSize = 10
+	if got, _ := userop.PreVerificationGasV07(op, shared); got != base-18_900 {
+		t.Fatalf("bundle of 10 = %d, want %d", got, base-18_900)
+	}
+
+	withAuth, _ := makeEIP7702UserOp(t)
+	withAuth.Factory, withAuth.EIP7702Auth = nil, nil
+	withoutAuthGas, _ := userop.PreVerificationGasV07(withAuth, userop.DefaultGasOverheads)
+	withAuth, _ = makeEIP7702UserOp(t)
+	withAuthGas, _ := userop.PreVerificationGasV07(withAuth, userop.DefaultGasOverheads)
+	// The 0x7702 initCode adds encoded bytes on top of the authorization charge.
+	if withAuthGas < withoutAuthGas+userop.DefaultGasOverheads.EIP7702Auth {
+		t.Fatalf("eip7702Auth = %d, without = %d", withAuthGas, withoutAuthGas)
+	}
+}
+
+func TestPreVerificationGasCalculatorChainOverhead(t *testing.T) {
+	op := makeUserOpV07()
+	base, _ := userop.PreVerificationGasV07(op, userop.DefaultGasOverheads)
+
+	var seen []byte
+	calc := userop.PreVerificationGasCalculator{Chain: userop.ChainOverheadFunc(func(_ context.Context, packed []byte, maxFee *big.Int) (uint64, error) {
+		seen = packed
+		if maxFee.Cmp(op.MaxFeePerGas.ToInt()) != 0 {
+			t.Errorf("maxFee = %s", maxFee)
+		}
+		return 1_000, nil
+	})}
+	got, err := calc.CalculateV07(context.Background(), op)
+	if err != nil || got != base+1_000 || len(seen) == 0 {
+		t.Fatalf("CalculateV07() = %d, %v; want %d", got, err, base+1_000)
+	}
+
+	failing := userop.PreVerificationGasCalculator{Chain: userop.ChainOverheadFunc(func(context.Context, []byte, *big.Int) (uint64, error) {
+		return 0, errors.New("oracle down")
+	})}
+	if _, err := failing.Calculate(context.Background(), makeUserOp()); err == nil {
+		t.Fatal("expected chain overhead error")
+	}
+}
+
+func TestL1DataFee(t *testing.T) {
+	packed := append(make([]byte, 10), bytes.Repeat([]byte{0xff}, 10)...) // 10×4 + 10×16 = 200 L1 gas
+	fee := userop.L1DataFee{L1GasPrice: big.NewInt(30), FixedGas: 100, Scalar: 0.5}
+	// (200 + 100) × 30 × 0.5 = 4500 wei, at 7 wei/gas rounds up to 643 gas.
+	got, err := fee.ExtraGas(context.Background(), packed, big.NewInt(7))
+	if err != nil || got != 643 {
+		t.Fatalf("ExtraGas() = %d, %v; want 643", got, err)
+	}
+	if _, err := fee.ExtraGas(context.Background(), packed, nil); err == nil {
+		t.Fatal("expected error without maxFeePerGas")
+	}
+	if got, _ := (userop.L1DataFee{}).ExtraGas(context.Background(), packed, nil); got != 0 {
+		t.Fatalf("zero L1 price = %d", got)
+	}
+
+	calc := userop.PreVerificationGasCalculator{Chain: fee}
+	op := makeUserOp()
+	base, _ := userop.PreVerificationGas(op, userop.DefaultGasOverheads)
+	if got, err := calc.Calculate(context.Background(), op); err != nil || got <= base {
+		t.Fatalf("Calculate() = %d, %v; base %d", got, err, base)
+	}
+}
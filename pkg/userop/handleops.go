@@ -0,0 +1,203 @@
+package userop
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/eipcodelab/eip7702-go/pkg/batching"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const entryPointV06ABIJSON = `[
+  {"type": "function", "name": "handleOps", "stateMutability": "nonpayable", "outputs": [], "inputs": [
+    {"name": "ops", "type": "tuple[]", "components": [
+      {"name": "sender", "type": "address"},
+      {"name": "nonce", "type": "uint256"},
+      {"name": "initCode", "type": "bytes"},
+      {"name": "callData", "type": "bytes"},
+      {"name": "callGasLimit", "type": "uint256"},
+      {"name": "verificationGasLimit", "type": "uint256"},
+      {"name": "preVerificationGas", "type": "uint256"},
+      {"name": "maxFeePerGas", "type": "uint256"},
+      {"name": "maxPriorityFeePerGas", "type": "uint256"},
+      {"name": "paymasterAndData", "type": "bytes"},
+      {"name": "signature", "type": "bytes"}
+    ]},
+    {"name": "beneficiary", "type": "address"}
+  ]},
+  {"type": "error", "name": "FailedOp", "inputs": [
+    {"name": "opIndex", "type": "uint256"},
+    {"name": "reason", "type": "string"}
+  ]}
+]`
+
+const entryPointV07ABIJSON = `[
+  {"type": "function", "name": "handleOps", "stateMutability": "nonpayable", "outputs": [], "inputs": [
+    {"name": "ops", "type": "tuple[]", "components": [
+      {"name": "sender", "type": "address"},
+      {"name": "nonce", "type": "uint256"},
+      {"name": "initCode", "type": "bytes"},
+      {"name": "callData", "type": "bytes"},
+      {"name": "accountGasLimits", "type": "bytes32"},
+      {"name": "preVerificationGas", "type": "uint256"},
+      {"name": "gasFees", "type": "bytes32"},
+      {"name": "paymasterAndData", "type": "bytes"},
+      {"name": "signature", "type": "bytes"}
+    ]},
+    {"name": "beneficiary", "type": "address"}
+  ]},
+  {"type": "error", "name": "FailedOp", "inputs": [
+    {"name": "opIndex", "type": "uint256"},
+    {"name": "reason", "type": "string"}
+  ]},
+  {"type": "error", "name": "FailedOpWithRevert", "inputs": [
+    {"name": "opIndex", "type": "uint256"},
+    {"name": "reason", "type": "string"},
+    {"name": "inner", "type": "bytes"}
+  ]}
+]`
+
+var (
+	entryPointV06ABI = mustParseABI(entryPointV06ABIJSON)
+	entryPointV07ABI = mustParseABI(entryPointV07ABIJSON)
+
+	// ErrNoOperations is returned when encoding handleOps without operations.
+	ErrNoOperations = errors.New("at least one user operation is required")
+)
+
+func mustParseABI(abiJSON string) abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(abiJSON))
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}
+
+// userOperationABI is the v0.6 UserOperation struct as the EntryPoint ABI expects it.
+type userOperationABI struct {
+	Sender               common.Address `abi:"sender"`
+	Nonce                *big.Int       `abi:"nonce"`
+	InitCode             []byte         `abi:"initCode"`
+	CallData             []byte         `abi:"callData"`
+	CallGasLimit         *big.Int       `abi:"callGasLimit"`
+	VerificationGasLimit *big.Int       `abi:"verificationGasLimit"`
+	PreVerificationGas   *big.Int       `abi:"preVerificationGas"`
+	MaxFeePerGas         *big.Int       `abi:"maxFeePerGas"`
+	MaxPriorityFeePerGas *big.Int       `abi:"maxPriorityFeePerGas"`
+	PaymasterAndData     []byte         `abi:"paymasterAndData"`
+	Signature            []byte         `abi:"signature"`
+}
+
+// EncodeHandleOps returns calldata for the v0.6 EntryPoint handleOps(ops, beneficiary).
+func EncodeHandleOps(ops []UserOperation, beneficiary common.Address) ([]byte, error) {
+	if len(ops) == 0 {
+		return nil, ErrNoOperations
+	}
+	encoded := make([]userOperationABI, len(ops))
+	for i, op := range ops {
+		if op.Nonce == nil || op.MaxFeePerGas == nil || op.MaxPriorityFeePerGas == nil {
+			return nil, fmt.Errorf("op %d: nonce and max fee fields are required", i)
+		}
+		encoded[i] = userOperationABI{
+			Sender:               op.Sender,
+			Nonce:                op.Nonce.ToInt(),
+			InitCode:             op.InitCode,
+			CallData:             op.CallData,
+			CallGasLimit:         new(big.Int).SetUint64(uint64(op.CallGasLimit)),
+			VerificationGasLimit: new(big.Int).SetUint64(uint64(op.VerificationGasLimit)),
+			PreVerificationGas:   new(big.Int).SetUint64(uint64(op.PreVerificationGas)),
+			MaxFeePerGas:         op.MaxFeePerGas.ToInt(),
+			MaxPriorityFeePerGas: op.MaxPriorityFeePerGas.ToInt(),
+			PaymasterAndData:     op.PaymasterAndData,
+			Signature:            op.Signature,
+		}
+	}
+	calldata, err := entryPointV06ABI.Pack("handleOps", encoded, beneficiary)
+	if err != nil {
+		return nil, fmt.Errorf("encode handleOps: %w", err)
+	}
+	return calldata, nil
+}
+
+// EncodeHandleOpsV07 returns calldata for the v0.7 and v0.8 EntryPoint
+// handleOps(PackedUserOperation[] ops, beneficiary).
+func EncodeHandleOpsV07(ops []UserOperationV07, beneficiary common.Address) ([]byte, error) {
+	if len(ops) == 0 {
+		return nil, ErrNoOperations
+	}
+	packed := make([]PackedUserOperation, len(ops))
+	for i, op := range ops {
+		p, err := op.Pack()
+		if err != nil {
+			return nil, fmt.Errorf("op %d: %w", i, err)
+		}
+		packed[i] = p
+	}
+	calldata, err := entryPointV07ABI.Pack("handleOps", packed, beneficiary)
+	if err != nil {
+		return nil, fmt.Errorf("encode handleOps: %w", err)
+	}
+	return calldata, nil
+}
+
+// FailedOp is the EntryPoint FailedOp or FailedOpWithRevert error. Reason carries the
+// "AAxx" code; Inner is the revert data of the account or paymaster, when reported.
+type FailedOp struct {
+	OpIndex uint64
+	Reason  string
+	Inner   []byte
+}
+
+func (e *FailedOp) Error() string {
+	if len(e.Inner) > 0 {
+		return fmt.Sprintf("op %d failed: %s: %v", e.OpIndex, e.Reason, e.Revert())
+	}
+	return fmt.Sprintf("op %d failed: %s", e.OpIndex, e.Reason)
+}
+
+// Code returns the "AAxx" prefix of Reason, or "" if there is none.
+func (e *FailedOp) Code() string {
+	if len(e.Reason) >= 4 && strings.HasPrefix(e.Reason, "AA") {
+		return e.Reason[:4]
+	}
+	return ""
+}
+
+// Revert decodes Inner, or returns nil when the EntryPoint did not report it.
+func (e *FailedOp) Revert() *batching.Revert {
+	if len(e.Inner) == 0 {
+		return nil
+	}
+	return batching.DecodeRevert(e.Inner, nil)
+}
+
+// DecodeFailedOp decodes FailedOp or FailedOpWithRevert revert data from any EntryPoint version.
+func DecodeFailedOp(data []byte) (*FailedOp, bool) {
+	if len(data) < 4 {
+		return nil, false
+	}
+	for _, abiErr := range entryPointV07ABI.Errors {
+		if !bytes.Equal(data[:4], abiErr.ID[:4]) {
+			continue
+		}
+		values, err := abiErr.Inputs.Unpack(data[4:])
+		if err != nil || len(values) < 2 {
+			return nil, false
+		}
+		index, ok := values[0].(*big.Int)
+		reason, ok2 := values[1].(string)
+		if !ok || !ok2 || !index.IsUint64() {
+			return nil, false
+		}
+		failed := &FailedOp{OpIndex: index.Uint64(), Reason: reason}
+		if len(values) > 2 {
+			failed.Inner, _ = values[2].([]byte)
+		}
+		return failed, true
+	}
+	return nil, false
+}
@@ -0,0 +1,87 @@
+package userop_test
+
+import (
+	"bytes"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/eipcodelab/eip7702-go/pkg/batching"
+	"github.com/eipcodelab/eip7702-go/pkg/userop"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestEncodeHandleOps(t *testing.T) {
+	beneficiary := common.HexToAddress("0x000000000000000000000000000000000000bEEF")
+	tests := []struct {
+		name      string
+		signature string
+		encode    func() ([]byte, error)
+	}{
+		{
+			name:      "v0.6",
+			signature: "handleOps((address,uint256,bytes,bytes,uint256,uint256,uint256,uint256,uint256,bytes,bytes)[],address)",
+			encode: func() ([]byte, error) {
+				return userop.EncodeHandleOps([]userop.UserOperation{makeUserOp(), makeUserOp()}, beneficiary)
+			},
+		},
+		{
+			name:      "v0.7",
+			signature: "handleOps((address,uint256,bytes,bytes,bytes32,uint256,bytes32,bytes,bytes)[],address)",
+			encode: func() ([]byte, error) {
+				return userop.EncodeHandleOpsV07([]userop.UserOperationV07{makeUserOpV07(), makeUserOpV07()}, beneficiary)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calldata, err := tt.encode()
+			if err != nil {
+				t.Fatalf("encode error = %v", err)
+			}
+			if !bytes.Equal(calldata[:4], crypto.Keccak256([]byte(tt.signature))[:4]) {
+				t.Fatalf("selector = %x", calldata[:4])
+			}
+			args := calldata[4:]
+			if common.BytesToAddress(args[32:64]) != beneficiary {
+				t.Fatalf("beneficiary word = %x", args[32:64])
+			}
+			offset := new(big.Int).SetBytes(args[:32]).Uint64()
+			if n := new(big.Int).SetBytes(args[offset : offset+32]).Uint64(); n != 2 {
+				t.Fatalf("ops length = %d, want 2", n)
+			}
+		})
+	}
+
+	if _, err := userop.EncodeHandleOpsV07(nil, beneficiary); err != userop.ErrNoOperations {
+		t.Fatalf("empty bundle error = %v", err)
+	}
+}
+
+func TestDecodeFailedOp(t *testing.T) {
+	failedOp := append(crypto.Keccak256([]byte("FailedOp(uint256,string)"))[:4],
+		abiEncode(t, []string{"uint256", "string"}, big.NewInt(1), "AA21 didn't pay prefund")...)
+	got, ok := userop.DecodeFailedOp(failedOp)
+	if !ok || got.OpIndex != 1 || got.Code() != "AA21" || got.Revert() != nil {
+		t.Fatalf("DecodeFailedOp() = %+v, %v", got, ok)
+	}
+
+	inner, err := batching.EncodeFunctionCall(`[{"type":"function","name":"Error","inputs":[{"name":"","type":"string"}]}]`, "Error", "bad signature")
+	if err != nil {
+		t.Fatal(err)
+	}
+	withRevert := append(crypto.Keccak256([]byte("FailedOpWithRevert(uint256,string,bytes)"))[:4],
+		abiEncode(t, []string{"uint256", "string", "bytes"}, big.NewInt(0), "AA23 reverted", inner)...)
+	got, ok = userop.DecodeFailedOp(withRevert)
+	if !ok || got.Code() != "AA23" || got.Revert() == nil || got.Revert().Message != "bad signature" {
+		t.Fatalf("DecodeFailedOp(with revert) = %+v, %v", got, ok)
+	}
+	if msg := got.Error(); !strings.Contains(msg, "op 0 failed: AA23 reverted") || !strings.Contains(msg, "bad signature") {
+		t.Fatalf("Error() = %q", got.Error())
+	}
+
+	if _, ok := userop.DecodeFailedOp(inner); ok {
+		t.Fatal("Error(string) is not a FailedOp")
+	}
+}
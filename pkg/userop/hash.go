@@ -0,0 +1,140 @@
+package userop
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var (
+	// packedUserOpTypeHash is the EIP-712 type hash used by the v0.8 EntryPoint.
+	packedUserOpTypeHash = crypto.Keccak256Hash([]byte("PackedUserOperation(address sender,uint256 nonce,bytes initCode,bytes callData,bytes32 accountGasLimits,uint256 preVerificationGas,bytes32 gasFees,bytes paymasterAndData)"))
+	eip712DomainTypeHash = crypto.Keccak256Hash([]byte("EIP712Domain(string name,string version,uint256 chainId,address verifyingContract)"))
+
+	errNilHashChainID = errors.New("chain id is required")
+)
+
+const (
+	// UserOpDomainName and UserOpDomainVersion form the v0.8 EntryPoint EIP-712 domain.
+	UserOpDomainName    = "ERC4337"
+	UserOpDomainVersion = "1"
+)
+
+// UserOpHash computes the v0.6 userOp hash:
+// keccak256(abi.encode(keccak256(pack(op)), entryPoint, chainId)).
+func UserOpHash(op UserOperation, entryPoint common.Address, chainID *big.Int) (common.Hash, error) {
+	if chainID == nil {
+		return common.Hash{}, errNilHashChainID
+	}
+	if op.Nonce == nil || op.MaxFeePerGas == nil || op.MaxPriorityFeePerGas == nil {
+		return common.Hash{}, errors.New("nonce and max fee fields are required")
+	}
+	inner := crypto.Keccak256(
+		addressWord(op.Sender),
+		uintWord(op.Nonce.ToInt()),
+		crypto.Keccak256(op.InitCode),
+		crypto.Keccak256(op.CallData),
+		uint64Word(uint64(op.CallGasLimit)),
+		uint64Word(uint64(op.VerificationGasLimit)),
+		uint64Word(uint64(op.PreVerificationGas)),
+		uintWord(op.MaxFeePerGas.ToInt()),
+		uintWord(op.MaxPriorityFeePerGas.ToInt()),
+		crypto.Keccak256(op.PaymasterAndData),
+	)
+	return crypto.Keccak256Hash(inner, addressWord(entryPoint), uintWord(chainID)), nil
+}
+
+// UserOpHashV07 computes the v0.7 userOp hash over the packed operation:
+// keccak256(abi.encode(keccak256(pack(op)), entryPoint, chainId)).
+func UserOpHashV07(op UserOperationV07, entryPoint common.Address, chainID *big.Int) (common.Hash, error) {
+	if chainID == nil {
+		return common.Hash{}, errNilHashChainID
+	}
+	packed, err := op.Pack()
+	if err != nil {
+		return common.Hash{}, err
+	}
+	inner := crypto.Keccak256(packedUserOpWords(packed, crypto.Keccak256(packed.InitCode))...)
+	return crypto.Keccak256Hash(inner, addressWord(entryPoint), uintWord(chainID)), nil
+}
+
+// UserOpHashV08 computes the v0.8 userOp hash as the EIP-712 digest of the
+// PackedUserOperation under the ("ERC4337", "1", chainId, entryPoint) domain.
+// A 0x7702 initCode is hashed with the delegate from op.EIP7702Auth.
+func UserOpHashV08(op UserOperationV07, entryPoint common.Address, chainID *big.Int) (common.Hash, error) {
+	var delegate *common.Address
+	if op.EIP7702Auth != nil {
+		delegate = &op.EIP7702Auth.Address
+	}
+	return userOpHashV08(op, delegate, entryPoint, chainID)
+}
+
+// UserOpHashV08WithDelegate hashes an operation from an already-delegated account
+// whose 0x7702 initCode must be hashed with the account's current delegate.
+func UserOpHashV08WithDelegate(op UserOperationV07, delegate common.Address, entryPoint common.Address, chainID *big.Int) (common.Hash, error) {
+	return userOpHashV08(op, &delegate, entryPoint, chainID)
+}
+
+func userOpHashV08(op UserOperationV07, delegate *common.Address, entryPoint common.Address, chainID *big.Int) (common.Hash, error) {
+	if chainID == nil {
+		return common.Hash{}, errNilHashChainID
+	}
+	packed, err := op.Pack()
+	if err != nil {
+		return common.Hash{}, err
+	}
+	initCodeHash := crypto.Keccak256(packed.InitCode)
+	if IsEIP7702InitCode(packed.InitCode) {
+		if delegate == nil {
+			return common.Hash{}, ErrMissingEIP7702Delegate
+		}
+		initCodeHash = eip7702InitCodeHash(packed.InitCode, *delegate)
+	}
+	words := append([][]byte{packedUserOpTypeHash[:]}, packedUserOpWords(packed, initCodeHash)...)
+	structHash := crypto.Keccak256(words...)
+	return crypto.Keccak256Hash([]byte{0x19, 0x01}, UserOpDomainSeparator(entryPoint, chainID).Bytes(), structHash), nil
+}
+
+// UserOpDomainSeparator returns the v0.8 EntryPoint EIP-712 domain separator.
+func UserOpDomainSeparator(entryPoint common.Address, chainID *big.Int) common.Hash {
+	return crypto.Keccak256Hash(
+		eip712DomainTypeHash[:],
+		crypto.Keccak256([]byte(UserOpDomainName)),
+		crypto.Keccak256([]byte(UserOpDomainVersion)),
+		uintWord(chainID),
+		addressWord(entryPoint),
+	)
+}
+
+// packedUserOpWords is the abi.encode of the packed fields with dynamic fields hashed.
+// Signature is excluded, as in UserOperationLib.encode.
+func packedUserOpWords(p PackedUserOperation, initCodeHash []byte) [][]byte {
+	return [][]byte{
+		addressWord(p.Sender),
+		uintWord(p.Nonce),
+		initCodeHash,
+		crypto.Keccak256(p.CallData),
+		p.AccountGasLimits[:],
+		uintWord(p.PreVerificationGas),
+		p.GasFees[:],
+		crypto.Keccak256(p.PaymasterAndData),
+	}
+}
+
+func addressWord(addr common.Address) []byte {
+	return common.LeftPadBytes(addr.Bytes(), 32)
+}
+
+func uintWord(v *big.Int) []byte {
+	if v == nil {
+		return make([]byte, 32)
+	}
+	return math.U256Bytes(new(big.Int).Set(v))
+}
+
+func uint64Word(v uint64) []byte {
+	return uintWord(new(big.Int).SetUint64(v))
+}
@@ -0,0 +1,251 @@
+package userop_test
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/eipcodelab/eip7702-go/pkg/userop"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+func abiEncode(t *testing.T, types []string, values ...any) []byte {
+	t.Helper()
+	args := make(abi.Arguments, len(types))
+	for i, name := range types {
+		typ, err := abi.NewType(name, "", nil)
+		if err != nil {
+			t.Fatalf("abi type %s: %v", name, err)
+		}
+		args[i] = abi.Argument{Type: typ}
+	}
+	out, err := args.Pack(values...)
+	if err != nil {
+		t.Fatalf("abi encode: %v", err)
+	}
+	return out
+}
+
+// referenceOuterHash mirrors EntryPoint.getUserOpHash for v0.6 and v0.7.
+func referenceOuterHash(t *testing.T, inner []byte, entryPoint common.Address, chainID *big.Int) common.Hash {
+	return crypto.Keccak256Hash(abiEncode(t, []string{"bytes32", "address", "uint256"}, common.BytesToHash(inner), entryPoint, chainID))
+}
+
+func TestUserOpHashV06MatchesReferencePacking(t *testing.T) {
+	op := makeUserOp()
+	op.InitCode = []byte{0x01, 0x02, 0x03}
+	op.PaymasterAndData = []byte{0x04}
+	entryPoint, chainID := userop.EntryPointV06Address, big.NewInt(11155111)
+
+	inner := crypto.Keccak256(abiEncode(t,
+		[]string{"address", "uint256", "bytes32", "bytes32", "uint256", "uint256", "uint256", "uint256", "uint256", "bytes32"},
+		op.Sender, op.Nonce.ToInt(),
+		crypto.Keccak256Hash(op.InitCode), crypto.Keccak256Hash(op.CallData),
+		new(big.Int).SetUint64(uint64(op.CallGasLimit)),
+		new(big.Int).SetUint64(uint64(op.VerificationGasLimit)),
+		new(big.Int).SetUint64(uint64(op.PreVerificationGas)),
+		op.MaxFeePerGas.ToInt(), op.MaxPriorityFeePerGas.ToInt(),
+		crypto.Keccak256Hash(op.PaymasterAndData),
+	))
+	want := referenceOuterHash(t, inner, entryPoint, chainID)
+
+	got, err := userop.UserOpHash(op, entryPoint, chainID)
+	if err != nil {
+		t.Fatalf("hash: %v", err)
+	}
+	if got != want {
+		t.Fatalf("hash mismatch: got %s want %s", got.Hex(), want.Hex())
+	}
+
+	op.Signature = []byte{0x01}
+	if again, _ := userop.UserOpHash(op, entryPoint, chainID); again != got {
+		t.Fatal("signature must not affect the userOp hash")
+	}
+	if other, _ := userop.UserOpHash(op, entryPoint, big.NewInt(1)); other == got {
+		t.Fatal("chain id must affect the userOp hash")
+	}
+}
+
+func TestUserOpHashV07MatchesReferencePacking(t *testing.T) {
+	op := makeUserOpV07()
+	entryPoint, chainID := userop.EntryPointV07Address, big.NewInt(1)
+	packed, err := op.Pack()
+	if err != nil {
+		t.Fatalf("pack: %v", err)
+	}
+
+	inner := crypto.Keccak256(abiEncode(t,
+		[]string{"address", "uint256", "bytes32", "bytes32", "bytes32", "uint256", "bytes32", "bytes32"},
+		packed.Sender, packed.Nonce,
+		crypto.Keccak256Hash(packed.InitCode), crypto.Keccak256Hash(packed.CallData),
+		packed.AccountGasLimits, packed.PreVerificationGas, packed.GasFees,
+		crypto.Keccak256Hash(packed.PaymasterAndData),
+	))
+	want := referenceOuterHash(t, inner, entryPoint, chainID)
+
+	got, err := userop.UserOpHashV07(op, entryPoint, chainID)
+	if err != nil {
+		t.Fatalf("hash: %v", err)
+	}
+	if got != want {
+		t.Fatalf("hash mismatch: got %s want %s", got.Hex(), want.Hex())
+	}
+}
+
+func TestUserOpHashV08MatchesTypedData(t *testing.T) {
+	op := makeUserOpV07()
+	entryPoint, chainID := userop.EntryPointV08Address, big.NewInt(8453)
+	packed, err := op.Pack()
+	if err != nil {
+		t.Fatalf("pack: %v", err)
+	}
+
+	typedData := apitypes.TypedData{
+		Types: apitypes.Types{
+			"EIP712Domain": {
+				{Name: "name", Type: "string"},
+				{Name: "version", Type: "string"},
+				{Name: "chainId", Type: "uint256"},
+				{Name: "verifyingContract", Type: "address"},
+			},
+			"PackedUserOperation": {
+				{Name: "sender", Type: "address"},
+				{Name: "nonce", Type: "uint256"},
+				{Name: "initCode", Type: "bytes"},
+				{Name: "callData", Type: "bytes"},
+				{Name: "accountGasLimits", Type: "bytes32"},
+				{Name: "preVerificationGas", Type: "uint256"},
+				{Name: "gasFees", Type: "bytes32"},
+				{Name: "paymasterAndData", Type: "bytes"},
+			},
+		},
+		PrimaryType: "PackedUserOperation",
+		Domain: apitypes.TypedDataDomain{
+			Name:              "ERC4337",
+			Version:           "1",
+			ChainId:           (*math.HexOrDecimal256)(chainID),
+			VerifyingContract: entryPoint.Hex(),
+		},
+		Message: apitypes.TypedDataMessage{
+			"sender":             packed.Sender.Hex(),
+			"nonce":              packed.Nonce.String(),
+			"initCode":           hexutil.Encode(packed.InitCode),
+			"callData":           hexutil.Encode(packed.CallData),
+			"accountGasLimits":   hexutil.Encode(packed.AccountGasLimits[:]),
+			"preVerificationGas": packed.PreVerificationGas.String(),
+			"gasFees":            hexutil.Encode(packed.GasFees[:]),
+			"paymasterAndData":   hexutil.Encode(packed.PaymasterAndData),
+		},
+	}
+	want, _, err := apitypes.TypedDataAndHash(typedData)
+	if err != nil {
+		t.Fatalf("typed data hash: %v", err)
+	}
+
+	got, err := userop.UserOpHashV08(op, entryPoint, chainID)
+	if err != nil {
+		t.Fatalf("hash: %v", err)
+	}
+	if !bytes.Equal(got[:], want) {
+		t.Fatalf("hash mismatch: got %s want %s", got.Hex(), hexutil.Encode(want))
+	}
+	if v07, _ := userop.UserOpHashV07(op, entryPoint, chainID); v07 == got {
+		t.Fatal("v0.7 and v0.8 hashes must differ")
+	}
+}
+
+// Fixed vectors for getUserOpHash on the canonical EntryPoints. Expected hashes
+// were computed outside this package from the EntryPoint Solidity encoding.
+func TestUserOpHashVectors(t *testing.T) {
+	addr := common.HexToAddress
+	factory, paymaster := addr("0x2222222222222222222222222222222222222222"), addr("0x3333333333333333333333333333333333333333")
+	marker := userop.EIP7702FactoryMarker
+	gwei := big.NewInt(1_000_000_000)
+
+	v06 := userop.UserOperation{
+		Sender:               addr("0x1111111111111111111111111111111111111111"),
+		Nonce:                userop.HexBig(big.NewInt(42)),
+		InitCode:             common.FromHex("0x2222222222222222222222222222222222222222deadbeef"),
+		CallData:             common.FromHex("0xb61d27f6"),
+		CallGasLimit:         100_000,
+		VerificationGasLimit: 150_000,
+		PreVerificationGas:   21_000,
+		MaxFeePerGas:         userop.HexBig(new(big.Int).Mul(big.NewInt(30), gwei)),
+		MaxPriorityFeePerGas: userop.HexBig(gwei),
+		Signature:            []byte{0xff},
+	}
+	v07 := userop.UserOperationV07{
+		Sender:                        v06.Sender,
+		Nonce:                         v06.Nonce,
+		Factory:                       &factory,
+		FactoryData:                   common.FromHex("0xdeadbeef"),
+		CallData:                      v06.CallData,
+		CallGasLimit:                  v06.CallGasLimit,
+		VerificationGasLimit:          v06.VerificationGasLimit,
+		PreVerificationGas:            v06.PreVerificationGas,
+		MaxFeePerGas:                  v06.MaxFeePerGas,
+		MaxPriorityFeePerGas:          v06.MaxPriorityFeePerGas,
+		Paymaster:                     &paymaster,
+		PaymasterVerificationGasLimit: 50_000,
+		PaymasterPostOpGasLimit:       10_000,
+		PaymasterData:                 common.FromHex("0xcafe"),
+		Signature:                     []byte{0xff},
+	}
+	delegated := userop.UserOperationV07{
+		Sender:               v06.Sender,
+		Nonce:                userop.HexBig(big.NewInt(0)),
+		Factory:              &marker,
+		CallData:             v06.CallData,
+		CallGasLimit:         v06.CallGasLimit,
+		VerificationGasLimit: v06.VerificationGasLimit,
+		PreVerificationGas:   v06.PreVerificationGas,
+		MaxFeePerGas:         v06.MaxFeePerGas,
+		MaxPriorityFeePerGas: v06.MaxPriorityFeePerGas,
+		EIP7702Auth: &userop.EIP7702Auth{
+			ChainID: userop.HexBig(big.NewInt(8453)),
+			Address: addr("0x4444444444444444444444444444444444444444"),
+			R:       userop.HexBig(big.NewInt(1)),
+			S:       userop.HexBig(big.NewInt(2)),
+		},
+	}
+	delegatedWithData := delegated
+	delegatedWithData.FactoryData = common.FromHex("0x01020304")
+
+	tests := []struct {
+		name string
+		hash func() (common.Hash, error)
+		want string
+	}{
+		{"v0.6", func() (common.Hash, error) {
+			return userop.UserOpHash(v06, userop.EntryPointV06Address, big.NewInt(1))
+		}, "0x449d6f5521bd1c0075dca55a0613756984d21df8a21e602fa649023d27dec365"},
+		{"v0.7", func() (common.Hash, error) {
+			return userop.UserOpHashV07(v07, userop.EntryPointV07Address, big.NewInt(11155111))
+		}, "0x24102b9fa27ab5ddcb0bfaf1c5e6403c85ceecccec91bd9df4299e1d9d7a702e"},
+		{"v0.8", func() (common.Hash, error) {
+			return userop.UserOpHashV08(v07, userop.EntryPointV08Address, big.NewInt(8453))
+		}, "0xdfce3246a584a66b4ca487a8613feb698a0c74aae5ec8794e1c334f487b25bb4"},
+		{"v0.8 eip7702Auth", func() (common.Hash, error) {
+			return userop.UserOpHashV08(delegated, userop.EntryPointV08Address, big.NewInt(8453))
+		}, "0xa605c0c2a45f33e16ccad5608c02f7261c79725bd362868e63d643ba8573b2b7"},
+		{"v0.8 eip7702Auth with initCode data", func() (common.Hash, error) {
+			return userop.UserOpHashV08(delegatedWithData, userop.EntryPointV08Address, big.NewInt(8453))
+		}, "0x24d6359c0136bd367412f1695b6337d720db5be210d26b474b82e800fd322e44"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.hash()
+			if err != nil {
+				t.Fatalf("hash: %v", err)
+			}
+			if got != common.HexToHash(tt.want) {
+				t.Fatalf("hash = %s, want %s", got.Hex(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,274 @@
+package userop
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// NonceKeyBits is the width of the key half of an ERC-4337 nonce (key<<64 | sequence).
+const NonceKeyBits = 192
+
+var (
+	getNonceSelector = crypto.Keccak256([]byte("getNonce(address,uint192)"))[:4]
+	maxNonceKey      = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), NonceKeyBits), big.NewInt(1))
+
+	// ErrNonceKey is returned for nonce keys that do not fit in 192 bits.
+	ErrNonceKey = errors.New("nonce key must be between 0 and 2^192-1")
+)
+
+// ContractCaller executes eth_call. *ethclient.Client and *BundlerClient implement it.
+type ContractCaller interface {
+	CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error)
+}
+
+// CallContract executes eth_call against the endpoint. Many bundlers proxy it to their node.
+// A nil blockNumber reads the latest block.
+func (c *BundlerClient) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	arg := map[string]any{"data": hexutil.Bytes(call.Data)}
+	if call.To != nil {
+		arg["to"] = *call.To
+	}
+	if call.From != (common.Address{}) {
+		arg["from"] = call.From
+	}
+	if call.Value != nil {
+		arg["value"] = HexBig(call.Value)
+	}
+	if call.Gas != 0 {
+		arg["gas"] = hexutil.Uint64(call.Gas)
+	}
+	block := "latest"
+	if blockNumber != nil {
+		block = hexutil.EncodeBig(blockNumber)
+	}
+	var out hexutil.Bytes
+	if err := c.Call(ctx, &out, "eth_call", arg, block); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// EncodeNonce returns key<<64 | sequence.
+func EncodeNonce(key *big.Int, sequence uint64) (*big.Int, error) {
+	if err := checkNonceKey(key); err != nil {
+		return nil, err
+	}
+	nonce := new(big.Int).Lsh(key, 64)
+	return nonce.Or(nonce, new(big.Int).SetUint64(sequence)), nil
+}
+
+// DecodeNonce splits an ERC-4337 nonce into its key and sequence.
+func DecodeNonce(nonce *big.Int) (key *big.Int, sequence uint64) {
+	if nonce == nil {
+		return new(big.Int), 0
+	}
+	key = new(big.Int).Rsh(nonce, 64)
+	sequence = new(big.Int).And(nonce, new(big.Int).SetUint64(^uint64(0))).Uint64()
+	return key, sequence
+}
+
+// GetNonce reads EntryPoint.getNonce(sender, key), the next valid nonce for the key.
+func GetNonce(ctx context.Context, caller ContractCaller, entryPoint, sender common.Address, key *big.Int) (*big.Int, error) {
+	if err := checkNonceKey(key); err != nil {
+		return nil, err
+	}
+	data := make([]byte, 0, 4+2*32)
+	data = append(data, getNonceSelector...)
+	data = append(data, common.LeftPadBytes(sender.Bytes(), 32)...)
+	data = append(data, common.LeftPadBytes(key.Bytes(), 32)...)
+	out, err := caller.CallContract(ctx, ethereum.CallMsg{To: &entryPoint, Data: data}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("getNonce: %w", err)
+	}
+	if len(out) != 32 {
+		return nil, fmt.Errorf("getNonce: expected 32 bytes, got %d", len(out))
+	}
+	return new(big.Int).SetBytes(out), nil
+}
+
+func checkNonceKey(key *big.Int) error {
+	if key == nil || key.Sign() < 0 || key.Cmp(maxNonceKey) > 0 {
+		return ErrNonceKey
+	}
+	return nil
+}
+
+// NonceManager hands out ERC-4337 nonces for senders that submit several
+// operations at once. Each nonce key is an independent lane: operations in
+// different lanes never block each other, while sequences within a lane are
+// allocated in order and tracked until they are confirmed or fail.
+// It is safe for concurrent use by multiple goroutines.
+type NonceManager struct {
+	caller     ContractCaller
+	entryPoint common.Address
+
+	mu    sync.Mutex
+	lanes map[nonceLaneID]*nonceLane
+}
+
+type nonceLaneID struct {
+	sender common.Address
+	key    string
+}
+
+type nonceLane struct {
+	synced   bool
+	next     uint64
+	inFlight map[uint64]struct{}
+	// confirmed is one past the highest confirmed sequence, so a lagging node
+	// cannot move the lane back onto used nonces.
+	confirmed uint64
+	// free holds sequences released by failed operations below next, in ascending order.
+	free []uint64
+}
+
+// NewNonceManager creates a manager that reads on-chain nonces from entryPoint through caller.
+func NewNonceManager(caller ContractCaller, entryPoint common.Address) *NonceManager {
+	return &NonceManager{caller: caller, entryPoint: entryPoint, lanes: make(map[nonceLaneID]*nonceLane)}
+}
+
+// Next allocates the next nonce in the sender's lane for key. The first call
+// for a lane, and the first call after a failure, resyncs with getNonce.
+// Every nonce returned must later be passed to Confirm or Fail.
+func (m *NonceManager) Next(ctx context.Context, sender common.Address, key *big.Int) (*big.Int, error) {
+	if err := checkNonceKey(key); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	lane := m.lane(sender, key)
+	synced := lane.synced
+	m.mu.Unlock()
+	if !synced {
+		if err := m.Resync(ctx, sender, key); err != nil {
+			return nil, err
+		}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var seq uint64
+	if len(lane.free) > 0 {
+		seq, lane.free = lane.free[0], lane.free[1:]
+	} else {
+		seq = lane.next
+		lane.next++
+	}
+	lane.inFlight[seq] = struct{}{}
+	return EncodeNonce(key, seq)
+}
+
+// NextInLanes allocates a nonce in whichever of keys has the fewest operations
+// in flight, preferring earlier keys on ties, so callers can spread parallel
+// operations over a fixed set of lanes.
+func (m *NonceManager) NextInLanes(ctx context.Context, sender common.Address, keys []*big.Int) (*big.Int, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("at least one nonce key is required")
+	}
+	for _, key := range keys {
+		if err := checkNonceKey(key); err != nil {
+			return nil, err
+		}
+	}
+	m.mu.Lock()
+	best := keys[0]
+	bestCount := len(m.lane(sender, best).inFlight)
+	for _, key := range keys[1:] {
+		if n := len(m.lane(sender, key).inFlight); n < bestCount {
+			best, bestCount = key, n
+		}
+	}
+	m.mu.Unlock()
+	return m.Next(ctx, sender, best)
+}
+
+// Confirm marks nonce as included on-chain.
+func (m *NonceManager) Confirm(sender common.Address, nonce *big.Int) {
+	key, seq := DecodeNonce(nonce)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	lane := m.lane(sender, key)
+	delete(lane.inFlight, seq)
+	lane.confirmed = max(lane.confirmed, seq+1)
+}
+
+// Fail releases a nonce whose operation was rejected or dropped. The sequence
+// is handed out again before any new one, and the lane resyncs on its next use
+// in case the operation reached the chain after all.
+func (m *NonceManager) Fail(sender common.Address, nonce *big.Int) {
+	key, seq := DecodeNonce(nonce)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	lane := m.lane(sender, key)
+	if _, ok := lane.inFlight[seq]; !ok {
+		return
+	}
+	delete(lane.inFlight, seq)
+	lane.synced = false
+	if seq+1 == lane.next {
+		lane.next--
+		// Trailing free sequences are now at the end of the lane too.
+		for len(lane.free) > 0 && lane.free[len(lane.free)-1]+1 == lane.next {
+			lane.free = lane.free[:len(lane.free)-1]
+			lane.next--
+		}
+		return
+	}
+	i, _ := slices.BinarySearch(lane.free, seq)
+	lane.free = slices.Insert(lane.free, i, seq)
+}
+
+// Resync reads the on-chain nonce for the lane. Sequences below it have been
+// used and are dropped from tracking; operations still in flight keep their
+// sequences so they are never handed out twice.
+func (m *NonceManager) Resync(ctx context.Context, sender common.Address, key *big.Int) error {
+	onChain, err := GetNonce(ctx, m.caller, m.entryPoint, sender, key)
+	if err != nil {
+		return err
+	}
+	_, chainSeq := DecodeNonce(onChain)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	lane := m.lane(sender, key)
+	chainSeq = max(chainSeq, lane.confirmed)
+	for seq := range lane.inFlight {
+		if seq < chainSeq {
+			delete(lane.inFlight, seq)
+		}
+	}
+	lane.free = slices.DeleteFunc(lane.free, func(seq uint64) bool { return seq < chainSeq })
+	if len(lane.inFlight) == 0 {
+		// Nothing pending locally, so the chain is authoritative.
+		lane.next = chainSeq
+		lane.free = nil
+	} else if lane.next < chainSeq {
+		lane.next = chainSeq
+	}
+	lane.synced = true
+	return nil
+}
+
+// InFlight returns how many nonces of the lane are allocated but not yet confirmed or failed.
+func (m *NonceManager) InFlight(sender common.Address, key *big.Int) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.lane(sender, key).inFlight)
+}
+
+// lane returns the state for (sender, key), creating it. m.mu must be held.
+func (m *NonceManager) lane(sender common.Address, key *big.Int) *nonceLane {
+	id := nonceLaneID{sender: sender, key: key.String()}
+	lane, ok := m.lanes[id]
+	if !ok {
+		lane = &nonceLane{inFlight: make(map[uint64]struct{})}
+		m.lanes[id] = lane
+	}
+	return lane
+}
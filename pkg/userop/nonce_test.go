@@ -0,0 +1,227 @@
+package userop_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+
+	"github.com/eipcodelab/eip7702-go/pkg/userop"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// fakeNonceCaller answers getNonce with a settable sequence for every key.
+type fakeNonceCaller struct {
+	mu    sync.Mutex
+	seq   uint64
+	calls int
+	last  ethereum.CallMsg
+}
+
+func (f *fakeNonceCaller) CallContract(_ context.Context, call ethereum.CallMsg, _ *big.Int) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	f.last = call
+	key := new(big.Int).SetBytes(call.Data[36:68])
+	nonce, err := userop.EncodeNonce(key, f.seq)
+	if err != nil {
+		return nil, err
+	}
+	return common.LeftPadBytes(nonce.Bytes(), 32), nil
+}
+
+func (f *fakeNonceCaller) set(seq uint64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.seq = seq
+}
+
+func sequences(t *testing.T, m *userop.NonceManager, sender common.Address, key *big.Int, n int) []uint64 {
+	t.Helper()
+	out := make([]uint64, n)
+	for i := range out {
+		nonce, err := m.Next(context.Background(), sender, key)
+		if err != nil {
+			t.Fatalf("Next() error = %v", err)
+		}
+		k, seq := userop.DecodeNonce(nonce)
+		if k.Cmp(key) != 0 {
+			t.Fatalf("key = %s, want %s", k, key)
+		}
+		out[i] = seq
+	}
+	return out
+}
+
+func mustNonce(t *testing.T, key int64, seq uint64) *big.Int {
+	t.Helper()
+	nonce, err := userop.EncodeNonce(big.NewInt(key), seq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return nonce
+}
+
+func TestEncodeDecodeNonce(t *testing.T) {
+	key, _ := new(big.Int).SetString("123456789abcdef", 16)
+	nonce, err := userop.EncodeNonce(key, 42)
+	if err != nil {
+		t.Fatalf("EncodeNonce() error = %v", err)
+	}
+	if want, _ := new(big.Int).SetString("123456789abcdef000000000000002a", 16); nonce.Cmp(want) != 0 {
+		t.Fatalf("nonce = %x, want %x", nonce, want)
+	}
+	gotKey, seq := userop.DecodeNonce(nonce)
+	if gotKey.Cmp(key) != 0 || seq != 42 {
+		t.Fatalf("DecodeNonce() = %s, %d", gotKey, seq)
+	}
+	tooBig := new(big.Int).Lsh(big.NewInt(1), userop.NonceKeyBits)
+	if _, err := userop.EncodeNonce(tooBig, 0); !errors.Is(err, userop.ErrNonceKey) {
+		t.Fatalf("error = %v, want ErrNonceKey", err)
+	}
+}
+
+func TestGetNonce(t *testing.T) {
+	caller := &fakeNonceCaller{seq: 9}
+	sender := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	nonce, err := userop.GetNonce(context.Background(), caller, userop.EntryPointV07Address, sender, big.NewInt(3))
+	if err != nil {
+		t.Fatalf("GetNonce() error = %v", err)
+	}
+	if nonce.Cmp(mustNonce(t, 3, 9)) != 0 {
+		t.Fatalf("nonce = %s", nonce)
+	}
+	want := crypto.Keccak256([]byte("getNonce(address,uint192)"))[:4]
+	want = append(want, common.LeftPadBytes(sender.Bytes(), 32)...)
+	want = append(want, common.LeftPadBytes([]byte{3}, 32)...)
+	if *caller.last.To != userop.EntryPointV07Address || !bytes.Equal(caller.last.Data, want) {
+		t.Fatalf("call = %+v", caller.last)
+	}
+}
+
+func TestNonceManagerAllocatesPerLane(t *testing.T) {
+	caller := &fakeNonceCaller{seq: 5}
+	m := userop.NewNonceManager(caller, userop.EntryPointV07Address)
+	sender := common.HexToAddress("0x1111111111111111111111111111111111111111")
+
+	if got := sequences(t, m, sender, big.NewInt(0), 3); got[0] != 5 || got[2] != 7 {
+		t.Fatalf("lane 0 = %v", got)
+	}
+	if got := sequences(t, m, sender, big.NewInt(1), 1); got[0] != 5 {
+		t.Fatalf("lane 1 = %v", got)
+	}
+	if caller.calls != 2 {
+		t.Fatalf("getNonce calls = %d, want one per lane", caller.calls)
+	}
+	if n := m.InFlight(sender, big.NewInt(0)); n != 3 {
+		t.Fatalf("InFlight() = %d", n)
+	}
+}
+
+func TestNonceManagerFailAndResync(t *testing.T) {
+	caller := &fakeNonceCaller{}
+	m := userop.NewNonceManager(caller, userop.EntryPointV07Address)
+	sender := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	key := big.NewInt(0)
+	sequences(t, m, sender, key, 4) // 0..3
+
+	// A failure in the middle leaves a gap that is filled first.
+	m.Fail(sender, mustNonce(t, 0, 1))
+	if got := sequences(t, m, sender, key, 2); got[0] != 1 || got[1] != 4 {
+		t.Fatalf("after middle failure = %v", got)
+	}
+	// Failing the newest nonces rolls the lane back.
+	m.Fail(sender, mustNonce(t, 0, 4))
+	m.Fail(sender, mustNonce(t, 0, 3))
+	if got := sequences(t, m, sender, key, 1); got[0] != 3 {
+		t.Fatalf("after tail failure = %v", got)
+	}
+
+	// Operations landed elsewhere: the resync skips past them.
+	for seq := uint64(0); seq < 4; seq++ {
+		m.Confirm(sender, mustNonce(t, 0, seq))
+	}
+	caller.set(10)
+	if err := m.Resync(context.Background(), sender, key); err != nil {
+		t.Fatalf("Resync() error = %v", err)
+	}
+	if got := sequences(t, m, sender, key, 1); got[0] != 10 {
+		t.Fatalf("after resync = %v", got)
+	}
+}
+
+func TestNonceManagerIgnoresLaggingNode(t *testing.T) {
+	caller := &fakeNonceCaller{}
+	m := userop.NewNonceManager(caller, userop.EntryPointV07Address)
+	sender := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	key := big.NewInt(0)
+	sequences(t, m, sender, key, 2)
+	m.Confirm(sender, mustNonce(t, 0, 0))
+	m.Confirm(sender, mustNonce(t, 0, 1))
+
+	// The node still reports 0, but 0 and 1 are confirmed.
+	if err := m.Resync(context.Background(), sender, key); err != nil {
+		t.Fatal(err)
+	}
+	if got := sequences(t, m, sender, key, 1); got[0] != 2 {
+		t.Fatalf("after stale resync = %v", got)
+	}
+}
+
+func TestNonceManagerNextInLanes(t *testing.T) {
+	m := userop.NewNonceManager(&fakeNonceCaller{}, userop.EntryPointV07Address)
+	sender := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	keys := []*big.Int{big.NewInt(0), big.NewInt(1), big.NewInt(2)}
+	counts := map[uint64]int{}
+	for i := 0; i < 6; i++ {
+		nonce, err := m.NextInLanes(context.Background(), sender, keys)
+		if err != nil {
+			t.Fatalf("NextInLanes() error = %v", err)
+		}
+		key, _ := userop.DecodeNonce(nonce)
+		counts[key.Uint64()]++
+	}
+	if counts[0] != 2 || counts[1] != 2 || counts[2] != 2 {
+		t.Fatalf("lane usage = %v", counts)
+	}
+	if _, err := m.NextInLanes(context.Background(), sender, nil); err == nil {
+		t.Fatal("expected error without keys")
+	}
+}
+
+func TestNonceManagerConcurrentNext(t *testing.T) {
+	m := userop.NewNonceManager(&fakeNonceCaller{seq: 7}, userop.EntryPointV07Address)
+	sender := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	const n = 50
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		seen = map[string]bool{}
+	)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			nonce, err := m.Next(context.Background(), sender, big.NewInt(0))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if seen[nonce.String()] {
+				t.Errorf("nonce %s allocated twice", nonce)
+			}
+			seen[nonce.String()] = true
+		}()
+	}
+	wg.Wait()
+	if len(seen) != n || !seen[mustNonce(t, 0, 7).String()] || !seen[mustNonce(t, 0, 7+n-1).String()] {
+		t.Fatalf("allocated %d nonces", len(seen))
+	}
+}
@@ -0,0 +1,233 @@
+package userop
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+const (
+	// PaymasterDataOffset is where paymasterData starts inside v0.7 paymasterAndData.
+	PaymasterDataOffset = common.AddressLength + 16 + 16
+)
+
+var maxUint128 = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 128), big.NewInt(1))
+
+// UserOperationV07 follows the ERC-4337 v0.7 JSON shape used by eth_sendUserOperation.
+// Factory and Paymaster are nil when the operation has no initCode or paymaster.
+// EIP7702Auth is only accepted by v0.8 EntryPoints.
+type UserOperationV07 struct {
+	Sender                        common.Address  `json:"sender"`
+	Nonce                         *hexutil.Big    `json:"nonce"`
+	Factory                       *common.Address `json:"factory,omitempty"`
+	FactoryData                   hexutil.Bytes   `json:"factoryData,omitempty"`
+	CallData                      hexutil.Bytes   `json:"callData"`
+	CallGasLimit                  hexutil.Uint64  `json:"callGasLimit"`
+	VerificationGasLimit          hexutil.Uint64  `json:"verificationGasLimit"`
+	PreVerificationGas            hexutil.Uint64  `json:"preVerificationGas"`
+	MaxFeePerGas                  *hexutil.Big    `json:"maxFeePerGas"`
+	MaxPriorityFeePerGas          *hexutil.Big    `json:"maxPriorityFeePerGas"`
+	Paymaster                     *common.Address `json:"paymaster,omitempty"`
+	PaymasterVerificationGasLimit hexutil.Uint64  `json:"paymasterVerificationGasLimit"`
+	PaymasterPostOpGasLimit       hexutil.Uint64  `json:"paymasterPostOpGasLimit"`
+	PaymasterData                 hexutil.Bytes   `json:"paymasterData,omitempty"`
+	Signature                     hexutil.Bytes   `json:"signature"`
+	EIP7702Auth                   *EIP7702Auth    `json:"eip7702Auth,omitempty"`
+}
+
+// MarshalJSON omits factory and paymaster fields when they are unused, as bundlers expect.
+func (u UserOperationV07) MarshalJSON() ([]byte, error) {
+	type plain UserOperationV07
+	out := struct {
+		plain
+		PaymasterVerificationGasLimit *hexutil.Uint64 `json:"paymasterVerificationGasLimit,omitempty"`
+		PaymasterPostOpGasLimit       *hexutil.Uint64 `json:"paymasterPostOpGasLimit,omitempty"`
+		PaymasterData                 *hexutil.Bytes  `json:"paymasterData,omitempty"`
+		FactoryData                   *hexutil.Bytes  `json:"factoryData,omitempty"`
+	}{plain: plain(u)}
+	if u.Paymaster != nil {
+		out.PaymasterVerificationGasLimit = &u.PaymasterVerificationGasLimit
+		out.PaymasterPostOpGasLimit = &u.PaymasterPostOpGasLimit
+		data := u.PaymasterData
+		if data == nil {
+			data = hexutil.Bytes{}
+		}
+		out.PaymasterData = &data
+	}
+	if u.Factory != nil {
+		data := u.FactoryData
+		if data == nil {
+			data = hexutil.Bytes{}
+		}
+		out.FactoryData = &data
+	}
+	return json.Marshal(out)
+}
+
+// ValidateBasic applies local sanity checks before sending to a bundler.
+func (u UserOperationV07) ValidateBasic() error {
+	if u.Nonce == nil {
+		return errors.New("nonce is required")
+	}
+	if u.MaxFeePerGas == nil || u.MaxPriorityFeePerGas == nil {
+		return errors.New("max fee fields are required")
+	}
+	if len(u.CallData) == 0 {
+		return errors.New("callData must not be empty")
+	}
+	if len(u.Signature) == 0 {
+		return errors.New("signature must not be empty")
+	}
+	if u.Factory == nil && len(u.FactoryData) > 0 {
+		return errors.New("factoryData requires factory")
+	}
+	if u.Paymaster == nil && (len(u.PaymasterData) > 0 || u.PaymasterVerificationGasLimit != 0 || u.PaymasterPostOpGasLimit != 0) {
+		return errors.New("paymaster fields require paymaster")
+	}
+	if u.EIP7702Auth != nil {
+		if u.Factory == nil || *u.Factory != EIP7702FactoryMarker {
+			return fmt.Errorf("%w: factory must be the 0x7702 marker", ErrInvalidEIP7702Auth)
+		}
+		if _, err := u.EIP7702Auth.Authorization(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// InitCode returns factory || factoryData, or nil without a factory.
+func (u UserOperationV07) InitCode() []byte {
+	if u.Factory == nil {
+		return nil
+	}
+	out := make([]byte, 0, common.AddressLength+len(u.FactoryData))
+	out = append(out, u.Factory.Bytes()...)
+	return append(out, u.FactoryData...)
+}
+
+// PaymasterAndData returns the packed paymaster || verificationGas || postOpGas || data field.
+func (u UserOperationV07) PaymasterAndData() []byte {
+	if u.Paymaster == nil {
+		return nil
+	}
+	out := make([]byte, PaymasterDataOffset, PaymasterDataOffset+len(u.PaymasterData))
+	copy(out, u.Paymaster.Bytes())
+	new(big.Int).SetUint64(uint64(u.PaymasterVerificationGasLimit)).FillBytes(out[common.AddressLength : common.AddressLength+16])
+	new(big.Int).SetUint64(uint64(u.PaymasterPostOpGasLimit)).FillBytes(out[common.AddressLength+16 : PaymasterDataOffset])
+	return append(out, u.PaymasterData...)
+}
+
+// PackedUserOperation is the on-chain struct consumed by the v0.7 and v0.8 EntryPoint.
+type PackedUserOperation struct {
+	Sender             common.Address `abi:"sender"`
+	Nonce              *big.Int       `abi:"nonce"`
+	InitCode           []byte         `abi:"initCode"`
+	CallData           []byte         `abi:"callData"`
+	AccountGasLimits   [32]byte       `abi:"accountGasLimits"`
+	PreVerificationGas *big.Int       `abi:"preVerificationGas"`
+	GasFees            [32]byte       `abi:"gasFees"`
+	PaymasterAndData   []byte         `abi:"paymasterAndData"`
+	Signature          []byte         `abi:"signature"`
+}
+
+// Pack converts the RPC shape into the on-chain PackedUserOperation layout.
+func (u UserOperationV07) Pack() (PackedUserOperation, error) {
+	if u.Nonce == nil || u.MaxFeePerGas == nil || u.MaxPriorityFeePerGas == nil {
+		return PackedUserOperation{}, errors.New("nonce and max fee fields are required")
+	}
+	gasFees, err := packUint128Pair(u.MaxPriorityFeePerGas.ToInt(), u.MaxFeePerGas.ToInt())
+	if err != nil {
+		return PackedUserOperation{}, fmt.Errorf("pack gas fees: %w", err)
+	}
+	accountGasLimits, err := packUint128Pair(
+		new(big.Int).SetUint64(uint64(u.VerificationGasLimit)),
+		new(big.Int).SetUint64(uint64(u.CallGasLimit)),
+	)
+	if err != nil {
+		return PackedUserOperation{}, fmt.Errorf("pack account gas limits: %w", err)
+	}
+	return PackedUserOperation{
+		Sender:             u.Sender,
+		Nonce:              new(big.Int).Set(u.Nonce.ToInt()),
+		InitCode:           u.InitCode(),
+		CallData:           append([]byte(nil), u.CallData...),
+		AccountGasLimits:   accountGasLimits,
+		PreVerificationGas: new(big.Int).SetUint64(uint64(u.PreVerificationGas)),
+		GasFees:            gasFees,
+		PaymasterAndData:   u.PaymasterAndData(),
+		Signature:          append([]byte(nil), u.Signature...),
+	}, nil
+}
+
+// UnpackUserOperation converts an on-chain PackedUserOperation into the RPC shape.
+func UnpackUserOperation(p PackedUserOperation) (UserOperationV07, error) {
+	if p.Nonce == nil || p.PreVerificationGas == nil {
+		return UserOperationV07{}, errors.New("nonce and preVerificationGas are required")
+	}
+	if !p.PreVerificationGas.IsUint64() {
+		return UserOperationV07{}, errors.New("preVerificationGas overflows uint64")
+	}
+	verificationGas, callGas := unpackUint128Pair(p.AccountGasLimits)
+	if !verificationGas.IsUint64() || !callGas.IsUint64() {
+		return UserOperationV07{}, errors.New("account gas limits overflow uint64")
+	}
+	maxPriorityFee, maxFee := unpackUint128Pair(p.GasFees)
+
+	op := UserOperationV07{
+		Sender:               p.Sender,
+		Nonce:                HexBig(p.Nonce),
+		CallData:             append(hexutil.Bytes(nil), p.CallData...),
+		CallGasLimit:         HexUint64(callGas.Uint64()),
+		VerificationGasLimit: HexUint64(verificationGas.Uint64()),
+		PreVerificationGas:   HexUint64(p.PreVerificationGas.Uint64()),
+		MaxFeePerGas:         HexBig(maxFee),
+		MaxPriorityFeePerGas: HexBig(maxPriorityFee),
+		Signature:            append(hexutil.Bytes(nil), p.Signature...),
+	}
+	switch n := len(p.InitCode); {
+	case n == 0:
+	case n < common.AddressLength:
+		return UserOperationV07{}, errors.New("initCode is shorter than a factory address")
+	default:
+		factory := common.BytesToAddress(p.InitCode[:common.AddressLength])
+		op.Factory = &factory
+		op.FactoryData = append(hexutil.Bytes{}, p.InitCode[common.AddressLength:]...)
+	}
+	switch n := len(p.PaymasterAndData); {
+	case n == 0:
+	case n < PaymasterDataOffset:
+		return UserOperationV07{}, fmt.Errorf("paymasterAndData must be at least %d bytes", PaymasterDataOffset)
+	default:
+		pmd := p.PaymasterAndData
+		paymaster := common.BytesToAddress(pmd[:common.AddressLength])
+		pmVerification := new(big.Int).SetBytes(pmd[common.AddressLength : common.AddressLength+16])
+		pmPostOp := new(big.Int).SetBytes(pmd[common.AddressLength+16 : PaymasterDataOffset])
+		if !pmVerification.IsUint64() || !pmPostOp.IsUint64() {
+			return UserOperationV07{}, errors.New("paymaster gas limits overflow uint64")
+		}
+		op.Paymaster = &paymaster
+		op.PaymasterVerificationGasLimit = HexUint64(pmVerification.Uint64())
+		op.PaymasterPostOpGasLimit = HexUint64(pmPostOp.Uint64())
+		op.PaymasterData = append(hexutil.Bytes{}, pmd[PaymasterDataOffset:]...)
+	}
+	return op, nil
+}
+
+// packUint128Pair returns high(16) || low(16) as used by accountGasLimits and gasFees.
+func packUint128Pair(high, low *big.Int) ([32]byte, error) {
+	var out [32]byte
+	if high.Sign() < 0 || low.Sign() < 0 || high.Cmp(maxUint128) > 0 || low.Cmp(maxUint128) > 0 {
+		return out, errors.New("value does not fit in uint128")
+	}
+	high.FillBytes(out[:16])
+	low.FillBytes(out[16:])
+	return out, nil
+}
+
+func unpackUint128Pair(word [32]byte) (*big.Int, *big.Int) {
+	return new(big.Int).SetBytes(word[:16]), new(big.Int).SetBytes(word[16:])
+}
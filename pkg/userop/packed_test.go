@@ -0,0 +1,108 @@
+package userop_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/eipcodelab/eip7702-go/pkg/userop"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func makeUserOpV07() userop.UserOperationV07 {
+	factory := common.HexToAddress("0x00000000000000000000000000000000000fac70")
+	paymaster := common.HexToAddress("0x0000000000000000000000000000000000009a11")
+	return userop.UserOperationV07{
+		Sender:                        common.HexToAddress("0x000000000000000000000000000000000000dead"),
+		Nonce:                         userop.HexBig(big.NewInt(7)),
+		Factory:                       &factory,
+		FactoryData:                   []byte{0xf0, 0x0d},
+		CallData:                      []byte{0x01, 0x02},
+		CallGasLimit:                  userop.HexUint64(100_000),
+		VerificationGasLimit:          userop.HexUint64(250_000),
+		PreVerificationGas:            userop.HexUint64(50_000),
+		MaxFeePerGas:                  userop.HexBig(big.NewInt(30_000_000_000)),
+		MaxPriorityFeePerGas:          userop.HexBig(big.NewInt(2_000_000_000)),
+		Paymaster:                     &paymaster,
+		PaymasterVerificationGasLimit: userop.HexUint64(60_000),
+		PaymasterPostOpGasLimit:       userop.HexUint64(10_000),
+		PaymasterData:                 []byte{0xbe, 0xef},
+		Signature:                     []byte{0xaa, 0xbb},
+	}
+}
+
+func TestPackUserOperationV07Roundtrip(t *testing.T) {
+	op := makeUserOpV07()
+	packed, err := op.Pack()
+	if err != nil {
+		t.Fatalf("pack: %v", err)
+	}
+	if !bytes.Equal(packed.InitCode[:20], op.Factory.Bytes()) || !bytes.Equal(packed.InitCode[20:], op.FactoryData) {
+		t.Fatalf("unexpected initCode: %x", packed.InitCode)
+	}
+	if new(big.Int).SetBytes(packed.AccountGasLimits[:16]).Uint64() != 250_000 ||
+		new(big.Int).SetBytes(packed.AccountGasLimits[16:]).Uint64() != 100_000 {
+		t.Fatalf("unexpected accountGasLimits: %x", packed.AccountGasLimits)
+	}
+	if new(big.Int).SetBytes(packed.GasFees[:16]).Uint64() != 2_000_000_000 {
+		t.Fatalf("unexpected gasFees: %x", packed.GasFees)
+	}
+	if len(packed.PaymasterAndData) != userop.PaymasterDataOffset+2 {
+		t.Fatalf("unexpected paymasterAndData length: %d", len(packed.PaymasterAndData))
+	}
+
+	unpacked, err := userop.UnpackUserOperation(packed)
+	if err != nil {
+		t.Fatalf("unpack: %v", err)
+	}
+	want, _ := json.Marshal(op)
+	got, _ := json.Marshal(unpacked)
+	if !bytes.Equal(got, want) {
+		t.Fatalf("roundtrip mismatch:\n got %s\nwant %s", got, want)
+	}
+}
+
+func TestUnpackUserOperationRejectsShortFields(t *testing.T) {
+	packed, err := makeUserOpV07().Pack()
+	if err != nil {
+		t.Fatalf("pack: %v", err)
+	}
+	short := packed
+	short.PaymasterAndData = packed.PaymasterAndData[:40]
+	if _, err := userop.UnpackUserOperation(short); err == nil {
+		t.Fatal("expected short paymasterAndData error")
+	}
+	short = packed
+	short.InitCode = packed.InitCode[:10]
+	if _, err := userop.UnpackUserOperation(short); err == nil {
+		t.Fatal("expected short initCode error")
+	}
+}
+
+func TestUserOperationV07JSONOmitsUnusedFields(t *testing.T) {
+	op := makeUserOpV07()
+	op.Factory, op.FactoryData = nil, nil
+	op.Paymaster, op.PaymasterData = nil, nil
+	op.PaymasterVerificationGasLimit, op.PaymasterPostOpGasLimit = 0, 0
+	enc, err := json.Marshal(op)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	for _, field := range []string{"factory", "paymaster"} {
+		if strings.Contains(string(enc), field) {
+			t.Fatalf("unexpected %s field in %s", field, enc)
+		}
+	}
+
+	enc, err = json.Marshal(makeUserOpV07())
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	for _, field := range []string{`"factory"`, `"factoryData"`, `"paymasterVerificationGasLimit":"0xea60"`, `"paymasterPostOpGasLimit"`, `"paymasterData"`} {
+		if !strings.Contains(string(enc), field) {
+			t.Fatalf("missing %s in %s", field, enc)
+		}
+	}
+}
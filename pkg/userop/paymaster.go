@@ -0,0 +1,225 @@
+package userop
+
+import (
+	"context"
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// ErrPaymasterShape is returned when paymaster data does not fit the operation's EntryPoint version.
+var ErrPaymasterShape = errors.New("paymaster data does not match user operation version")
+
+// PaymasterSponsor is the optional sponsor metadata of an ERC-7677 stub response.
+type PaymasterSponsor struct {
+	Name string `json:"name"`
+	Icon string `json:"icon,omitempty"`
+}
+
+// PaymasterStubData is the pm_getPaymasterStubData result. v0.6 services return
+// PaymasterAndData; v0.7 and later return the split Paymaster fields.
+type PaymasterStubData struct {
+	Sponsor                       *PaymasterSponsor `json:"sponsor,omitempty"`
+	PaymasterAndData              hexutil.Bytes     `json:"paymasterAndData,omitempty"`
+	Paymaster                     *common.Address   `json:"paymaster,omitempty"`
+	PaymasterData                 hexutil.Bytes     `json:"paymasterData,omitempty"`
+	PaymasterVerificationGasLimit *hexutil.Uint64   `json:"paymasterVerificationGasLimit,omitempty"`
+	PaymasterPostOpGasLimit       *hexutil.Uint64   `json:"paymasterPostOpGasLimit,omitempty"`
+	// IsFinal reports that the stub is already the final data and pm_getPaymasterData can be skipped.
+	IsFinal bool `json:"isFinal,omitempty"`
+}
+
+// PaymasterData is the pm_getPaymasterData result.
+type PaymasterData struct {
+	PaymasterAndData hexutil.Bytes   `json:"paymasterAndData,omitempty"`
+	Paymaster        *common.Address `json:"paymaster,omitempty"`
+	PaymasterData    hexutil.Bytes   `json:"paymasterData,omitempty"`
+}
+
+// ApplyTo sets op.PaymasterAndData from a v0.6 stub.
+func (d PaymasterStubData) ApplyTo(op *UserOperation) error {
+	return PaymasterData{PaymasterAndData: d.PaymasterAndData, Paymaster: d.Paymaster}.ApplyTo(op)
+}
+
+// ApplyToV07 sets the paymaster fields of a v0.7 operation from a stub,
+// including the gas limits the service returned.
+func (d PaymasterStubData) ApplyToV07(op *UserOperationV07) error {
+	err := PaymasterData{Paymaster: d.Paymaster, PaymasterData: d.PaymasterData, PaymasterAndData: d.PaymasterAndData}.ApplyToV07(op)
+	if err != nil {
+		return err
+	}
+	if d.PaymasterVerificationGasLimit != nil {
+		op.PaymasterVerificationGasLimit = *d.PaymasterVerificationGasLimit
+	}
+	if d.PaymasterPostOpGasLimit != nil {
+		op.PaymasterPostOpGasLimit = *d.PaymasterPostOpGasLimit
+	}
+	return nil
+}
+
+// ApplyTo sets op.PaymasterAndData from a v0.6 response.
+func (d PaymasterData) ApplyTo(op *UserOperation) error {
+	if d.Paymaster != nil || len(d.PaymasterAndData) < common.AddressLength {
+		return ErrPaymasterShape
+	}
+	op.PaymasterAndData = append(hexutil.Bytes(nil), d.PaymasterAndData...)
+	return nil
+}
+
+// ApplyToV07 sets Paymaster and PaymasterData of a v0.7 operation, keeping its gas limits.
+func (d PaymasterData) ApplyToV07(op *UserOperationV07) error {
+	if d.Paymaster == nil || len(d.PaymasterAndData) > 0 {
+		return ErrPaymasterShape
+	}
+	paymaster := *d.Paymaster
+	op.Paymaster = &paymaster
+	op.PaymasterData = append(hexutil.Bytes{}, d.PaymasterData...)
+	return nil
+}
+
+// PaymasterClient calls an ERC-7677 paymaster web service.
+// It is safe for concurrent use by multiple goroutines.
+type PaymasterClient struct {
+	rpc *BundlerClient
+}
+
+// NewPaymasterClient creates a client for a paymaster service endpoint.
+func NewPaymasterClient(endpoint string) *PaymasterClient {
+	return &PaymasterClient{rpc: NewBundlerClient(endpoint)}
+}
+
+// NewPaymasterClientWithOptions creates a paymaster client with custom headers, auth and limits.
+func NewPaymasterClientWithOptions(endpoint string, opts ClientOptions) *PaymasterClient {
+	return &PaymasterClient{rpc: NewBundlerClientWithOptions(endpoint, opts)}
+}
+
+// GetPaymasterStubData calls pm_getPaymasterStubData for a v0.6 operation.
+// pmContext is the service-specific context object and may be nil.
+func (c *PaymasterClient) GetPaymasterStubData(ctx context.Context, op UserOperation, entryPoint common.Address, chainID *big.Int, pmContext map[string]any) (*PaymasterStubData, error) {
+	if err := checkEntryPoint(entryPoint, EntryPointV06); err != nil {
+		return nil, err
+	}
+	return c.stubData(ctx, op, entryPoint, chainID, pmContext)
+}
+
+// GetPaymasterStubDataV07 calls pm_getPaymasterStubData for a v0.7 or v0.8 operation.
+func (c *PaymasterClient) GetPaymasterStubDataV07(ctx context.Context, op UserOperationV07, entryPoint common.Address, chainID *big.Int, pmContext map[string]any) (*PaymasterStubData, error) {
+	if err := checkEntryPoint(entryPoint, EntryPointV07, EntryPointV08); err != nil {
+		return nil, err
+	}
+	return c.stubData(ctx, op, entryPoint, chainID, pmContext)
+}
+
+// GetPaymasterData calls pm_getPaymasterData for a v0.6 operation whose gas fields are final.
+func (c *PaymasterClient) GetPaymasterData(ctx context.Context, op UserOperation, entryPoint common.Address, chainID *big.Int, pmContext map[string]any) (*PaymasterData, error) {
+	if err := checkEntryPoint(entryPoint, EntryPointV06); err != nil {
+		return nil, err
+	}
+	return c.paymasterData(ctx, op, entryPoint, chainID, pmContext)
+}
+
+// GetPaymasterDataV07 calls pm_getPaymasterData for a v0.7 or v0.8 operation whose gas fields are final.
+func (c *PaymasterClient) GetPaymasterDataV07(ctx context.Context, op UserOperationV07, entryPoint common.Address, chainID *big.Int, pmContext map[string]any) (*PaymasterData, error) {
+	if err := checkEntryPoint(entryPoint, EntryPointV07, EntryPointV08); err != nil {
+		return nil, err
+	}
+	return c.paymasterData(ctx, op, entryPoint, chainID, pmContext)
+}
+
+func (c *PaymasterClient) stubData(ctx context.Context, op any, entryPoint common.Address, chainID *big.Int, pmContext map[string]any) (*PaymasterStubData, error) {
+	if chainID == nil {
+		return nil, errNilHashChainID
+	}
+	var out PaymasterStubData
+	if err := c.rpc.Call(ctx, &out, "pm_getPaymasterStubData", op, entryPoint, HexBig(chainID), paymasterContext(pmContext)); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *PaymasterClient) paymasterData(ctx context.Context, op any, entryPoint common.Address, chainID *big.Int, pmContext map[string]any) (*PaymasterData, error) {
+	if chainID == nil {
+		return nil, errNilHashChainID
+	}
+	var out PaymasterData
+	if err := c.rpc.Call(ctx, &out, "pm_getPaymasterData", op, entryPoint, HexBig(chainID), paymasterContext(pmContext)); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// paymasterContext sends an empty object instead of null, as ERC-7677 expects.
+func paymasterContext(pmContext map[string]any) map[string]any {
+	if pmContext == nil {
+		return map[string]any{}
+	}
+	return pmContext
+}
+
+// SponsorUserOperation runs the ERC-7677 flow for a v0.6 operation: stub data,
+// gas estimation with the bundler, then final paymaster data. op.Signature must
+// hold a dummy signature of the right length; sign op after it returns.
+func (c *PaymasterClient) SponsorUserOperation(ctx context.Context, bundler *BundlerClient, op *UserOperation, entryPoint common.Address, chainID *big.Int, pmContext map[string]any) (*PaymasterSponsor, error) {
+	if op == nil {
+		return nil, errors.New("user operation is required")
+	}
+	stub, err := c.GetPaymasterStubData(ctx, *op, entryPoint, chainID, pmContext)
+	if err != nil {
+		return nil, err
+	}
+	if err := stub.ApplyTo(op); err != nil {
+		return nil, err
+	}
+	estimate, err := bundler.EstimateUserOperationGas(ctx, *op, entryPoint)
+	if err != nil {
+		return nil, err
+	}
+	estimate.ApplyTo(op)
+	if stub.IsFinal {
+		return stub.Sponsor, nil
+	}
+	final, err := c.GetPaymasterData(ctx, *op, entryPoint, chainID, pmContext)
+	if err != nil {
+		return nil, err
+	}
+	if err := final.ApplyTo(op); err != nil {
+		return nil, err
+	}
+	return stub.Sponsor, nil
+}
+
+// SponsorUserOperationV07 runs the ERC-7677 flow for a v0.7 or v0.8 operation.
+// A paymasterPostOpGasLimit from the stub is kept over the bundler estimate.
+func (c *PaymasterClient) SponsorUserOperationV07(ctx context.Context, bundler *BundlerClient, op *UserOperationV07, entryPoint common.Address, chainID *big.Int, pmContext map[string]any) (*PaymasterSponsor, error) {
+	if op == nil {
+		return nil, errors.New("user operation is required")
+	}
+	stub, err := c.GetPaymasterStubDataV07(ctx, *op, entryPoint, chainID, pmContext)
+	if err != nil {
+		return nil, err
+	}
+	if err := stub.ApplyToV07(op); err != nil {
+		return nil, err
+	}
+	estimate, err := bundler.EstimateUserOperationGasV07(ctx, *op, entryPoint)
+	if err != nil {
+		return nil, err
+	}
+	if stub.PaymasterPostOpGasLimit != nil {
+		estimate.PaymasterPostOpGasLimit = stub.PaymasterPostOpGasLimit
+	}
+	estimate.ApplyToV07(op)
+	if stub.IsFinal {
+		return stub.Sponsor, nil
+	}
+	final, err := c.GetPaymasterDataV07(ctx, *op, entryPoint, chainID, pmContext)
+	if err != nil {
+		return nil, err
+	}
+	if err := final.ApplyToV07(op); err != nil {
+		return nil, err
+	}
+	return stub.Sponsor, nil
+}
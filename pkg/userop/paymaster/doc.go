@@ -0,0 +1,2 @@
+// Package paymaster implements a VerifyingPaymaster signing service for ERC-4337 user operations.
+package paymaster
@@ -0,0 +1,275 @@
+package paymaster
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/eipcodelab/eip7702-go/pkg/userop"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+const (
+	defaultValidity             = 10 * time.Minute
+	defaultVerificationGasLimit = 100_000
+	maxRequestBytes             = 1 << 20
+
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInternalError  = -32603
+)
+
+// Config configures a Handler.
+type Config struct {
+	Paymaster  *VerifyingPaymaster
+	EntryPoint common.Address
+	// Version defaults to the version of a canonical EntryPoint address.
+	Version userop.EntryPointVersion
+	// Policies must all approve a request, in order.
+	Policies []Policy
+	// Validity is how long signed data stays valid. Default 10 minutes.
+	Validity time.Duration
+	// VerificationGasLimit and PostOpGasLimit are returned with v0.7 stubs.
+	// VerificationGasLimit defaults to 100k; the VerifyingPaymaster has no postOp.
+	VerificationGasLimit uint64
+	PostOpGasLimit       uint64
+	// SenderNonce reads the v0.6 paymaster's senderNonce(sender). Nil means zero.
+	SenderNonce func(ctx context.Context, sender common.Address) (*big.Int, error)
+	// Sponsor is returned with stub data so wallets can show who pays.
+	Sponsor *userop.PaymasterSponsor
+	// Now defaults to time.Now.
+	Now func() time.Time
+}
+
+// Handler serves pm_getPaymasterStubData and pm_getPaymasterData over JSON-RPC.
+// It is safe for concurrent use.
+type Handler struct {
+	cfg Config
+	// final serialises policy checks, signing and recording of final requests
+	// so spend caps cannot be overshot by concurrent requests.
+	final sync.Mutex
+}
+
+// NewHandler validates cfg and returns an ERC-7677 handler.
+func NewHandler(cfg Config) (*Handler, error) {
+	if cfg.Paymaster == nil {
+		return nil, errors.New("paymaster is required")
+	}
+	if cfg.Version == userop.EntryPointUnknown {
+		version, ok := userop.EntryPointVersionOf(cfg.EntryPoint)
+		if !ok {
+			return nil, fmt.Errorf("entry point %s is not canonical; set Version", cfg.EntryPoint.Hex())
+		}
+		cfg.Version = version
+	}
+	if cfg.Validity <= 0 {
+		cfg.Validity = defaultValidity
+	}
+	if cfg.VerificationGasLimit == 0 {
+		cfg.VerificationGasLimit = defaultVerificationGasLimit
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+	return &Handler{cfg: cfg}, nil
+}
+
+type rpcRequest struct {
+	JSONRPC string            `json:"jsonrpc"`
+	ID      json.RawMessage   `json:"id"`
+	Method  string            `json:"method"`
+	Params  []json.RawMessage `json:"params"`
+}
+
+type rpcResponse struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      json.RawMessage  `json:"id"`
+	Result  any              `json:"result,omitempty"`
+	Error   *userop.RPCError `json:"error,omitempty"`
+}
+
+// ServeHTTP handles one JSON-RPC request per POST.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestBytes))
+	if err != nil {
+		http.Error(w, "read request", http.StatusBadRequest)
+		return
+	}
+	resp := rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null")}
+	var req rpcRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		resp.Error = &userop.RPCError{Code: codeParseError, Message: "parse error"}
+	} else {
+		if len(req.ID) > 0 {
+			resp.ID = req.ID
+		}
+		resp.Result, resp.Error = h.handle(r.Context(), req)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+func (h *Handler) handle(ctx context.Context, req rpcRequest) (any, *userop.RPCError) {
+	var final bool
+	switch req.Method {
+	case "pm_getPaymasterStubData":
+	case "pm_getPaymasterData":
+		final = true
+	case "":
+		return nil, &userop.RPCError{Code: codeInvalidRequest, Message: "method is required"}
+	default:
+		return nil, &userop.RPCError{Code: codeMethodNotFound, Message: "method not found: " + req.Method}
+	}
+	if len(req.Params) < 3 {
+		return nil, invalidParams(errors.New("expected [userOp, entryPoint, chainId, context]"))
+	}
+	var entryPoint common.Address
+	if err := json.Unmarshal(req.Params[1], &entryPoint); err != nil || entryPoint != h.cfg.EntryPoint {
+		return nil, invalidParams(fmt.Errorf("unsupported entry point %s", req.Params[1]))
+	}
+	var chainID hexutil.Big
+	if err := json.Unmarshal(req.Params[2], &chainID); err != nil || chainID.ToInt().Cmp(h.cfg.Paymaster.ChainID) != 0 {
+		return nil, invalidParams(fmt.Errorf("unsupported chain id %s", req.Params[2]))
+	}
+	var pmContext map[string]any
+	if len(req.Params) > 3 {
+		if err := json.Unmarshal(req.Params[3], &pmContext); err != nil {
+			return nil, invalidParams(fmt.Errorf("context must be an object: %v", err))
+		}
+	}
+
+	if final {
+		h.final.Lock()
+		defer h.final.Unlock()
+	}
+	if h.cfg.Version == userop.EntryPointV06 {
+		var op userop.UserOperation
+		if err := json.Unmarshal(req.Params[0], &op); err != nil {
+			return nil, invalidParams(fmt.Errorf("decode user operation: %v", err))
+		}
+		return h.sponsor(ctx, &op, final, pmContext)
+	}
+	var op userop.UserOperationV07
+	if err := json.Unmarshal(req.Params[0], &op); err != nil {
+		return nil, invalidParams(fmt.Errorf("decode user operation: %v", err))
+	}
+	return h.sponsorV07(ctx, &op, final, pmContext)
+}
+
+func (h *Handler) sponsorV07(ctx context.Context, op *userop.UserOperationV07, final bool, pmContext map[string]any) (any, *userop.RPCError) {
+	if op.Nonce == nil || op.MaxFeePerGas == nil || op.MaxPriorityFeePerGas == nil {
+		return nil, invalidParams(errors.New("nonce and max fee fields are required"))
+	}
+	if !final {
+		op.PaymasterVerificationGasLimit = hexutil.Uint64(h.cfg.VerificationGasLimit)
+		op.PaymasterPostOpGasLimit = hexutil.Uint64(h.cfg.PostOpGasLimit)
+	}
+	req := &Request{
+		Final:      final,
+		EntryPoint: h.cfg.EntryPoint,
+		Version:    h.cfg.Version,
+		Sender:     op.Sender,
+		Nonce:      op.Nonce.ToInt(),
+		CallData:   op.CallData,
+		MaxCost:    maxCostV07(*op),
+		Context:    pmContext,
+	}
+	if rpcErr := h.approve(ctx, req); rpcErr != nil {
+		return nil, rpcErr
+	}
+	if err := h.cfg.Paymaster.SponsorV07(op, h.validity()); err != nil {
+		return nil, internalError(err)
+	}
+	h.record(req)
+	if final {
+		return userop.PaymasterData{Paymaster: op.Paymaster, PaymasterData: op.PaymasterData}, nil
+	}
+	return userop.PaymasterStubData{
+		Sponsor:                       h.cfg.Sponsor,
+		Paymaster:                     op.Paymaster,
+		PaymasterData:                 op.PaymasterData,
+		PaymasterVerificationGasLimit: &op.PaymasterVerificationGasLimit,
+		PaymasterPostOpGasLimit:       &op.PaymasterPostOpGasLimit,
+	}, nil
+}
+
+func (h *Handler) sponsor(ctx context.Context, op *userop.UserOperation, final bool, pmContext map[string]any) (any, *userop.RPCError) {
+	if op.Nonce == nil || op.MaxFeePerGas == nil || op.MaxPriorityFeePerGas == nil {
+		return nil, invalidParams(errors.New("nonce and max fee fields are required"))
+	}
+	req := &Request{
+		Final:      final,
+		EntryPoint: h.cfg.EntryPoint,
+		Version:    h.cfg.Version,
+		Sender:     op.Sender,
+		Nonce:      op.Nonce.ToInt(),
+		CallData:   op.CallData,
+		MaxCost:    maxCost(*op),
+		Context:    pmContext,
+	}
+	if rpcErr := h.approve(ctx, req); rpcErr != nil {
+		return nil, rpcErr
+	}
+	senderNonce := new(big.Int)
+	if h.cfg.SenderNonce != nil {
+		n, err := h.cfg.SenderNonce(ctx, op.Sender)
+		if err != nil {
+			return nil, internalError(fmt.Errorf("read sender nonce: %w", err))
+		}
+		senderNonce = n
+	}
+	if err := h.cfg.Paymaster.Sponsor(op, senderNonce, h.validity()); err != nil {
+		return nil, internalError(err)
+	}
+	h.record(req)
+	if final {
+		return userop.PaymasterData{PaymasterAndData: op.PaymasterAndData}, nil
+	}
+	return userop.PaymasterStubData{Sponsor: h.cfg.Sponsor, PaymasterAndData: op.PaymasterAndData}, nil
+}
+
+func (h *Handler) approve(ctx context.Context, req *Request) *userop.RPCError {
+	for _, policy := range h.cfg.Policies {
+		if err := policy.Check(ctx, req); err != nil {
+			return &userop.RPCError{Code: userop.CodePaymasterRejected, Message: err.Error()}
+		}
+	}
+	return nil
+}
+
+// record lets Recorder policies count a signed final request.
+func (h *Handler) record(req *Request) {
+	if !req.Final {
+		return
+	}
+	for _, policy := range h.cfg.Policies {
+		if recorder, ok := policy.(Recorder); ok {
+			recorder.Record(req)
+		}
+	}
+}
+
+// validity is valid from now (validAfter 0) until now + Validity.
+func (h *Handler) validity() Validity {
+	return Validity{ValidUntil: uint64(h.cfg.Now().Add(h.cfg.Validity).Unix())}
+}
+
+func invalidParams(err error) *userop.RPCError {
+	return &userop.RPCError{Code: userop.CodeInvalidParams, Message: err.Error()}
+}
+
+func internalError(err error) *userop.RPCError {
+	return &userop.RPCError{Code: codeInternalError, Message: err.Error()}
+}
@@ -0,0 +1,182 @@
+package paymaster_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/eipcodelab/eip7702-go/pkg/userop"
+	"github.com/eipcodelab/eip7702-go/pkg/userop/paymaster"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// newEstimator serves eth_estimateUserOperationGas with fixed limits.
+func newEstimator(t *testing.T) *userop.BundlerClient {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID json.RawMessage `json:"id"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"jsonrpc": "2.0",
+			"id":      req.ID,
+			"result": map[string]any{
+				"preVerificationGas":            "0xc350",
+				"verificationGasLimit":          "0x30d40",
+				"callGasLimit":                  "0x186a0",
+				"paymasterVerificationGasLimit": "0xea60",
+			},
+		})
+	}))
+	t.Cleanup(srv.Close)
+	return userop.NewBundlerClient(srv.URL)
+}
+
+func newPaymasterServer(t *testing.T, cfg paymaster.Config) *userop.PaymasterClient {
+	t.Helper()
+	h, err := paymaster.NewHandler(cfg)
+	if err != nil {
+		t.Fatalf("NewHandler() error = %v", err)
+	}
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	return userop.NewPaymasterClient(srv.URL)
+}
+
+func TestHandlerSponsorsV07Operation(t *testing.T) {
+	pm := mustKey(t)
+	now := time.Unix(1_800_000_000, 0)
+	client := newPaymasterServer(t, paymaster.Config{
+		Paymaster:  pm,
+		EntryPoint: userop.EntryPointV07Address,
+		Policies:   []paymaster.Policy{paymaster.AllowSenders(testSender), paymaster.AllowTargets(allowedTarget)},
+		Sponsor:    &userop.PaymasterSponsor{Name: "Codelab"},
+		Now:        func() time.Time { return now },
+	})
+
+	op := makeOpV07()
+	op.CallData = executeBatch(t, allowedTarget)
+	sponsor, err := client.SponsorUserOperationV07(context.Background(), newEstimator(t), &op, userop.EntryPointV07Address, testChainID, nil)
+	if err != nil {
+		t.Fatalf("SponsorUserOperationV07() error = %v", err)
+	}
+	if sponsor == nil || sponsor.Name != "Codelab" {
+		t.Fatalf("sponsor = %+v", sponsor)
+	}
+	if op.CallGasLimit != 0x186a0 || op.PaymasterVerificationGasLimit != 0xea60 {
+		t.Fatalf("estimated gas not applied: %+v", op)
+	}
+	validity, err := pm.VerifyV07(op)
+	if err != nil {
+		t.Fatalf("VerifyV07() error = %v", err)
+	}
+	if want := uint64(now.Add(10 * time.Minute).Unix()); validity.ValidUntil != want {
+		t.Fatalf("validUntil = %d, want %d", validity.ValidUntil, want)
+	}
+}
+
+func TestHandlerRejectsByPolicy(t *testing.T) {
+	client := newPaymasterServer(t, paymaster.Config{
+		Paymaster:  mustKey(t),
+		EntryPoint: userop.EntryPointV07Address,
+		Policies:   []paymaster.Policy{paymaster.AllowTargets(allowedTarget)},
+	})
+
+	op := makeOpV07()
+	op.CallData = executeBatch(t, otherTarget)
+	_, err := client.GetPaymasterStubDataV07(context.Background(), op, userop.EntryPointV07Address, testChainID, nil)
+	if !errors.Is(err, userop.ErrPaymasterRejected) {
+		t.Fatalf("error = %v, want ErrPaymasterRejected", err)
+	}
+	if _, err := client.GetPaymasterStubDataV07(context.Background(), makeOpV07(), userop.EntryPointV07Address, big.NewInt(1), nil); !errors.Is(err, userop.ErrInvalidParams) {
+		t.Fatalf("wrong chain error = %v, want ErrInvalidParams", err)
+	}
+}
+
+func TestHandlerEnforcesSpendCapOnFinalData(t *testing.T) {
+	op := makeOpV07()
+	op.Paymaster = &testPaymaster
+	op.PaymasterVerificationGasLimit, op.PaymasterPostOpGasLimit = 100_000, 0
+	// MaxCost is (200k + 100k + 50k + 100k) gas at 3 gwei, as stubs also price it.
+	perOp := new(big.Int).Mul(big.NewInt(450_000), big.NewInt(3_000_000_000))
+	spendCap := paymaster.NewSpendCap(new(big.Int).Add(perOp, big.NewInt(1)))
+	client := newPaymasterServer(t, paymaster.Config{
+		Paymaster:  mustKey(t),
+		EntryPoint: userop.EntryPointV07Address,
+		Policies:   []paymaster.Policy{spendCap},
+	})
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if _, err := client.GetPaymasterStubDataV07(ctx, op, userop.EntryPointV07Address, testChainID, nil); err != nil {
+			t.Fatalf("stub %d: %v", i, err)
+		}
+	}
+	if _, err := client.GetPaymasterDataV07(ctx, op, userop.EntryPointV07Address, testChainID, nil); err != nil {
+		t.Fatalf("first final: %v", err)
+	}
+	if _, err := client.GetPaymasterDataV07(ctx, op, userop.EntryPointV07Address, testChainID, nil); !errors.Is(err, userop.ErrPaymasterRejected) {
+		t.Fatalf("second final error = %v, want ErrPaymasterRejected", err)
+	}
+	if got := spendCap.Spent(testSender); got.Cmp(perOp) != 0 {
+		t.Fatalf("spent = %s, want %s", got, perOp)
+	}
+}
+
+func TestHandlerSpendCapRejectsWrappingGasLimits(t *testing.T) {
+	// One ether caps any sender; limits that wrap uint64 must not price below it.
+	spendCap := paymaster.NewSpendCap(big.NewInt(1_000_000_000_000_000_000))
+	ctx := context.Background()
+
+	v07 := newPaymasterServer(t, paymaster.Config{
+		Paymaster:  mustKey(t),
+		EntryPoint: userop.EntryPointV07Address,
+		Policies:   []paymaster.Policy{spendCap},
+	})
+	op := makeOpV07()
+	op.VerificationGasLimit, op.CallGasLimit = 1<<63, 1<<63
+	if _, err := v07.GetPaymasterStubDataV07(ctx, op, userop.EntryPointV07Address, testChainID, nil); !errors.Is(err, userop.ErrPaymasterRejected) {
+		t.Fatalf("v0.7 error = %v, want ErrPaymasterRejected", err)
+	}
+
+	v06 := newPaymasterServer(t, paymaster.Config{
+		Paymaster:  mustKey(t),
+		EntryPoint: userop.EntryPointV06Address,
+		Policies:   []paymaster.Policy{spendCap},
+	})
+	opV06 := makeOp()
+	// Three times this limit is 2^64 + 2.
+	opV06.VerificationGasLimit = 0x5555555555555556
+	if _, err := v06.GetPaymasterStubData(ctx, opV06, userop.EntryPointV06Address, testChainID, nil); !errors.Is(err, userop.ErrPaymasterRejected) {
+		t.Fatalf("v0.6 error = %v, want ErrPaymasterRejected", err)
+	}
+}
+
+func TestHandlerSponsorsV06Operation(t *testing.T) {
+	pm := mustKey(t)
+	client := newPaymasterServer(t, paymaster.Config{
+		Paymaster:  pm,
+		EntryPoint: userop.EntryPointV06Address,
+		SenderNonce: func(context.Context, common.Address) (*big.Int, error) {
+			return big.NewInt(9), nil
+		},
+	})
+
+	op := makeOp()
+	data, err := client.GetPaymasterData(context.Background(), op, userop.EntryPointV06Address, testChainID, nil)
+	if err != nil {
+		t.Fatalf("GetPaymasterData() error = %v", err)
+	}
+	if err := data.ApplyTo(&op); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pm.Verify(op, big.NewInt(9)); err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+}
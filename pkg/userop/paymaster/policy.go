@@ -0,0 +1,167 @@
+package paymaster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/eipcodelab/eip7702-go/pkg/batching"
+	"github.com/eipcodelab/eip7702-go/pkg/userop"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ErrRejected is wrapped by policy errors that deny sponsorship.
+var ErrRejected = errors.New("sponsorship rejected")
+
+// Request describes one sponsorship request as seen by policies.
+type Request struct {
+	// Final is false for pm_getPaymasterStubData and true for pm_getPaymasterData.
+	Final      bool
+	EntryPoint common.Address
+	Version    userop.EntryPointVersion
+	Sender     common.Address
+	Nonce      *big.Int
+	CallData   []byte
+	// MaxCost is the most wei the paymaster can be charged for the operation.
+	MaxCost *big.Int
+	// Context is the ERC-7677 context object sent by the wallet.
+	Context map[string]any
+}
+
+// Calls decodes CallData as an executeBatch call list.
+func (r *Request) Calls() ([]batching.Call, error) {
+	return batching.DecodeExecuteBatch(r.CallData)
+}
+
+// Policy approves or rejects a sponsorship request.
+type Policy interface {
+	Check(ctx context.Context, req *Request) error
+}
+
+// Recorder is implemented by policies that track signed sponsorships.
+// Record is called after a final request was approved by every policy and signed.
+type Recorder interface {
+	Record(req *Request)
+}
+
+// PolicyFunc adapts a function to Policy.
+type PolicyFunc func(ctx context.Context, req *Request) error
+
+// Check calls f(ctx, req).
+func (f PolicyFunc) Check(ctx context.Context, req *Request) error {
+	return f(ctx, req)
+}
+
+// AllowSenders only sponsors operations from the given accounts.
+func AllowSenders(senders ...common.Address) Policy {
+	allowed := make(map[common.Address]bool, len(senders))
+	for _, s := range senders {
+		allowed[s] = true
+	}
+	return PolicyFunc(func(_ context.Context, req *Request) error {
+		if !allowed[req.Sender] {
+			return fmt.Errorf("%w: sender %s is not allowed", ErrRejected, req.Sender.Hex())
+		}
+		return nil
+	})
+}
+
+// AllowTargets only sponsors executeBatch calldata whose every call targets one of
+// the given contracts. Calldata in any other format is rejected.
+func AllowTargets(targets ...common.Address) Policy {
+	allowed := make(map[common.Address]bool, len(targets))
+	for _, t := range targets {
+		allowed[t] = true
+	}
+	return PolicyFunc(func(_ context.Context, req *Request) error {
+		calls, err := req.Calls()
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrRejected, err)
+		}
+		for i, call := range calls {
+			if !allowed[call.Target] {
+				return fmt.Errorf("%w: call %d target %s is not allowed", ErrRejected, i, call.Target.Hex())
+			}
+		}
+		return nil
+	})
+}
+
+// SpendCap limits the total MaxCost sponsored per sender. Spend is counted when a
+// final request is signed, so it is an upper bound of what was actually paid.
+// It is safe for concurrent use.
+type SpendCap struct {
+	limit *big.Int
+
+	mu    sync.Mutex
+	spent map[common.Address]*big.Int
+}
+
+// NewSpendCap returns a per-sender cap of limit wei.
+func NewSpendCap(limit *big.Int) *SpendCap {
+	return &SpendCap{limit: new(big.Int).Set(limit), spent: make(map[common.Address]*big.Int)}
+}
+
+// Check rejects requests that would take the sender past the cap.
+func (s *SpendCap) Check(_ context.Context, req *Request) error {
+	if req.MaxCost == nil {
+		return fmt.Errorf("%w: max cost is unknown", ErrRejected)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	total := new(big.Int).Add(s.spentLocked(req.Sender), req.MaxCost)
+	if total.Cmp(s.limit) > 0 {
+		return fmt.Errorf("%w: sender %s would spend %s wei, cap is %s", ErrRejected, req.Sender.Hex(), total, s.limit)
+	}
+	return nil
+}
+
+// Record adds a signed request's MaxCost to the sender's spend.
+func (s *SpendCap) Record(req *Request) {
+	if req.MaxCost == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.spent[req.Sender] = new(big.Int).Add(s.spentLocked(req.Sender), req.MaxCost)
+}
+
+// Spent returns the recorded spend for sender.
+func (s *SpendCap) Spent(sender common.Address) *big.Int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return new(big.Int).Set(s.spentLocked(sender))
+}
+
+func (s *SpendCap) spentLocked(sender common.Address) *big.Int {
+	if v, ok := s.spent[sender]; ok {
+		return v
+	}
+	return new(big.Int)
+}
+
+// maxCostV07 is (all gas limits + preVerificationGas) * maxFeePerGas.
+func maxCostV07(op userop.UserOperationV07) *big.Int {
+	gas := sumGas(uint64(op.VerificationGasLimit), uint64(op.CallGasLimit), uint64(op.PreVerificationGas),
+		uint64(op.PaymasterVerificationGasLimit), uint64(op.PaymasterPostOpGasLimit))
+	return gas.Mul(gas, op.MaxFeePerGas.ToInt())
+}
+
+// maxCost mirrors the v0.6 EntryPoint, which reserves verification gas three
+// times for paymaster operations.
+func maxCost(op userop.UserOperation) *big.Int {
+	v := uint64(op.VerificationGasLimit)
+	gas := sumGas(uint64(op.CallGasLimit), v, v, v, uint64(op.PreVerificationGas))
+	return gas.Mul(gas, op.MaxFeePerGas.ToInt())
+}
+
+// sumGas adds gas limits without wrapping at 2^64.
+func sumGas(limits ...uint64) *big.Int {
+	sum := new(big.Int)
+	for _, limit := range limits {
+		sum.Add(sum, new(big.Int).SetUint64(limit))
+	}
+	return sum
+}
@@ -0,0 +1,81 @@
+package paymaster_test
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/eipcodelab/eip7702-go/pkg/batching"
+	"github.com/eipcodelab/eip7702-go/pkg/userop/paymaster"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var (
+	allowedTarget = common.HexToAddress("0x00000000000000000000000000000000000000a1")
+	otherTarget   = common.HexToAddress("0x00000000000000000000000000000000000000b2")
+)
+
+func executeBatch(t *testing.T, targets ...common.Address) []byte {
+	t.Helper()
+	calls := make([]batching.Call, len(targets))
+	for i, target := range targets {
+		calls[i] = batching.Call{Target: target, Data: []byte{0x01}}
+	}
+	data, err := batching.EncodeExecuteBatch(calls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestAllowSenders(t *testing.T) {
+	policy := paymaster.AllowSenders(testSender)
+	if err := policy.Check(context.Background(), &paymaster.Request{Sender: testSender}); err != nil {
+		t.Fatalf("allowed sender rejected: %v", err)
+	}
+	if err := policy.Check(context.Background(), &paymaster.Request{Sender: otherTarget}); !errors.Is(err, paymaster.ErrRejected) {
+		t.Fatalf("error = %v, want ErrRejected", err)
+	}
+}
+
+func TestAllowTargets(t *testing.T) {
+	policy := paymaster.AllowTargets(allowedTarget)
+	cases := []struct {
+		name     string
+		callData []byte
+		ok       bool
+	}{
+		{"allowed", executeBatch(t, allowedTarget, allowedTarget), true},
+		{"one foreign target", executeBatch(t, allowedTarget, otherTarget), false},
+		{"not executeBatch", []byte{0xde, 0xad, 0xbe, 0xef}, false},
+	}
+	for _, tc := range cases {
+		err := policy.Check(context.Background(), &paymaster.Request{CallData: tc.callData})
+		if tc.ok != (err == nil) {
+			t.Errorf("%s: error = %v", tc.name, err)
+		}
+		if err != nil && !errors.Is(err, paymaster.ErrRejected) {
+			t.Errorf("%s: error %v does not wrap ErrRejected", tc.name, err)
+		}
+	}
+}
+
+func TestSpendCap(t *testing.T) {
+	spendCap := paymaster.NewSpendCap(big.NewInt(100))
+	req := &paymaster.Request{Sender: testSender, MaxCost: big.NewInt(60), Final: true}
+	if err := spendCap.Check(context.Background(), req); err != nil {
+		t.Fatalf("first check: %v", err)
+	}
+	spendCap.Record(req)
+	if err := spendCap.Check(context.Background(), req); !errors.Is(err, paymaster.ErrRejected) {
+		t.Fatalf("second check error = %v, want ErrRejected", err)
+	}
+	if got := spendCap.Spent(testSender); got.Cmp(big.NewInt(60)) != 0 {
+		t.Fatalf("Spent() = %s, want 60", got)
+	}
+	other := &paymaster.Request{Sender: otherTarget, MaxCost: big.NewInt(60)}
+	if err := spendCap.Check(context.Background(), other); err != nil {
+		t.Fatalf("caps must be per sender: %v", err)
+	}
+}
@@ -0,0 +1,270 @@
+package paymaster
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/eipcodelab/eip7702-go/pkg/userop"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const (
+	// TimestampsLength is the abi.encode(uint48 validUntil, uint48 validAfter) size.
+	TimestampsLength = 64
+	// SignatureLength is the r || s || v sponsor signature size.
+	SignatureLength = crypto.SignatureLength
+	// PaymasterDataLength is the v0.7 paymasterData size: timestamps || signature.
+	PaymasterDataLength = TimestampsLength + SignatureLength
+
+	maxUint48 = 1<<48 - 1
+)
+
+var (
+	// ErrInvalidPaymasterData is returned for paymaster data that is not timestamps || signature.
+	ErrInvalidPaymasterData = errors.New("invalid verifying paymaster data")
+	// ErrWrongSigner is returned when paymaster data was not signed by the expected key.
+	ErrWrongSigner = errors.New("paymaster data not signed by verifying signer")
+)
+
+// Validity is the validUntil/validAfter window signed into paymaster data.
+// Zero ValidUntil means no expiry.
+type Validity struct {
+	ValidUntil uint64
+	ValidAfter uint64
+}
+
+func (v Validity) check() error {
+	if v.ValidUntil > maxUint48 || v.ValidAfter > maxUint48 {
+		return errors.New("validity timestamps must fit in uint48")
+	}
+	return nil
+}
+
+// HashV07 computes VerifyingPaymaster.getHash for a v0.7 or v0.8 operation. The
+// operation's Paymaster gas limits are part of the hash; PaymasterData is not.
+func HashV07(op userop.UserOperationV07, paymaster common.Address, chainID *big.Int, validity Validity) (common.Hash, error) {
+	if chainID == nil {
+		return common.Hash{}, errors.New("chain id is required")
+	}
+	if err := validity.check(); err != nil {
+		return common.Hash{}, err
+	}
+	// Pack with the paymaster address set so the gas limits land in paymasterAndData.
+	op.Paymaster = &paymaster
+	packed, err := op.Pack()
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return crypto.Keccak256Hash(
+		common.LeftPadBytes(packed.Sender.Bytes(), 32),
+		math.U256Bytes(new(big.Int).Set(packed.Nonce)),
+		crypto.Keccak256(packed.InitCode),
+		crypto.Keccak256(packed.CallData),
+		packed.AccountGasLimits[:],
+		packed.PaymasterAndData[common.AddressLength:userop.PaymasterDataOffset],
+		math.U256Bytes(new(big.Int).Set(packed.PreVerificationGas)),
+		packed.GasFees[:],
+		math.U256Bytes(new(big.Int).Set(chainID)),
+		common.LeftPadBytes(paymaster.Bytes(), 32),
+		uint64Word(validity.ValidUntil),
+		uint64Word(validity.ValidAfter),
+	), nil
+}
+
+// Hash computes VerifyingPaymaster.getHash for a v0.6 operation. senderNonce is
+// the paymaster's senderNonce(sender) counter, which v0.6 signs over.
+func Hash(op userop.UserOperation, paymaster common.Address, chainID, senderNonce *big.Int, validity Validity) (common.Hash, error) {
+	if chainID == nil {
+		return common.Hash{}, errors.New("chain id is required")
+	}
+	if op.Nonce == nil || op.MaxFeePerGas == nil || op.MaxPriorityFeePerGas == nil {
+		return common.Hash{}, errors.New("nonce and max fee fields are required")
+	}
+	if err := validity.check(); err != nil {
+		return common.Hash{}, err
+	}
+	if senderNonce == nil {
+		senderNonce = new(big.Int)
+	}
+	return crypto.Keccak256Hash(
+		common.LeftPadBytes(op.Sender.Bytes(), 32),
+		math.U256Bytes(new(big.Int).Set(op.Nonce.ToInt())),
+		crypto.Keccak256(op.InitCode),
+		crypto.Keccak256(op.CallData),
+		uint64Word(uint64(op.CallGasLimit)),
+		uint64Word(uint64(op.VerificationGasLimit)),
+		uint64Word(uint64(op.PreVerificationGas)),
+		math.U256Bytes(new(big.Int).Set(op.MaxFeePerGas.ToInt())),
+		math.U256Bytes(new(big.Int).Set(op.MaxPriorityFeePerGas.ToInt())),
+		math.U256Bytes(new(big.Int).Set(chainID)),
+		common.LeftPadBytes(paymaster.Bytes(), 32),
+		math.U256Bytes(new(big.Int).Set(senderNonce)),
+		uint64Word(validity.ValidUntil),
+		uint64Word(validity.ValidAfter),
+	), nil
+}
+
+// SignHash signs the EIP-191 personal message of hash, as the paymaster's
+// ECDSA.recover(toEthSignedMessageHash(hash)) expects. v is 27 or 28.
+func SignHash(hash common.Hash, key *ecdsa.PrivateKey) ([]byte, error) {
+	if key == nil {
+		return nil, errors.New("private key is required")
+	}
+	sig, err := crypto.Sign(accounts.TextHash(hash[:]), key)
+	if err != nil {
+		return nil, fmt.Errorf("sign paymaster hash: %w", err)
+	}
+	sig[64] += 27
+	return sig, nil
+}
+
+// RecoverSigner returns the address that produced sig over hash with SignHash.
+func RecoverSigner(hash common.Hash, sig []byte) (common.Address, error) {
+	if len(sig) != SignatureLength {
+		return common.Address{}, fmt.Errorf("%w: signature must be %d bytes", ErrInvalidPaymasterData, SignatureLength)
+	}
+	normalized := append([]byte(nil), sig...)
+	if normalized[64] >= 27 {
+		normalized[64] -= 27
+	}
+	pub, err := crypto.SigToPub(accounts.TextHash(hash[:]), normalized)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("recover signer: %w", err)
+	}
+	return crypto.PubkeyToAddress(*pub), nil
+}
+
+// EncodePaymasterData returns abi.encode(validUntil, validAfter) || signature, the
+// v0.7 paymasterData and the v0.6 paymasterAndData suffix after the address.
+func EncodePaymasterData(validity Validity, sig []byte) []byte {
+	out := make([]byte, 0, TimestampsLength+len(sig))
+	out = append(out, uint64Word(validity.ValidUntil)...)
+	out = append(out, uint64Word(validity.ValidAfter)...)
+	return append(out, sig...)
+}
+
+// DecodePaymasterData splits paymaster data into its validity window and signature.
+func DecodePaymasterData(data []byte) (Validity, []byte, error) {
+	if len(data) != PaymasterDataLength {
+		return Validity{}, nil, fmt.Errorf("%w: want %d bytes, got %d", ErrInvalidPaymasterData, PaymasterDataLength, len(data))
+	}
+	until := new(big.Int).SetBytes(data[:32])
+	after := new(big.Int).SetBytes(data[32:TimestampsLength])
+	if until.Cmp(big.NewInt(maxUint48)) > 0 || after.Cmp(big.NewInt(maxUint48)) > 0 {
+		return Validity{}, nil, fmt.Errorf("%w: timestamps exceed uint48", ErrInvalidPaymasterData)
+	}
+	sig := append([]byte(nil), data[TimestampsLength:]...)
+	return Validity{ValidUntil: until.Uint64(), ValidAfter: after.Uint64()}, sig, nil
+}
+
+// VerifyingPaymaster signs sponsorships for one deployed VerifyingPaymaster contract.
+type VerifyingPaymaster struct {
+	Address common.Address
+	ChainID *big.Int
+	Signer  *ecdsa.PrivateKey
+}
+
+// NewVerifyingPaymaster returns a signer for the paymaster at address on chainID.
+func NewVerifyingPaymaster(address common.Address, chainID *big.Int, signer *ecdsa.PrivateKey) (*VerifyingPaymaster, error) {
+	if chainID == nil {
+		return nil, errors.New("chain id is required")
+	}
+	if signer == nil {
+		return nil, errors.New("signer key is required")
+	}
+	return &VerifyingPaymaster{Address: address, ChainID: new(big.Int).Set(chainID), Signer: signer}, nil
+}
+
+// SignerAddress is the verifyingSigner the contract must be configured with.
+func (p *VerifyingPaymaster) SignerAddress() common.Address {
+	return crypto.PubkeyToAddress(p.Signer.PublicKey)
+}
+
+// SponsorV07 sets op.Paymaster and a signed op.PaymasterData. The paymaster gas
+// limits must already hold their final values because they are signed.
+func (p *VerifyingPaymaster) SponsorV07(op *userop.UserOperationV07, validity Validity) error {
+	if op == nil {
+		return errors.New("user operation is required")
+	}
+	hash, err := HashV07(*op, p.Address, p.ChainID, validity)
+	if err != nil {
+		return err
+	}
+	sig, err := SignHash(hash, p.Signer)
+	if err != nil {
+		return err
+	}
+	paymaster := p.Address
+	op.Paymaster = &paymaster
+	op.PaymasterData = EncodePaymasterData(validity, sig)
+	return nil
+}
+
+// Sponsor sets a signed v0.6 op.PaymasterAndData.
+func (p *VerifyingPaymaster) Sponsor(op *userop.UserOperation, senderNonce *big.Int, validity Validity) error {
+	if op == nil {
+		return errors.New("user operation is required")
+	}
+	hash, err := Hash(*op, p.Address, p.ChainID, senderNonce, validity)
+	if err != nil {
+		return err
+	}
+	sig, err := SignHash(hash, p.Signer)
+	if err != nil {
+		return err
+	}
+	op.PaymasterAndData = append(p.Address.Bytes(), EncodePaymasterData(validity, sig)...)
+	return nil
+}
+
+// VerifyV07 checks that op carries paymaster data signed by this paymaster's key.
+func (p *VerifyingPaymaster) VerifyV07(op userop.UserOperationV07) (Validity, error) {
+	if op.Paymaster == nil || *op.Paymaster != p.Address {
+		return Validity{}, fmt.Errorf("%w: paymaster is not %s", ErrInvalidPaymasterData, p.Address.Hex())
+	}
+	validity, sig, err := DecodePaymasterData(op.PaymasterData)
+	if err != nil {
+		return Validity{}, err
+	}
+	hash, err := HashV07(op, p.Address, p.ChainID, validity)
+	if err != nil {
+		return Validity{}, err
+	}
+	return validity, p.checkSigner(hash, sig)
+}
+
+// Verify checks that a v0.6 op carries paymasterAndData signed by this paymaster's key.
+func (p *VerifyingPaymaster) Verify(op userop.UserOperation, senderNonce *big.Int) (Validity, error) {
+	if len(op.PaymasterAndData) < common.AddressLength || common.BytesToAddress(op.PaymasterAndData[:common.AddressLength]) != p.Address {
+		return Validity{}, fmt.Errorf("%w: paymaster is not %s", ErrInvalidPaymasterData, p.Address.Hex())
+	}
+	validity, sig, err := DecodePaymasterData(op.PaymasterAndData[common.AddressLength:])
+	if err != nil {
+		return Validity{}, err
+	}
+	hash, err := Hash(op, p.Address, p.ChainID, senderNonce, validity)
+	if err != nil {
+		return Validity{}, err
+	}
+	return validity, p.checkSigner(hash, sig)
+}
+
+func (p *VerifyingPaymaster) checkSigner(hash common.Hash, sig []byte) error {
+	signer, err := RecoverSigner(hash, sig)
+	if err != nil {
+		return err
+	}
+	if want := p.SignerAddress(); signer != want {
+		return fmt.Errorf("%w: recovered %s, want %s", ErrWrongSigner, signer.Hex(), want.Hex())
+	}
+	return nil
+}
+
+func uint64Word(v uint64) []byte {
+	return math.U256Bytes(new(big.Int).SetUint64(v))
+}
@@ -0,0 +1,191 @@
+package paymaster_test
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/eipcodelab/eip7702-go/pkg/userop"
+	"github.com/eipcodelab/eip7702-go/pkg/userop/paymaster"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var (
+	testChainID   = big.NewInt(11155111)
+	testPaymaster = common.HexToAddress("0x00000000000000000000000000000000000000f1")
+	testSender    = common.HexToAddress("0x000000000000000000000000000000000000dead")
+)
+
+func mustKey(t *testing.T) *paymaster.VerifyingPaymaster {
+	t.Helper()
+	key, err := crypto.HexToECDSA("4f3edf983ac636a65a842ce7c78d9aa706d3b113bce9c46f30d7d21715b23b1d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pm, err := paymaster.NewVerifyingPaymaster(testPaymaster, testChainID, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pm
+}
+
+func makeOpV07() userop.UserOperationV07 {
+	return userop.UserOperationV07{
+		Sender:                        testSender,
+		Nonce:                         userop.HexBig(big.NewInt(3)),
+		CallData:                      []byte{0x01, 0x02},
+		CallGasLimit:                  userop.HexUint64(100_000),
+		VerificationGasLimit:          userop.HexUint64(200_000),
+		PreVerificationGas:            userop.HexUint64(50_000),
+		MaxFeePerGas:                  userop.HexBig(big.NewInt(3_000_000_000)),
+		MaxPriorityFeePerGas:          userop.HexBig(big.NewInt(1_000_000_000)),
+		PaymasterVerificationGasLimit: userop.HexUint64(60_000),
+		PaymasterPostOpGasLimit:       userop.HexUint64(1),
+		Signature:                     []byte{0xaa},
+	}
+}
+
+func makeOp() userop.UserOperation {
+	return userop.UserOperation{
+		Sender:               testSender,
+		Nonce:                userop.HexBig(big.NewInt(3)),
+		InitCode:             []byte{0x0f},
+		CallData:             []byte{0x01, 0x02},
+		CallGasLimit:         userop.HexUint64(100_000),
+		VerificationGasLimit: userop.HexUint64(200_000),
+		PreVerificationGas:   userop.HexUint64(50_000),
+		MaxFeePerGas:         userop.HexBig(big.NewInt(3_000_000_000)),
+		MaxPriorityFeePerGas: userop.HexBig(big.NewInt(1_000_000_000)),
+		Signature:            []byte{0xaa},
+	}
+}
+
+func abiArgs(t *testing.T, types ...string) abi.Arguments {
+	t.Helper()
+	args := make(abi.Arguments, len(types))
+	for i, name := range types {
+		typ, err := abi.NewType(name, "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		args[i] = abi.Argument{Type: typ}
+	}
+	return args
+}
+
+// word packs two uint128 halves like accountGasLimits and gasFees.
+func word(high, low uint64) [32]byte {
+	var out [32]byte
+	new(big.Int).SetUint64(high).FillBytes(out[:16])
+	new(big.Int).SetUint64(low).FillBytes(out[16:])
+	return out
+}
+
+func TestHashV07MatchesGetHash(t *testing.T) {
+	op := makeOpV07()
+	validity := paymaster.Validity{ValidUntil: 1_900_000_000, ValidAfter: 1_700_000_000}
+	got, err := paymaster.HashV07(op, testPaymaster, testChainID, validity)
+	if err != nil {
+		t.Fatalf("HashV07() error = %v", err)
+	}
+
+	pmGasLimits := word(60_000, 1)
+	args := abiArgs(t, "address", "uint256", "bytes32", "bytes32", "bytes32", "uint256", "uint256", "bytes32", "uint256", "address", "uint48", "uint48")
+	enc, err := args.Pack(
+		testSender, big.NewInt(3),
+		crypto.Keccak256Hash(nil), crypto.Keccak256Hash(op.CallData),
+		word(200_000, 100_000),
+		new(big.Int).SetBytes(pmGasLimits[:]),
+		big.NewInt(50_000),
+		word(1_000_000_000, 3_000_000_000),
+		testChainID, testPaymaster,
+		big.NewInt(1_900_000_000), big.NewInt(1_700_000_000),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := crypto.Keccak256Hash(enc); got != want {
+		t.Fatalf("HashV07() = %s, want %s", got.Hex(), want.Hex())
+	}
+}
+
+func TestHashMatchesV06GetHash(t *testing.T) {
+	op := makeOp()
+	validity := paymaster.Validity{ValidUntil: 1_900_000_000}
+	got, err := paymaster.Hash(op, testPaymaster, testChainID, big.NewInt(2), validity)
+	if err != nil {
+		t.Fatalf("Hash() error = %v", err)
+	}
+
+	args := abiArgs(t, "address", "uint256", "bytes32", "bytes32", "uint256", "uint256", "uint256", "uint256", "uint256", "uint256", "address", "uint256", "uint48", "uint48")
+	enc, err := args.Pack(
+		testSender, big.NewInt(3),
+		crypto.Keccak256Hash(op.InitCode), crypto.Keccak256Hash(op.CallData),
+		big.NewInt(100_000), big.NewInt(200_000), big.NewInt(50_000),
+		big.NewInt(3_000_000_000), big.NewInt(1_000_000_000),
+		testChainID, testPaymaster, big.NewInt(2),
+		big.NewInt(1_900_000_000), big.NewInt(0),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := crypto.Keccak256Hash(enc); got != want {
+		t.Fatalf("Hash() = %s, want %s", got.Hex(), want.Hex())
+	}
+}
+
+func TestSponsorV07Roundtrip(t *testing.T) {
+	pm := mustKey(t)
+	op := makeOpV07()
+	validity := paymaster.Validity{ValidUntil: 1_900_000_000}
+	if err := pm.SponsorV07(&op, validity); err != nil {
+		t.Fatalf("SponsorV07() error = %v", err)
+	}
+	if op.Paymaster == nil || *op.Paymaster != testPaymaster || len(op.PaymasterData) != paymaster.PaymasterDataLength {
+		t.Fatalf("paymaster fields = %v %x", op.Paymaster, op.PaymasterData)
+	}
+	got, err := pm.VerifyV07(op)
+	if err != nil || got != validity {
+		t.Fatalf("VerifyV07() = %+v, %v", got, err)
+	}
+
+	op.CallGasLimit++
+	if _, err := pm.VerifyV07(op); !errors.Is(err, paymaster.ErrWrongSigner) {
+		t.Fatalf("VerifyV07() after tampering error = %v, want ErrWrongSigner", err)
+	}
+}
+
+func TestSponsorV06Roundtrip(t *testing.T) {
+	pm := mustKey(t)
+	op := makeOp()
+	if err := pm.Sponsor(&op, big.NewInt(4), paymaster.Validity{ValidUntil: 1_900_000_000}); err != nil {
+		t.Fatalf("Sponsor() error = %v", err)
+	}
+	if !bytes.Equal(op.PaymasterAndData[:20], testPaymaster.Bytes()) || len(op.PaymasterAndData) != 20+paymaster.PaymasterDataLength {
+		t.Fatalf("paymasterAndData = %x", op.PaymasterAndData)
+	}
+	if _, err := pm.Verify(op, big.NewInt(4)); err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if _, err := pm.Verify(op, big.NewInt(5)); !errors.Is(err, paymaster.ErrWrongSigner) {
+		t.Fatalf("Verify() with other sender nonce error = %v, want ErrWrongSigner", err)
+	}
+}
+
+func TestDecodePaymasterData(t *testing.T) {
+	sig := bytes.Repeat([]byte{0x11}, paymaster.SignatureLength)
+	validity := paymaster.Validity{ValidUntil: 10, ValidAfter: 5}
+	gotValidity, gotSig, err := paymaster.DecodePaymasterData(paymaster.EncodePaymasterData(validity, sig))
+	if err != nil || gotValidity != validity || !bytes.Equal(gotSig, sig) {
+		t.Fatalf("DecodePaymasterData() = %+v, %x, %v", gotValidity, gotSig, err)
+	}
+	if _, _, err := paymaster.DecodePaymasterData(sig); !errors.Is(err, paymaster.ErrInvalidPaymasterData) {
+		t.Fatalf("short data error = %v", err)
+	}
+	if _, err := paymaster.HashV07(makeOpV07(), testPaymaster, testChainID, paymaster.Validity{ValidUntil: 1 << 48}); err == nil {
+		t.Fatal("expected error for validUntil overflowing uint48")
+	}
+}
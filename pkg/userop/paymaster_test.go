@@ -0,0 +1,141 @@
+package userop_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+
+	"github.com/eipcodelab/eip7702-go/pkg/userop"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+var testPaymaster = common.HexToAddress("0x00000000000000000000000000000000000000f1")
+
+// paymasterStandIn serves ERC-7677 and gas estimation calls and records the
+// operations the paymaster saw.
+type paymasterStandIn struct {
+	mu      sync.Mutex
+	methods []string
+	final   map[string]any
+	isFinal bool
+}
+
+func (p *paymasterStandIn) handle(t *testing.T, v06 bool) rpcHandler {
+	return func(call rpcCall) (any, map[string]any) {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.methods = append(p.methods, call.Method)
+		switch call.Method {
+		case "pm_getPaymasterStubData", "pm_getPaymasterData":
+			if len(call.Params) != 4 || string(call.Params[2]) != `"0x1"` {
+				return nil, map[string]any{"code": userop.CodeInvalidParams, "message": "bad params"}
+			}
+			var pmContext map[string]any
+			_ = json.Unmarshal(call.Params[3], &pmContext)
+			if pmContext["policyId"] != "gold" {
+				return nil, map[string]any{"code": userop.CodeInvalidParams, "message": "missing policy"}
+			}
+			if call.Method == "pm_getPaymasterData" {
+				_ = json.Unmarshal(call.Params[0], &p.final)
+				if v06 {
+					return map[string]any{"paymasterAndData": hexutil.Bytes(append(testPaymaster.Bytes(), 0xf1, 0xf1))}, nil
+				}
+				return map[string]any{"paymaster": testPaymaster, "paymasterData": "0xf1f1"}, nil
+			}
+			if v06 {
+				return map[string]any{"paymasterAndData": hexutil.Bytes(append(testPaymaster.Bytes(), 0x00, 0x00)), "isFinal": p.isFinal}, nil
+			}
+			return map[string]any{
+				"sponsor":                       map[string]any{"name": "Gold"},
+				"paymaster":                     testPaymaster,
+				"paymasterData":                 "0x0000",
+				"paymasterVerificationGasLimit": "0x7530",
+				"paymasterPostOpGasLimit":       "0x2710",
+				"isFinal":                       p.isFinal,
+			}, nil
+		case "eth_estimateUserOperationGas":
+			return map[string]any{
+				"preVerificationGas":            "0xc350",
+				"verificationGasLimit":          "0x186a0",
+				"callGasLimit":                  "0x9c40",
+				"paymasterVerificationGasLimit": "0x4e20",
+				"paymasterPostOpGasLimit":       "0x1",
+			}, nil
+		default:
+			t.Errorf("unexpected method %s", call.Method)
+			return nil, map[string]any{"code": -32601, "message": "method not found"}
+		}
+	}
+}
+
+var goldPolicy = map[string]any{"policyId": "gold"}
+
+func TestSponsorUserOperationV07(t *testing.T) {
+	standIn := &paymasterStandIn{}
+	srv := newRPCServer(t, standIn.handle(t, false))
+	pm := userop.NewPaymasterClient(srv.URL)
+	bundler := userop.NewBundlerClient(srv.URL)
+
+	op := makeUserOpV07()
+	op.Paymaster, op.PaymasterData = nil, nil
+	op.PaymasterVerificationGasLimit, op.PaymasterPostOpGasLimit = 0, 0
+
+	sponsor, err := pm.SponsorUserOperationV07(context.Background(), bundler, &op, userop.EntryPointV07Address, big.NewInt(1), goldPolicy)
+	if err != nil {
+		t.Fatalf("SponsorUserOperationV07() error = %v", err)
+	}
+	if sponsor == nil || sponsor.Name != "Gold" {
+		t.Fatalf("sponsor = %+v", sponsor)
+	}
+	want := []string{"pm_getPaymasterStubData", "eth_estimateUserOperationGas", "pm_getPaymasterData"}
+	if len(standIn.methods) != len(want) {
+		t.Fatalf("methods = %v, want %v", standIn.methods, want)
+	}
+	for i := range want {
+		if standIn.methods[i] != want[i] {
+			t.Fatalf("methods = %v, want %v", standIn.methods, want)
+		}
+	}
+	if op.Paymaster == nil || *op.Paymaster != testPaymaster || !bytes.Equal(op.PaymasterData, []byte{0xf1, 0xf1}) {
+		t.Fatalf("paymaster fields = %v %x", op.Paymaster, op.PaymasterData)
+	}
+	if op.CallGasLimit != 0x9c40 || op.PaymasterVerificationGasLimit != 0x4e20 || op.PaymasterPostOpGasLimit != 0x2710 {
+		t.Fatalf("gas = call %d, pm verification %d, pm postOp %d", op.CallGasLimit, op.PaymasterVerificationGasLimit, op.PaymasterPostOpGasLimit)
+	}
+	if standIn.final["callGasLimit"] != "0x9c40" {
+		t.Fatalf("pm_getPaymasterData saw callGasLimit %v, want estimated value", standIn.final["callGasLimit"])
+	}
+}
+
+func TestSponsorUserOperationSkipsFinalCall(t *testing.T) {
+	standIn := &paymasterStandIn{isFinal: true}
+	srv := newRPCServer(t, standIn.handle(t, true))
+	pm := userop.NewPaymasterClient(srv.URL)
+
+	op := makeUserOp()
+	if _, err := pm.SponsorUserOperation(context.Background(), userop.NewBundlerClient(srv.URL), &op, userop.EntryPointV06Address, big.NewInt(1), goldPolicy); err != nil {
+		t.Fatalf("SponsorUserOperation() error = %v", err)
+	}
+	if len(standIn.methods) != 2 {
+		t.Fatalf("methods = %v, want stub and estimate only", standIn.methods)
+	}
+	if !bytes.Equal(op.PaymasterAndData, append(testPaymaster.Bytes(), 0x00, 0x00)) || op.CallGasLimit != 0x9c40 {
+		t.Fatalf("op = %+v", op)
+	}
+}
+
+func TestPaymasterDataShapeMismatch(t *testing.T) {
+	op := makeUserOpV07()
+	if err := (userop.PaymasterData{PaymasterAndData: testPaymaster.Bytes()}).ApplyToV07(&op); !errors.Is(err, userop.ErrPaymasterShape) {
+		t.Fatalf("ApplyToV07() error = %v, want ErrPaymasterShape", err)
+	}
+	legacy := makeUserOp()
+	if err := (userop.PaymasterData{Paymaster: &testPaymaster}).ApplyTo(&legacy); !errors.Is(err, userop.ErrPaymasterShape) {
+		t.Fatalf("ApplyTo() error = %v, want ErrPaymasterShape", err)
+	}
+}
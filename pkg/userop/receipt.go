@@ -0,0 +1,190 @@
+package userop
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var (
+	// UserOperationEventTopic is topic0 of the EntryPoint UserOperationEvent log.
+	UserOperationEventTopic = crypto.Keccak256Hash([]byte("UserOperationEvent(bytes32,address,address,uint256,bool,uint256,uint256)"))
+
+	// ErrNotFound is returned when the bundler does not know the requested userOp.
+	ErrNotFound = errors.New("user operation not found")
+)
+
+// GasEstimate is the eth_estimateUserOperationGas result. Paymaster limits are only
+// returned for v0.7 and later EntryPoints.
+type GasEstimate struct {
+	PreVerificationGas            hexutil.Uint64  `json:"preVerificationGas"`
+	VerificationGasLimit          hexutil.Uint64  `json:"verificationGasLimit"`
+	CallGasLimit                  hexutil.Uint64  `json:"callGasLimit"`
+	PaymasterVerificationGasLimit *hexutil.Uint64 `json:"paymasterVerificationGasLimit,omitempty"`
+	PaymasterPostOpGasLimit       *hexutil.Uint64 `json:"paymasterPostOpGasLimit,omitempty"`
+}
+
+// ApplyTo copies the estimated gas limits into a v0.6 operation.
+func (e GasEstimate) ApplyTo(op *UserOperation) {
+	op.PreVerificationGas = e.PreVerificationGas
+	op.VerificationGasLimit = e.VerificationGasLimit
+	op.CallGasLimit = e.CallGasLimit
+}
+
+// ApplyToV07 copies the estimated gas limits into a v0.7 operation.
+// Paymaster limits are only overwritten when the estimate includes them.
+func (e GasEstimate) ApplyToV07(op *UserOperationV07) {
+	op.PreVerificationGas = e.PreVerificationGas
+	op.VerificationGasLimit = e.VerificationGasLimit
+	op.CallGasLimit = e.CallGasLimit
+	if e.PaymasterVerificationGasLimit != nil {
+		op.PaymasterVerificationGasLimit = *e.PaymasterVerificationGasLimit
+	}
+	if e.PaymasterPostOpGasLimit != nil {
+		op.PaymasterPostOpGasLimit = *e.PaymasterPostOpGasLimit
+	}
+}
+
+// UserOperationByHash is the eth_getUserOperationByHash result. Block fields are
+// empty while the operation is still in the mempool.
+type UserOperationByHash struct {
+	UserOperation   json.RawMessage `json:"userOperation"`
+	EntryPoint      common.Address  `json:"entryPoint"`
+	BlockNumber     *hexutil.Big    `json:"blockNumber"`
+	BlockHash       *common.Hash    `json:"blockHash"`
+	TransactionHash *common.Hash    `json:"transactionHash"`
+}
+
+// TransactionReceipt is the bundle transaction receipt embedded in a userOp receipt.
+type TransactionReceipt struct {
+	TransactionHash   common.Hash     `json:"transactionHash"`
+	BlockHash         common.Hash     `json:"blockHash"`
+	BlockNumber       *hexutil.Big    `json:"blockNumber"`
+	From              common.Address  `json:"from"`
+	To                *common.Address `json:"to"`
+	GasUsed           hexutil.Uint64  `json:"gasUsed"`
+	EffectiveGasPrice *hexutil.Big    `json:"effectiveGasPrice"`
+	Status            hexutil.Uint64  `json:"status"`
+	Logs              []*types.Log    `json:"logs"`
+}
+
+// UserOperationReceipt is the eth_getUserOperationReceipt result.
+// Logs are the logs emitted during this operation's execution.
+type UserOperationReceipt struct {
+	UserOpHash    common.Hash        `json:"userOpHash"`
+	EntryPoint    common.Address     `json:"entryPoint"`
+	Sender        common.Address     `json:"sender"`
+	Nonce         *hexutil.Big       `json:"nonce"`
+	Paymaster     common.Address     `json:"paymaster"`
+	ActualGasCost *hexutil.Big       `json:"actualGasCost"`
+	ActualGasUsed *hexutil.Big       `json:"actualGasUsed"`
+	Success       bool               `json:"success"`
+	Reason        string             `json:"reason,omitempty"`
+	Logs          []*types.Log       `json:"logs"`
+	Receipt       TransactionReceipt `json:"receipt"`
+
+	// Event is the decoded UserOperationEvent, when present in the bundle receipt logs.
+	Event *UserOperationEvent `json:"-"`
+}
+
+// UserOperationEvent is the EntryPoint log emitted for every executed userOp.
+type UserOperationEvent struct {
+	UserOpHash    common.Hash
+	Sender        common.Address
+	Paymaster     common.Address
+	Nonce         *big.Int
+	Success       bool
+	ActualGasCost *big.Int
+	ActualGasUsed *big.Int
+}
+
+// DecodeUserOperationEvent decodes one UserOperationEvent log.
+func DecodeUserOperationEvent(log *types.Log) (*UserOperationEvent, error) {
+	if log == nil || len(log.Topics) != 4 || log.Topics[0] != UserOperationEventTopic {
+		return nil, errors.New("log is not a UserOperationEvent")
+	}
+	if len(log.Data) != 4*32 {
+		return nil, fmt.Errorf("UserOperationEvent data must be 128 bytes, got %d", len(log.Data))
+	}
+	return &UserOperationEvent{
+		UserOpHash:    log.Topics[1],
+		Sender:        common.BytesToAddress(log.Topics[2][:]),
+		Paymaster:     common.BytesToAddress(log.Topics[3][:]),
+		Nonce:         new(big.Int).SetBytes(log.Data[0:32]),
+		Success:       new(big.Int).SetBytes(log.Data[32:64]).Sign() != 0,
+		ActualGasCost: new(big.Int).SetBytes(log.Data[64:96]),
+		ActualGasUsed: new(big.Int).SetBytes(log.Data[96:128]),
+	}, nil
+}
+
+// findUserOperationEvent returns the UserOperationEvent for userOpHash emitted by entryPoint.
+func findUserOperationEvent(logs []*types.Log, entryPoint common.Address, userOpHash common.Hash) *UserOperationEvent {
+	for _, log := range logs {
+		if log == nil || log.Address != entryPoint || len(log.Topics) < 2 || log.Topics[0] != UserOperationEventTopic || log.Topics[1] != userOpHash {
+			continue
+		}
+		if event, err := DecodeUserOperationEvent(log); err == nil {
+			return event
+		}
+	}
+	return nil
+}
+
+// UserOperationRevertReasonTopic is topic0 of the EntryPoint UserOperationRevertReason log.
+var UserOperationRevertReasonTopic = crypto.Keccak256Hash([]byte("UserOperationRevertReason(bytes32,address,uint256,bytes)"))
+
+// UserOperationRevertReason is the EntryPoint log emitted when the account call reverts.
+type UserOperationRevertReason struct {
+	UserOpHash   common.Hash
+	Sender       common.Address
+	Nonce        *big.Int
+	RevertReason []byte
+}
+
+// DecodeUserOperationRevertReason decodes one UserOperationRevertReason log.
+func DecodeUserOperationRevertReason(log *types.Log) (*UserOperationRevertReason, error) {
+	if log == nil || len(log.Topics) != 3 || log.Topics[0] != UserOperationRevertReasonTopic {
+		return nil, errors.New("log is not a UserOperationRevertReason")
+	}
+	if len(log.Data) < 3*32 {
+		return nil, errors.New("UserOperationRevertReason data is too short")
+	}
+	offset := new(big.Int).SetBytes(log.Data[32:64])
+	if !offset.IsUint64() || offset.Uint64() > uint64(len(log.Data)-32) {
+		return nil, errors.New("UserOperationRevertReason offset is out of range")
+	}
+	start := offset.Uint64()
+	length := new(big.Int).SetBytes(log.Data[start : start+32])
+	if !length.IsUint64() || length.Uint64() > uint64(len(log.Data))-start-32 {
+		return nil, errors.New("UserOperationRevertReason length is out of range")
+	}
+	reason := make([]byte, length.Uint64())
+	copy(reason, log.Data[start+32:])
+	return &UserOperationRevertReason{
+		UserOpHash:   log.Topics[1],
+		Sender:       common.BytesToAddress(log.Topics[2][:]),
+		Nonce:        new(big.Int).SetBytes(log.Data[0:32]),
+		RevertReason: reason,
+	}, nil
+}
+
+// RevertReason returns the decoded UserOperationRevertReason log for this receipt, if any.
+func (r *UserOperationReceipt) RevertReason() *UserOperationRevertReason {
+	for _, logs := range [][]*types.Log{r.Logs, r.Receipt.Logs} {
+		for _, log := range logs {
+			if log == nil || log.Address != r.EntryPoint || len(log.Topics) < 2 || log.Topics[1] != r.UserOpHash {
+				continue
+			}
+			if reason, err := DecodeUserOperationRevertReason(log); err == nil {
+				return reason
+			}
+		}
+	}
+	return nil
+}
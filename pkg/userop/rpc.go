@@ -0,0 +1,79 @@
+package userop
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// EstimateUserOperationGas calls eth_estimateUserOperationGas for a v0.6 operation.
+// The signature may be a dummy of the right length.
+func (c *BundlerClient) EstimateUserOperationGas(ctx context.Context, op UserOperation, entryPoint common.Address) (*GasEstimate, error) {
+	if err := checkEntryPoint(entryPoint, EntryPointV06); err != nil {
+		return nil, err
+	}
+	var estimate GasEstimate
+	if err := c.Call(ctx, &estimate, "eth_estimateUserOperationGas", op, entryPoint); err != nil {
+		return nil, err
+	}
+	return &estimate, nil
+}
+
+// EstimateUserOperationGasV07 calls eth_estimateUserOperationGas for a v0.7 or v0.8 operation.
+func (c *BundlerClient) EstimateUserOperationGasV07(ctx context.Context, op UserOperationV07, entryPoint common.Address) (*GasEstimate, error) {
+	if err := checkEntryPoint(entryPoint, EntryPointV07, EntryPointV08); err != nil {
+		return nil, err
+	}
+	var estimate GasEstimate
+	if err := c.Call(ctx, &estimate, "eth_estimateUserOperationGas", op, entryPoint); err != nil {
+		return nil, err
+	}
+	return &estimate, nil
+}
+
+// GetUserOperationByHash calls eth_getUserOperationByHash. It returns ErrNotFound
+// when the bundler does not know the hash.
+func (c *BundlerClient) GetUserOperationByHash(ctx context.Context, hash common.Hash) (*UserOperationByHash, error) {
+	var out *UserOperationByHash
+	if err := c.Call(ctx, &out, "eth_getUserOperationByHash", hash); err != nil {
+		return nil, err
+	}
+	if out == nil {
+		return nil, ErrNotFound
+	}
+	return out, nil
+}
+
+// GetUserOperationReceipt calls eth_getUserOperationReceipt. It returns ErrNotFound
+// while the operation is not yet included.
+func (c *BundlerClient) GetUserOperationReceipt(ctx context.Context, hash common.Hash) (*UserOperationReceipt, error) {
+	var out *UserOperationReceipt
+	if err := c.Call(ctx, &out, "eth_getUserOperationReceipt", hash); err != nil {
+		return nil, err
+	}
+	if out == nil {
+		return nil, ErrNotFound
+	}
+	out.Event = findUserOperationEvent(out.Receipt.Logs, out.EntryPoint, hash)
+	return out, nil
+}
+
+// SupportedEntryPoints calls eth_supportedEntryPoints.
+func (c *BundlerClient) SupportedEntryPoints(ctx context.Context) ([]common.Address, error) {
+	var out []common.Address
+	if err := c.Call(ctx, &out, "eth_supportedEntryPoints"); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ChainID calls eth_chainId.
+func (c *BundlerClient) ChainID(ctx context.Context) (*big.Int, error) {
+	var out hexutil.Big
+	if err := c.Call(ctx, &out, "eth_chainId"); err != nil {
+		return nil, err
+	}
+	return out.ToInt(), nil
+}
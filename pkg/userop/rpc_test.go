@@ -0,0 +1,188 @@
+package userop_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/eipcodelab/eip7702-go/pkg/userop"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+type rpcCall struct {
+	ID     json.RawMessage   `json:"id"`
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+}
+
+// rpcHandler returns a result or an error object for one call.
+type rpcHandler func(call rpcCall) (any, map[string]any)
+
+// newRPCServer serves single JSON-RPC requests from handler.
+func newRPCServer(t *testing.T, handler rpcHandler) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var call rpcCall
+		if err := json.NewDecoder(r.Body).Decode(&call); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		result, rpcErr := handler(call)
+		resp := map[string]any{"jsonrpc": "2.0", "id": call.ID}
+		if rpcErr != nil {
+			resp["error"] = rpcErr
+		} else {
+			resp["result"] = result
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+var testUserOpHash = common.HexToHash("0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa")
+
+func receiptFixture(success bool) map[string]any {
+	sender := makeUserOpV07().Sender
+	paymaster := common.Address{}
+	successWord := "0"
+	if success {
+		successWord = "1"
+	}
+	data := fmt.Sprintf("0x%064x%064s%064x%064x", 1, successWord, 21_000_000, 150_000)
+	eventLog := map[string]any{
+		"address":          userop.EntryPointV07Address,
+		"topics":           []common.Hash{userop.UserOperationEventTopic, testUserOpHash, common.BytesToHash(sender.Bytes()), common.BytesToHash(paymaster.Bytes())},
+		"data":             data,
+		"blockNumber":      "0x10",
+		"transactionHash":  common.HexToHash("0xbb"),
+		"transactionIndex": "0x0",
+		"blockHash":        common.HexToHash("0xcc"),
+		"logIndex":         "0x1",
+		"removed":          false,
+	}
+	return map[string]any{
+		"userOpHash":    testUserOpHash,
+		"entryPoint":    userop.EntryPointV07Address,
+		"sender":        sender,
+		"nonce":         "0x1",
+		"paymaster":     paymaster,
+		"actualGasCost": "0x1406f40",
+		"actualGasUsed": "0x249f0",
+		"success":       success,
+		"logs":          []any{},
+		"receipt": map[string]any{
+			"transactionHash": common.HexToHash("0xbb"),
+			"blockHash":       common.HexToHash("0xcc"),
+			"blockNumber":     "0x10",
+			"from":            common.HexToAddress("0x0b"),
+			"to":              userop.EntryPointV07Address,
+			"gasUsed":         "0x30d40",
+			"status":          "0x1",
+			"logs":            []any{eventLog},
+		},
+	}
+}
+
+func TestBundlerRPCMethods(t *testing.T) {
+	srv := newRPCServer(t, func(call rpcCall) (any, map[string]any) {
+		switch call.Method {
+		case "eth_estimateUserOperationGas":
+			if len(call.Params) != 2 {
+				return nil, map[string]any{"code": -32602, "message": "bad params"}
+			}
+			return map[string]any{
+				"preVerificationGas":            "0xc350",
+				"verificationGasLimit":          "0x186a0",
+				"callGasLimit":                  "0x7530",
+				"paymasterVerificationGasLimit": "0x4e20",
+				"paymasterPostOpGasLimit":       "0x0",
+			}, nil
+		case "eth_getUserOperationByHash":
+			return map[string]any{
+				"userOperation":   makeUserOpV07(),
+				"entryPoint":      userop.EntryPointV07Address,
+				"blockNumber":     "0x10",
+				"blockHash":       common.HexToHash("0xcc"),
+				"transactionHash": common.HexToHash("0xbb"),
+			}, nil
+		case "eth_getUserOperationReceipt":
+			return receiptFixture(true), nil
+		case "eth_supportedEntryPoints":
+			return []common.Address{userop.EntryPointV07Address, userop.EntryPointV08Address}, nil
+		case "eth_chainId":
+			return "0x2105", nil
+		}
+		return nil, map[string]any{"code": -32601, "message": "method not found"}
+	})
+	client := userop.NewBundlerClient(srv.URL)
+	ctx := context.Background()
+
+	estimate, err := client.EstimateUserOperationGasV07(ctx, makeUserOpV07(), userop.EntryPointV07Address)
+	if err != nil {
+		t.Fatalf("estimate: %v", err)
+	}
+	if estimate.CallGasLimit != 30_000 || estimate.PaymasterVerificationGasLimit == nil || *estimate.PaymasterVerificationGasLimit != 20_000 {
+		t.Fatalf("unexpected estimate: %+v", estimate)
+	}
+	if _, err := client.EstimateUserOperationGas(ctx, makeUserOp(), userop.EntryPointV06Address); err != nil {
+		t.Fatalf("estimate v0.6: %v", err)
+	}
+
+	byHash, err := client.GetUserOperationByHash(ctx, testUserOpHash)
+	if err != nil {
+		t.Fatalf("by hash: %v", err)
+	}
+	if byHash.EntryPoint != userop.EntryPointV07Address || byHash.BlockNumber.ToInt().Int64() != 16 || len(byHash.UserOperation) == 0 {
+		t.Fatalf("unexpected by-hash result: %+v", byHash)
+	}
+
+	receipt, err := client.GetUserOperationReceipt(ctx, testUserOpHash)
+	if err != nil {
+		t.Fatalf("receipt: %v", err)
+	}
+	if !receipt.Success || receipt.Receipt.Status != 1 || len(receipt.Receipt.Logs) != 1 {
+		t.Fatalf("unexpected receipt: %+v", receipt)
+	}
+	event := receipt.Event
+	if event == nil || !event.Success || event.Sender != receipt.Sender || event.ActualGasUsed.Int64() != 150_000 || event.Nonce.Int64() != 1 {
+		t.Fatalf("unexpected decoded event: %+v", event)
+	}
+
+	entryPoints, err := client.SupportedEntryPoints(ctx)
+	if err != nil || len(entryPoints) != 2 || entryPoints[1] != userop.EntryPointV08Address {
+		t.Fatalf("unexpected entry points: %v %v", entryPoints, err)
+	}
+	chainID, err := client.ChainID(ctx)
+	if err != nil || chainID.Cmp(big.NewInt(8453)) != 0 {
+		t.Fatalf("unexpected chain id: %v %v", chainID, err)
+	}
+
+	var raw hexutil.Big
+	if err := client.Call(ctx, &raw, "eth_chainId"); err != nil || raw.ToInt().Int64() != 8453 {
+		t.Fatalf("generic call: %v", err)
+	}
+	if err := client.Call(ctx, nil, "eth_unknown"); err == nil {
+		t.Fatal("expected rpc error for unknown method")
+	}
+}
+
+func TestBundlerRPCNotFound(t *testing.T) {
+	srv := newRPCServer(t, func(call rpcCall) (any, map[string]any) {
+		return nil, nil
+	})
+	client := userop.NewBundlerClient(srv.URL)
+	if _, err := client.GetUserOperationReceipt(context.Background(), testUserOpHash); !errors.Is(err, userop.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for receipt, got %v", err)
+	}
+	if _, err := client.GetUserOperationByHash(context.Background(), testUserOpHash); !errors.Is(err, userop.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for by-hash, got %v", err)
+	}
+}
@@ -0,0 +1,247 @@
+package userop
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/eipcodelab/eip7702-go/pkg/eip7702"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// RPCCaller performs JSON-RPC calls. *BundlerClient implements it for any endpoint, including nodes.
+type RPCCaller interface {
+	Call(ctx context.Context, result any, method string, params ...any) error
+}
+
+// SubmitOptions configures a self-bundled transaction. Zero values are filled from the node.
+type SubmitOptions struct {
+	// Beneficiary receives the operations' gas refunds. Defaults to the sponsor.
+	Beneficiary common.Address
+	// Authorizations turn the bundle into a set-code transaction, so delegations
+	// land in the same transaction as the operations that rely on them. When the
+	// sponsor signs its own authorization, the authorization nonce must be the
+	// transaction nonce + 1.
+	Authorizations []eip7702.Authorization
+	// GasLimit defaults to eth_estimateGas plus 20%.
+	GasLimit uint64
+	// MaxPriorityFeePerGas defaults to eth_maxPriorityFeePerGas and MaxFeePerGas
+	// to twice the latest base fee plus the tip.
+	MaxFeePerGas         *big.Int
+	MaxPriorityFeePerGas *big.Int
+	// Nonce defaults to the sponsor's pending nonce.
+	Nonce *uint64
+}
+
+// BundleTransaction is a signed handleOps transaction.
+type BundleTransaction struct {
+	Hash                 common.Hash
+	Raw                  []byte
+	Nonce                uint64
+	GasLimit             uint64
+	MaxFeePerGas         *big.Int
+	MaxPriorityFeePerGas *big.Int
+}
+
+// SelfBundler sends handleOps transactions from a sponsor EOA, for when no
+// bundler will accept an operation. The sponsor pays the bundle gas and is
+// refunded by the EntryPoint through the beneficiary.
+type SelfBundler struct {
+	node       RPCCaller
+	key        *ecdsa.PrivateKey
+	from       common.Address
+	entryPoint common.Address
+	chainID    *big.Int
+}
+
+// NewSelfBundler creates a self-bundler that signs with key and talks to an Ethereum node.
+func NewSelfBundler(node RPCCaller, key *ecdsa.PrivateKey, entryPoint common.Address, chainID *big.Int) (*SelfBundler, error) {
+	if node == nil {
+		return nil, errors.New("node is required")
+	}
+	if key == nil {
+		return nil, errors.New("sponsor key is required")
+	}
+	if chainID == nil {
+		return nil, errNilHashChainID
+	}
+	return &SelfBundler{
+		node:       node,
+		key:        key,
+		from:       crypto.PubkeyToAddress(key.PublicKey),
+		entryPoint: entryPoint,
+		chainID:    new(big.Int).Set(chainID),
+	}, nil
+}
+
+// Address returns the sponsor account.
+func (b *SelfBundler) Address() common.Address {
+	return b.from
+}
+
+// HandleOps bundles v0.6 operations and sends the transaction.
+func (b *SelfBundler) HandleOps(ctx context.Context, ops []UserOperation, opts SubmitOptions) (*BundleTransaction, error) {
+	if err := checkEntryPoint(b.entryPoint, EntryPointV06); err != nil {
+		return nil, err
+	}
+	calldata, err := EncodeHandleOps(ops, b.beneficiary(opts))
+	if err != nil {
+		return nil, err
+	}
+	return b.Submit(ctx, calldata, opts)
+}
+
+// HandleOpsV07 bundles v0.7 or v0.8 operations and sends the transaction.
+func (b *SelfBundler) HandleOpsV07(ctx context.Context, ops []UserOperationV07, opts SubmitOptions) (*BundleTransaction, error) {
+	if err := checkEntryPoint(b.entryPoint, EntryPointV07, EntryPointV08); err != nil {
+		return nil, err
+	}
+	calldata, err := EncodeHandleOpsV07(ops, b.beneficiary(opts))
+	if err != nil {
+		return nil, err
+	}
+	return b.Submit(ctx, calldata, opts)
+}
+
+// Submit signs a transaction calling the EntryPoint with calldata and sends it
+// with eth_sendRawTransaction.
+func (b *SelfBundler) Submit(ctx context.Context, calldata []byte, opts SubmitOptions) (*BundleTransaction, error) {
+	tx, err := b.SignTransaction(ctx, calldata, opts)
+	if err != nil {
+		return nil, err
+	}
+	var hash common.Hash
+	if err := b.node.Call(ctx, &hash, "eth_sendRawTransaction", hexutil.Bytes(tx.Raw)); err != nil {
+		return nil, fmt.Errorf("send bundle transaction: %w", err)
+	}
+	if hash != tx.Hash {
+		return nil, fmt.Errorf("%w: node returned transaction hash %s, expected %s", ErrInvalidResponse, hash.Hex(), tx.Hash.Hex())
+	}
+	return tx, nil
+}
+
+// SignTransaction fills nonce, fees and gas from the node where opts leaves them
+// unset and signs the transaction without sending it. A failing gas estimate is
+// returned as a *FailedOp when the EntryPoint reports one.
+func (b *SelfBundler) SignTransaction(ctx context.Context, calldata []byte, opts SubmitOptions) (*BundleTransaction, error) {
+	out := &BundleTransaction{GasLimit: opts.GasLimit, MaxFeePerGas: opts.MaxFeePerGas, MaxPriorityFeePerGas: opts.MaxPriorityFeePerGas}
+	if opts.Nonce != nil {
+		out.Nonce = *opts.Nonce
+	} else {
+		var nonce hexutil.Uint64
+		if err := b.node.Call(ctx, &nonce, "eth_getTransactionCount", b.from, "pending"); err != nil {
+			return nil, fmt.Errorf("read sponsor nonce: %w", err)
+		}
+		out.Nonce = uint64(nonce)
+	}
+	if err := b.fillFees(ctx, out); err != nil {
+		return nil, err
+	}
+	if out.GasLimit == 0 {
+		gas, err := b.estimateGas(ctx, calldata, opts.Authorizations)
+		if err != nil {
+			return nil, err
+		}
+		out.GasLimit = gas + gas/5
+	}
+
+	if len(opts.Authorizations) > 0 {
+		tx := &eip7702.SetCodeTx{
+			ChainID:              b.chainID,
+			Nonce:                out.Nonce,
+			MaxPriorityFeePerGas: out.MaxPriorityFeePerGas,
+			MaxFeePerGas:         out.MaxFeePerGas,
+			GasLimit:             out.GasLimit,
+			Destination:          b.entryPoint,
+			Value:                new(big.Int),
+			Data:                 calldata,
+			AuthorizationList:    opts.Authorizations,
+		}
+		if err := tx.Sign(b.key); err != nil {
+			return nil, err
+		}
+		raw, err := tx.EncodeTypedTransaction()
+		if err != nil {
+			return nil, err
+		}
+		out.Raw, out.Hash = raw, crypto.Keccak256Hash(raw)
+		return out, nil
+	}
+
+	to := b.entryPoint
+	tx, err := types.SignNewTx(b.key, types.LatestSignerForChainID(b.chainID), &types.DynamicFeeTx{
+		ChainID:   b.chainID,
+		Nonce:     out.Nonce,
+		GasTipCap: out.MaxPriorityFeePerGas,
+		GasFeeCap: out.MaxFeePerGas,
+		Gas:       out.GasLimit,
+		To:        &to,
+		Data:      calldata,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("sign bundle transaction: %w", err)
+	}
+	raw, err := tx.MarshalBinary()
+	if err != nil {
+		return nil, fmt.Errorf("encode bundle transaction: %w", err)
+	}
+	out.Raw, out.Hash = raw, tx.Hash()
+	return out, nil
+}
+
+func (b *SelfBundler) beneficiary(opts SubmitOptions) common.Address {
+	if opts.Beneficiary == (common.Address{}) {
+		return b.from
+	}
+	return opts.Beneficiary
+}
+
+func (b *SelfBundler) fillFees(ctx context.Context, tx *BundleTransaction) error {
+	if tx.MaxPriorityFeePerGas == nil {
+		tip, err := maxPriorityFee(ctx, b.node, "eth_maxPriorityFeePerGas")
+		if err != nil {
+			return err
+		}
+		tx.MaxPriorityFeePerGas = tip
+	}
+	if tx.MaxFeePerGas == nil {
+		baseFee, err := latestBaseFee(ctx, b.node)
+		if err != nil {
+			return err
+		}
+		tx.MaxFeePerGas = headroomMaxFee(baseFee, tx.MaxPriorityFeePerGas)
+	}
+	if tx.MaxPriorityFeePerGas.Cmp(tx.MaxFeePerGas) > 0 {
+		return fmt.Errorf("max priority fee %s exceeds max fee %s", tx.MaxPriorityFeePerGas, tx.MaxFeePerGas)
+	}
+	return nil
+}
+
+func (b *SelfBundler) estimateGas(ctx context.Context, calldata []byte, auths []eip7702.Authorization) (uint64, error) {
+	call := map[string]any{"from": b.from, "to": b.entryPoint, "data": hexutil.Bytes(calldata)}
+	if len(auths) > 0 {
+		list := make([]*EIP7702Auth, len(auths))
+		for i, auth := range auths {
+			list[i] = NewEIP7702Auth(auth)
+		}
+		call["authorizationList"] = list
+	}
+	var gas hexutil.Uint64
+	if err := b.node.Call(ctx, &gas, "eth_estimateGas", call); err != nil {
+		var rpcErr *RPCError
+		if errors.As(err, &rpcErr) {
+			if data, ok := rpcErr.RevertData(); ok {
+				if failed, ok := DecodeFailedOp(data); ok {
+					return 0, fmt.Errorf("estimate handleOps: %w", failed)
+				}
+			}
+		}
+		return 0, fmt.Errorf("estimate handleOps: %w", err)
+	}
+	return uint64(gas), nil
+}
@@ -0,0 +1,163 @@
+package userop_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/eipcodelab/eip7702-go/pkg/eip7702"
+	"github.com/eipcodelab/eip7702-go/pkg/userop"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// fakeNode answers the calls SelfBundler makes and records what it was sent.
+type fakeNode struct {
+	estimateErr map[string]any
+	estimate    map[string]json.RawMessage
+	raw         []byte
+}
+
+func (n *fakeNode) handle(call rpcCall) (any, map[string]any) {
+	switch call.Method {
+	case "eth_getTransactionCount":
+		return "0x5", nil
+	case "eth_maxPriorityFeePerGas":
+		return "0x3b9aca00", nil // 1 gwei
+	case "eth_getBlockByNumber":
+		return map[string]any{"baseFeePerGas": "0x2540be400"}, nil // 10 gwei
+	case "eth_estimateGas":
+		_ = json.Unmarshal(call.Params[0], &n.estimate)
+		if n.estimateErr != nil {
+			return nil, n.estimateErr
+		}
+		return "0x186a0", nil // 100k
+	case "eth_sendRawTransaction":
+		var raw hexutil.Bytes
+		_ = json.Unmarshal(call.Params[0], &raw)
+		n.raw = raw
+		return crypto.Keccak256Hash(raw), nil
+	default:
+		return nil, map[string]any{"code": -32601, "message": "method not found"}
+	}
+}
+
+func newSelfBundler(t *testing.T, node *fakeNode, entryPoint common.Address) (*userop.SelfBundler, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := newRPCServer(t, node.handle)
+	b, err := userop.NewSelfBundler(userop.NewBundlerClient(srv.URL), key, entryPoint, big.NewInt(1))
+	if err != nil {
+		t.Fatalf("NewSelfBundler() error = %v", err)
+	}
+	if b.Address() != crypto.PubkeyToAddress(key.PublicKey) {
+		t.Fatalf("Address() = %s", b.Address().Hex())
+	}
+	return b, key
+}
+
+func TestSelfBundlerHandleOpsV07(t *testing.T) {
+	node := &fakeNode{}
+	b, _ := newSelfBundler(t, node, userop.EntryPointV07Address)
+	sponsor := b.Address()
+	ops := []userop.UserOperationV07{makeUserOpV07()}
+
+	sent, err := b.HandleOpsV07(context.Background(), ops, userop.SubmitOptions{})
+	if err != nil {
+		t.Fatalf("HandleOpsV07() error = %v", err)
+	}
+	if sent.Nonce != 5 || sent.GasLimit != 120_000 || sent.MaxFeePerGas.Cmp(big.NewInt(21_000_000_000)) != 0 {
+		t.Fatalf("transaction = %+v", sent)
+	}
+
+	var tx types.Transaction
+	if err := tx.UnmarshalBinary(node.raw); err != nil {
+		t.Fatalf("decode sent transaction: %v", err)
+	}
+	from, err := types.Sender(types.LatestSignerForChainID(big.NewInt(1)), &tx)
+	if err != nil || from != sponsor {
+		t.Fatalf("sender = %s, %v", from.Hex(), err)
+	}
+	want, _ := userop.EncodeHandleOpsV07(ops, sponsor)
+	if tx.Type() != types.DynamicFeeTxType || *tx.To() != userop.EntryPointV07Address || !bytes.Equal(tx.Data(), want) || tx.Hash() != sent.Hash {
+		t.Fatalf("sent transaction type %d to %s", tx.Type(), tx.To().Hex())
+	}
+}
+
+func TestSelfBundlerSetCodeTransaction(t *testing.T) {
+	node := &fakeNode{}
+	b, key := newSelfBundler(t, node, userop.EntryPointV08Address)
+	op, auth := makeEIP7702UserOp(t)
+	beneficiary := common.HexToAddress("0x000000000000000000000000000000000000bEEF")
+	nonce := uint64(9)
+
+	sent, err := b.HandleOpsV07(context.Background(), []userop.UserOperationV07{op}, userop.SubmitOptions{
+		Beneficiary:    beneficiary,
+		Authorizations: []eip7702.Authorization{auth},
+		Nonce:          &nonce,
+	})
+	if err != nil {
+		t.Fatalf("HandleOpsV07() error = %v", err)
+	}
+	if _, ok := node.estimate["authorizationList"]; !ok {
+		t.Fatalf("estimate call = %v, want authorizationList", node.estimate)
+	}
+
+	calldata, _ := userop.EncodeHandleOpsV07([]userop.UserOperationV07{op}, beneficiary)
+	want := &eip7702.SetCodeTx{
+		ChainID:              big.NewInt(1),
+		Nonce:                9,
+		MaxPriorityFeePerGas: big.NewInt(1_000_000_000),
+		MaxFeePerGas:         big.NewInt(21_000_000_000),
+		GasLimit:             120_000,
+		Destination:          userop.EntryPointV08Address,
+		Value:                big.NewInt(0),
+		Data:                 calldata,
+		AuthorizationList:    []eip7702.Authorization{auth},
+	}
+	if err := want.Sign(key); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := want.EncodeTypedTransaction()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(node.raw, raw) || !bytes.Equal(sent.Raw, raw) {
+		t.Fatalf("sent transaction = %x, want %x", node.raw, raw)
+	}
+	if hash, _ := want.Hash(); sent.Hash != hash {
+		t.Fatalf("hash = %s, want %s", sent.Hash.Hex(), hash.Hex())
+	}
+	if from, err := want.Sender(); err != nil || from != b.Address() {
+		t.Fatalf("sender = %s, %v", from.Hex(), err)
+	}
+}
+
+func TestSelfBundlerReportsFailedOp(t *testing.T) {
+	revert := append(crypto.Keccak256([]byte("FailedOp(uint256,string)"))[:4],
+		abiEncode(t, []string{"uint256", "string"}, big.NewInt(0), "AA21 didn't pay prefund")...)
+	node := &fakeNode{estimateErr: map[string]any{"code": 3, "message": "execution reverted", "data": hexutil.Encode(revert)}}
+	b, _ := newSelfBundler(t, node, userop.EntryPointV06Address)
+
+	_, err := b.HandleOps(context.Background(), []userop.UserOperation{makeUserOp()}, userop.SubmitOptions{})
+	var failed *userop.FailedOp
+	if !errors.As(err, &failed) || failed.Code() != "AA21" {
+		t.Fatalf("error = %v, want FailedOp AA21", err)
+	}
+	if node.raw != nil {
+		t.Fatal("transaction must not be sent after a failed estimate")
+	}
+
+	if _, err := b.HandleOpsV07(context.Background(), []userop.UserOperationV07{makeUserOpV07()}, userop.SubmitOptions{}); !errors.Is(err, userop.ErrEntryPointVersion) {
+		t.Fatalf("shape mismatch error = %v", err)
+	}
+}
@@ -0,0 +1,175 @@
+package userop
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// SignatureScheme selects what the account's key signs for a userOp hash.
+type SignatureScheme int
+
+const (
+	// SignatureRawHash signs the userOp hash directly.
+	SignatureRawHash SignatureScheme = iota
+	// SignaturePersonal signs the EIP-191 personal message of the userOp hash.
+	SignaturePersonal
+)
+
+// ErrSignerMismatch is returned when a recovered signer is not the operation sender.
+var ErrSignerMismatch = errors.New("user operation signer does not match sender")
+
+// UserOpHashForEntryPoint hashes a v0.7-shaped operation with the rules of a
+// canonical v0.7 or v0.8 EntryPoint. Operations carrying eip7702Auth use v0.8 rules.
+// Other addresses return ErrEntryPointVersion; use UserOpHashForVersion for them.
+func UserOpHashForEntryPoint(op UserOperationV07, entryPoint common.Address, chainID *big.Int) (common.Hash, error) {
+	version, ok := EntryPointVersionOf(entryPoint)
+	if op.EIP7702Auth != nil {
+		version, ok = EntryPointV08, true
+	}
+	if !ok {
+		return common.Hash{}, fmt.Errorf("%w: %s is not a canonical entry point", ErrEntryPointVersion, entryPoint.Hex())
+	}
+	return UserOpHashForVersion(op, version, entryPoint, chainID)
+}
+
+// UserOpHashForVersion hashes a v0.7-shaped operation for an EntryPoint deployment
+// of the given version, which must be v0.7 or v0.8.
+func UserOpHashForVersion(op UserOperationV07, version EntryPointVersion, entryPoint common.Address, chainID *big.Int) (common.Hash, error) {
+	switch version {
+	case EntryPointV07:
+		return UserOpHashV07(op, entryPoint, chainID)
+	case EntryPointV08:
+		return UserOpHashV08(op, entryPoint, chainID)
+	default:
+		return common.Hash{}, fmt.Errorf("%w: cannot hash a packed operation for %s", ErrEntryPointVersion, version)
+	}
+}
+
+// SignUserOperation fills op.Signature with the sender key's signature over the v0.6 userOp hash.
+// For a 7702-delegated EOA the signer is the EOA key itself.
+func SignUserOperation(op *UserOperation, signer *ecdsa.PrivateKey, entryPoint common.Address, chainID *big.Int, scheme SignatureScheme) error {
+	if op == nil {
+		return errors.New("user operation is required")
+	}
+	hash, err := UserOpHash(*op, entryPoint, chainID)
+	if err != nil {
+		return err
+	}
+	sig, err := signUserOpHash(hash, signer, scheme)
+	if err != nil {
+		return err
+	}
+	op.Signature = sig
+	return nil
+}
+
+// SignUserOperationV07 fills op.Signature for a v0.7 or v0.8 EntryPoint. See UserOpHashForEntryPoint.
+func SignUserOperationV07(op *UserOperationV07, signer *ecdsa.PrivateKey, entryPoint common.Address, chainID *big.Int, scheme SignatureScheme) error {
+	if op == nil {
+		return errors.New("user operation is required")
+	}
+	hash, err := UserOpHashForEntryPoint(*op, entryPoint, chainID)
+	if err != nil {
+		return err
+	}
+	return signV07(op, hash, signer, scheme)
+}
+
+// SignUserOperationV07ForVersion fills op.Signature for a non-canonical EntryPoint
+// deployment of the given version.
+func SignUserOperationV07ForVersion(op *UserOperationV07, signer *ecdsa.PrivateKey, version EntryPointVersion, entryPoint common.Address, chainID *big.Int, scheme SignatureScheme) error {
+	if op == nil {
+		return errors.New("user operation is required")
+	}
+	hash, err := UserOpHashForVersion(*op, version, entryPoint, chainID)
+	if err != nil {
+		return err
+	}
+	return signV07(op, hash, signer, scheme)
+}
+
+func signV07(op *UserOperationV07, hash common.Hash, signer *ecdsa.PrivateKey, scheme SignatureScheme) error {
+	sig, err := signUserOpHash(hash, signer, scheme)
+	if err != nil {
+		return err
+	}
+	op.Signature = sig
+	return nil
+}
+
+// VerifyUserOperationSignature recovers the v0.6 signer and checks it equals op.Sender.
+func VerifyUserOperationSignature(op UserOperation, entryPoint common.Address, chainID *big.Int, scheme SignatureScheme) (common.Address, error) {
+	hash, err := UserOpHash(op, entryPoint, chainID)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return verifyUserOpSignature(hash, op.Signature, op.Sender, scheme)
+}
+
+// VerifyUserOperationV07Signature recovers the v0.7/v0.8 signer and checks it equals op.Sender.
+func VerifyUserOperationV07Signature(op UserOperationV07, entryPoint common.Address, chainID *big.Int, scheme SignatureScheme) (common.Address, error) {
+	hash, err := UserOpHashForEntryPoint(op, entryPoint, chainID)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return verifyUserOpSignature(hash, op.Signature, op.Sender, scheme)
+}
+
+// signedDigest returns the digest the key actually signs under scheme.
+func signedDigest(hash common.Hash, scheme SignatureScheme) ([]byte, error) {
+	switch scheme {
+	case SignatureRawHash:
+		return hash[:], nil
+	case SignaturePersonal:
+		return accounts.TextHash(hash[:]), nil
+	default:
+		return nil, fmt.Errorf("unknown signature scheme %d", scheme)
+	}
+}
+
+// signUserOpHash returns r || s || v with v in {27, 28}, as ECDSA.recover expects.
+func signUserOpHash(hash common.Hash, signer *ecdsa.PrivateKey, scheme SignatureScheme) ([]byte, error) {
+	if signer == nil {
+		return nil, errors.New("private key is required")
+	}
+	digest, err := signedDigest(hash, scheme)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := crypto.Sign(digest, signer)
+	if err != nil {
+		return nil, fmt.Errorf("sign user operation: %w", err)
+	}
+	sig[64] += 27
+	return sig, nil
+}
+
+func verifyUserOpSignature(hash common.Hash, signature []byte, sender common.Address, scheme SignatureScheme) (common.Address, error) {
+	if len(signature) != crypto.SignatureLength {
+		return common.Address{}, fmt.Errorf("signature must be %d bytes", crypto.SignatureLength)
+	}
+	digest, err := signedDigest(hash, scheme)
+	if err != nil {
+		return common.Address{}, err
+	}
+	sig := make([]byte, crypto.SignatureLength)
+	copy(sig, signature)
+	if sig[64] >= 27 {
+		sig[64] -= 27
+	}
+	pub, err := crypto.SigToPub(digest, sig)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("recover signer: %w", err)
+	}
+	signer := crypto.PubkeyToAddress(*pub)
+	if signer != sender {
+		return signer, fmt.Errorf("%w: recovered %s, sender %s", ErrSignerMismatch, signer.Hex(), sender.Hex())
+	}
+	return signer, nil
+}
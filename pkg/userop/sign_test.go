@@ -0,0 +1,106 @@
+package userop_test
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/eipcodelab/eip7702-go/pkg/userop"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestSignAndVerifyUserOperation(t *testing.T) {
+	key, err := crypto.HexToECDSA("4f3edf983ac63f7f8b7d0c4f76f2a5a70fadb53fcbf65f45d6fd5d77f07683ab")
+	if err != nil {
+		t.Fatalf("key: %v", err)
+	}
+	chainID := big.NewInt(1)
+
+	for _, scheme := range []userop.SignatureScheme{userop.SignatureRawHash, userop.SignaturePersonal} {
+		op := makeUserOp()
+		op.Sender = crypto.PubkeyToAddress(key.PublicKey)
+		if err := userop.SignUserOperation(&op, key, userop.EntryPointV06Address, chainID, scheme); err != nil {
+			t.Fatalf("sign: %v", err)
+		}
+		if len(op.Signature) != 65 || (op.Signature[64] != 27 && op.Signature[64] != 28) {
+			t.Fatalf("unexpected signature: %x", op.Signature)
+		}
+		signer, err := userop.VerifyUserOperationSignature(op, userop.EntryPointV06Address, chainID, scheme)
+		if err != nil {
+			t.Fatalf("verify: %v", err)
+		}
+		if signer != op.Sender {
+			t.Fatalf("unexpected signer: %s", signer.Hex())
+		}
+		other := userop.SignaturePersonal
+		if scheme == userop.SignaturePersonal {
+			other = userop.SignatureRawHash
+		}
+		if _, err := userop.VerifyUserOperationSignature(op, userop.EntryPointV06Address, chainID, other); !errors.Is(err, userop.ErrSignerMismatch) {
+			t.Fatalf("expected mismatch under the other scheme, got %v", err)
+		}
+	}
+}
+
+func TestSignUserOperationV07UsesEntryPointVersion(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("key: %v", err)
+	}
+	chainID := big.NewInt(1)
+	op := makeUserOpV07()
+	op.Sender = crypto.PubkeyToAddress(key.PublicKey)
+
+	if err := userop.SignUserOperationV07(&op, key, userop.EntryPointV08Address, chainID, userop.SignaturePersonal); err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	hash, err := userop.UserOpHashV08(op, userop.EntryPointV08Address, chainID)
+	if err != nil {
+		t.Fatalf("hash: %v", err)
+	}
+	sig := append([]byte{}, op.Signature...)
+	sig[64] -= 27
+	pub, err := crypto.SigToPub(accounts.TextHash(hash[:]), sig)
+	if err != nil || crypto.PubkeyToAddress(*pub) != op.Sender {
+		t.Fatalf("signature is not over the v0.8 hash: %v", err)
+	}
+	if _, err := userop.VerifyUserOperationV07Signature(op, userop.EntryPointV08Address, chainID, userop.SignaturePersonal); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if _, err := userop.VerifyUserOperationV07Signature(op, userop.EntryPointV07Address, chainID, userop.SignaturePersonal); !errors.Is(err, userop.ErrSignerMismatch) {
+		t.Fatalf("expected mismatch against the v0.7 entry point, got %v", err)
+	}
+}
+
+func TestSignUserOperationV07CustomEntryPoint(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("key: %v", err)
+	}
+	chainID := big.NewInt(1)
+	entryPoint := common.HexToAddress("0x0000000000000000000000000000000000001234")
+	op := makeUserOpV07()
+	op.Sender = crypto.PubkeyToAddress(key.PublicKey)
+
+	if err := userop.SignUserOperationV07(&op, key, entryPoint, chainID, userop.SignatureRawHash); !errors.Is(err, userop.ErrEntryPointVersion) {
+		t.Fatalf("non-canonical entry point error = %v, want ErrEntryPointVersion", err)
+	}
+	if err := userop.SignUserOperationV07ForVersion(&op, key, userop.EntryPointV08, entryPoint, chainID, userop.SignatureRawHash); err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	hash, err := userop.UserOpHashV08(op, entryPoint, chainID)
+	if err != nil {
+		t.Fatalf("hash: %v", err)
+	}
+	sig := append([]byte{}, op.Signature...)
+	sig[64] -= 27
+	pub, err := crypto.SigToPub(hash[:], sig)
+	if err != nil || crypto.PubkeyToAddress(*pub) != op.Sender {
+		t.Fatalf("signature is not over the v0.8 hash: %v", err)
+	}
+	if _, err := userop.UserOpHashForVersion(op, userop.EntryPointV06, entryPoint, chainID); !errors.Is(err, userop.ErrEntryPointVersion) {
+		t.Fatalf("v0.6 version error = %v, want ErrEntryPointVersion", err)
+	}
+}
@@ -0,0 +1,275 @@
+package userop
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// stakeManagerABIJSON is the StakeManager surface shared by EntryPoint v0.6, v0.7 and v0.8.
+// v0.6 declares the deposit as uint112; it encodes to the same word as v0.7's uint256,
+// and the static DepositInfo struct encodes like its flattened fields.
+const stakeManagerABIJSON = `[
+  {"type": "function", "name": "balanceOf", "stateMutability": "view",
+   "inputs": [{"name": "account", "type": "address"}],
+   "outputs": [{"name": "", "type": "uint256"}]},
+  {"type": "function", "name": "getDepositInfo", "stateMutability": "view",
+   "inputs": [{"name": "account", "type": "address"}],
+   "outputs": [
+     {"name": "deposit", "type": "uint256"},
+     {"name": "staked", "type": "bool"},
+     {"name": "stake", "type": "uint112"},
+     {"name": "unstakeDelaySec", "type": "uint32"},
+     {"name": "withdrawTime", "type": "uint48"}
+   ]},
+  {"type": "function", "name": "depositTo", "stateMutability": "payable", "outputs": [],
+   "inputs": [{"name": "account", "type": "address"}]},
+  {"type": "function", "name": "withdrawTo", "stateMutability": "nonpayable", "outputs": [],
+   "inputs": [{"name": "withdrawAddress", "type": "address"}, {"name": "withdrawAmount", "type": "uint256"}]},
+  {"type": "function", "name": "addStake", "stateMutability": "payable", "outputs": [],
+   "inputs": [{"name": "unstakeDelaySec", "type": "uint32"}]},
+  {"type": "function", "name": "unlockStake", "stateMutability": "nonpayable", "outputs": [], "inputs": []},
+  {"type": "function", "name": "withdrawStake", "stateMutability": "nonpayable", "outputs": [],
+   "inputs": [{"name": "withdrawAddress", "type": "address"}]}
+]`
+
+var stakeManagerABI = mustParseABI(stakeManagerABIJSON)
+
+// DepositInfo is an account's EntryPoint deposit and stake.
+type DepositInfo struct {
+	Deposit         *big.Int
+	Staked          bool
+	Stake           *big.Int
+	UnstakeDelaySec uint32
+	// WithdrawTime is the unix time the stake can be withdrawn after unlockStake, or 0 while locked.
+	WithdrawTime uint64
+}
+
+// EncodeBalanceOf returns calldata for EntryPoint.balanceOf(account).
+func EncodeBalanceOf(account common.Address) ([]byte, error) {
+	return packStakeCall("balanceOf", account)
+}
+
+// EncodeGetDepositInfo returns calldata for EntryPoint.getDepositInfo(account).
+func EncodeGetDepositInfo(account common.Address) ([]byte, error) {
+	return packStakeCall("getDepositInfo", account)
+}
+
+// EncodeDepositTo returns calldata for EntryPoint.depositTo(account). The deposit is the call value.
+func EncodeDepositTo(account common.Address) ([]byte, error) {
+	return packStakeCall("depositTo", account)
+}
+
+// EncodeWithdrawTo returns calldata for EntryPoint.withdrawTo(to, amount), sent by the depositor.
+func EncodeWithdrawTo(to common.Address, amount *big.Int) ([]byte, error) {
+	if amount == nil || amount.Sign() < 0 {
+		return nil, errors.New("withdraw amount must be non-negative")
+	}
+	return packStakeCall("withdrawTo", to, amount)
+}
+
+// EncodeAddStake returns calldata for EntryPoint.addStake(unstakeDelaySec). The stake is the call value.
+func EncodeAddStake(unstakeDelaySec uint32) ([]byte, error) {
+	if unstakeDelaySec == 0 {
+		return nil, errors.New("unstake delay must be positive")
+	}
+	return packStakeCall("addStake", unstakeDelaySec)
+}
+
+// EncodeUnlockStake returns calldata for EntryPoint.unlockStake().
+func EncodeUnlockStake() ([]byte, error) {
+	return packStakeCall("unlockStake")
+}
+
+// EncodeWithdrawStake returns calldata for EntryPoint.withdrawStake(to), valid once WithdrawTime has passed.
+func EncodeWithdrawStake(to common.Address) ([]byte, error) {
+	return packStakeCall("withdrawStake", to)
+}
+
+func packStakeCall(method string, args ...any) ([]byte, error) {
+	calldata, err := stakeManagerABI.Pack(method, args...)
+	if err != nil {
+		return nil, fmt.Errorf("encode %s: %w", method, err)
+	}
+	return calldata, nil
+}
+
+// DecodeBalanceOf decodes the eth_call result of balanceOf.
+func DecodeBalanceOf(data []byte) (*big.Int, error) {
+	values, err := stakeManagerABI.Unpack("balanceOf", data)
+	if err != nil {
+		return nil, fmt.Errorf("decode balanceOf: %w", err)
+	}
+	return values[0].(*big.Int), nil
+}
+
+// DecodeDepositInfo decodes the eth_call result of getDepositInfo from any EntryPoint version.
+func DecodeDepositInfo(data []byte) (*DepositInfo, error) {
+	values, err := stakeManagerABI.Unpack("getDepositInfo", data)
+	if err != nil {
+		return nil, fmt.Errorf("decode getDepositInfo: %w", err)
+	}
+	return &DepositInfo{
+		Deposit:         values[0].(*big.Int),
+		Staked:          values[1].(bool),
+		Stake:           values[2].(*big.Int),
+		UnstakeDelaySec: values[3].(uint32),
+		WithdrawTime:    values[4].(*big.Int).Uint64(),
+	}, nil
+}
+
+// BalanceOf reads an account's EntryPoint deposit.
+func BalanceOf(ctx context.Context, caller ContractCaller, entryPoint, account common.Address) (*big.Int, error) {
+	out, err := callStakeManager(ctx, caller, entryPoint, "balanceOf", account)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeBalanceOf(out)
+}
+
+// GetDepositInfo reads an account's EntryPoint deposit and stake.
+func GetDepositInfo(ctx context.Context, caller ContractCaller, entryPoint, account common.Address) (*DepositInfo, error) {
+	out, err := callStakeManager(ctx, caller, entryPoint, "getDepositInfo", account)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeDepositInfo(out)
+}
+
+func callStakeManager(ctx context.Context, caller ContractCaller, entryPoint common.Address, method string, account common.Address) ([]byte, error) {
+	data, err := packStakeCall(method, account)
+	if err != nil {
+		return nil, err
+	}
+	out, err := caller.CallContract(ctx, ethereum.CallMsg{To: &entryPoint, Data: data}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", method, err)
+	}
+	return out, nil
+}
+
+const defaultDepositInterval = time.Minute
+
+// DepositAlert reports an account whose deposit is below its threshold.
+type DepositAlert struct {
+	Account   common.Address
+	Deposit   *big.Int
+	Threshold *big.Int
+	// Shortfall is the top-up that brings the deposit back to the threshold.
+	Shortfall *big.Int
+}
+
+// DepositMonitorOptions configures a DepositMonitor.
+type DepositMonitorOptions struct {
+	// Thresholds maps every watched account to its minimum deposit.
+	Thresholds map[common.Address]*big.Int
+	// Interval is the delay between checks in Run. Default 1m.
+	Interval time.Duration
+	// OnLow is called when an account falls below its threshold. It fires once per
+	// drop and again only after the deposit has recovered and fallen again.
+	OnLow func(DepositAlert)
+	// OnError receives read failures in Run. Optional.
+	OnError func(error)
+}
+
+// DepositMonitor watches EntryPoint deposits of paymasters and accounts.
+// It is safe for concurrent use by multiple goroutines.
+type DepositMonitor struct {
+	caller     ContractCaller
+	entryPoint common.Address
+	opts       DepositMonitorOptions
+	accounts   []common.Address
+
+	mu  sync.Mutex
+	low map[common.Address]bool
+}
+
+// NewDepositMonitor creates a monitor for the accounts in opts.Thresholds.
+func NewDepositMonitor(caller ContractCaller, entryPoint common.Address, opts DepositMonitorOptions) (*DepositMonitor, error) {
+	if caller == nil {
+		return nil, errors.New("contract caller is required")
+	}
+	if len(opts.Thresholds) == 0 {
+		return nil, errors.New("at least one threshold is required")
+	}
+	thresholds := make(map[common.Address]*big.Int, len(opts.Thresholds))
+	accounts := make([]common.Address, 0, len(opts.Thresholds))
+	for account, threshold := range opts.Thresholds {
+		if threshold == nil || threshold.Sign() < 0 {
+			return nil, fmt.Errorf("threshold for %s must be non-negative", account.Hex())
+		}
+		thresholds[account] = new(big.Int).Set(threshold)
+		accounts = append(accounts, account)
+	}
+	slices.SortFunc(accounts, func(a, b common.Address) int { return a.Cmp(b) })
+	opts.Thresholds = thresholds
+	if opts.Interval <= 0 {
+		opts.Interval = defaultDepositInterval
+	}
+	return &DepositMonitor{
+		caller:     caller,
+		entryPoint: entryPoint,
+		opts:       opts,
+		accounts:   accounts,
+		low:        make(map[common.Address]bool),
+	}, nil
+}
+
+// Check reads every watched deposit once and returns the accounts below their
+// threshold, ordered by address. Accounts that could not be read are skipped and
+// their errors joined into the returned error.
+func (m *DepositMonitor) Check(ctx context.Context) ([]DepositAlert, error) {
+	var alerts, fired []DepositAlert
+	var errs []error
+	m.mu.Lock()
+	for _, account := range m.accounts {
+		deposit, err := BalanceOf(ctx, m.caller, m.entryPoint, account)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", account.Hex(), err))
+			continue
+		}
+		threshold := m.opts.Thresholds[account]
+		if deposit.Cmp(threshold) >= 0 {
+			delete(m.low, account)
+			continue
+		}
+		alert := DepositAlert{
+			Account:   account,
+			Deposit:   deposit,
+			Threshold: new(big.Int).Set(threshold),
+			Shortfall: new(big.Int).Sub(threshold, deposit),
+		}
+		alerts = append(alerts, alert)
+		if !m.low[account] {
+			m.low[account] = true
+			fired = append(fired, alert)
+		}
+	}
+	m.mu.Unlock()
+
+	if m.opts.OnLow != nil {
+		for _, alert := range fired {
+			m.opts.OnLow(alert)
+		}
+	}
+	return alerts, errors.Join(errs...)
+}
+
+// Run checks deposits every Interval until ctx is done and returns ctx's error.
+func (m *DepositMonitor) Run(ctx context.Context) error {
+	for {
+		if _, err := m.Check(ctx); err != nil && ctx.Err() == nil && m.opts.OnError != nil {
+			m.opts.OnError(err)
+		}
+		if err := sleepContext(ctx, m.opts.Interval); err != nil {
+			return err
+		}
+	}
+}
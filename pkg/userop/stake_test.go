@@ -0,0 +1,219 @@
+package userop_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eipcodelab/eip7702-go/pkg/userop"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// fakeStakeCaller answers balanceOf from a settable deposit table.
+type fakeStakeCaller struct {
+	mu       sync.Mutex
+	deposits map[common.Address]*big.Int
+	failing  map[common.Address]bool
+}
+
+func (f *fakeStakeCaller) CallContract(_ context.Context, call ethereum.CallMsg, _ *big.Int) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !bytes.Equal(call.Data[:4], selector("balanceOf(address)")) {
+		return nil, errors.New("unexpected call")
+	}
+	account := common.BytesToAddress(call.Data[4:36])
+	if f.failing[account] {
+		return nil, errors.New("node unavailable")
+	}
+	deposit := f.deposits[account]
+	if deposit == nil {
+		deposit = new(big.Int)
+	}
+	return common.LeftPadBytes(deposit.Bytes(), 32), nil
+}
+
+func (f *fakeStakeCaller) set(account common.Address, deposit int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.deposits[account] = big.NewInt(deposit)
+}
+
+func selector(signature string) []byte {
+	return crypto.Keccak256([]byte(signature))[:4]
+}
+
+func TestStakeManagerCalldata(t *testing.T) {
+	account := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	word := common.LeftPadBytes(account.Bytes(), 32)
+	amount := big.NewInt(1_000)
+
+	tests := []struct {
+		name      string
+		encode    func() ([]byte, error)
+		signature string
+		args      []byte
+	}{
+		{"balanceOf", func() ([]byte, error) { return userop.EncodeBalanceOf(account) }, "balanceOf(address)", word},
+		{"getDepositInfo", func() ([]byte, error) { return userop.EncodeGetDepositInfo(account) }, "getDepositInfo(address)", word},
+		{"depositTo", func() ([]byte, error) { return userop.EncodeDepositTo(account) }, "depositTo(address)", word},
+		{"withdrawTo", func() ([]byte, error) { return userop.EncodeWithdrawTo(account, amount) }, "withdrawTo(address,uint256)",
+			append(append([]byte{}, word...), common.LeftPadBytes(amount.Bytes(), 32)...)},
+		{"addStake", func() ([]byte, error) { return userop.EncodeAddStake(86_400) }, "addStake(uint32)",
+			common.LeftPadBytes(big.NewInt(86_400).Bytes(), 32)},
+		{"unlockStake", userop.EncodeUnlockStake, "unlockStake()", nil},
+		{"withdrawStake", func() ([]byte, error) { return userop.EncodeWithdrawStake(account) }, "withdrawStake(address)", word},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.encode()
+			if err != nil {
+				t.Fatalf("encode error = %v", err)
+			}
+			want := append(selector(tt.signature), tt.args...)
+			if !bytes.Equal(got, want) {
+				t.Fatalf("calldata = %x, want %x", got, want)
+			}
+		})
+	}
+
+	if _, err := userop.EncodeWithdrawTo(account, nil); err == nil {
+		t.Fatal("expected error for nil amount")
+	}
+	if _, err := userop.EncodeAddStake(0); err == nil {
+		t.Fatal("expected error for zero unstake delay")
+	}
+}
+
+func TestDecodeDepositInfo(t *testing.T) {
+	// v0.6 returns (uint112 deposit, ...) and v0.7 (uint256 deposit, ...); both decode alike.
+	for _, depositType := range []string{"uint112", "uint256"} {
+		data := abiEncode(t, []string{depositType, "bool", "uint112", "uint32", "uint48"},
+			big.NewInt(5_000), true, big.NewInt(1_000), uint32(86_400), big.NewInt(1_700_000_000))
+		info, err := userop.DecodeDepositInfo(data)
+		if err != nil {
+			t.Fatalf("%s: DecodeDepositInfo() error = %v", depositType, err)
+		}
+		if info.Deposit.Int64() != 5_000 || !info.Staked || info.Stake.Int64() != 1_000 ||
+			info.UnstakeDelaySec != 86_400 || info.WithdrawTime != 1_700_000_000 {
+			t.Fatalf("%s: info = %+v", depositType, info)
+		}
+	}
+	if _, err := userop.DecodeDepositInfo(make([]byte, 32)); err == nil {
+		t.Fatal("expected error for short data")
+	}
+}
+
+func TestGetDepositInfoOverRPC(t *testing.T) {
+	account := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	info := abiEncode(t, []string{"uint112", "bool", "uint112", "uint32", "uint48"},
+		big.NewInt(7), false, big.NewInt(0), uint32(0), big.NewInt(0))
+	srv := newRPCServer(t, func(call rpcCall) (any, map[string]any) {
+		var arg struct {
+			To   common.Address `json:"to"`
+			Data hexutil.Bytes  `json:"data"`
+		}
+		if err := json.Unmarshal(call.Params[0], &arg); err != nil || arg.To != userop.EntryPointV06Address || len(arg.Data) < 4 {
+			return nil, map[string]any{"code": -32602, "message": "bad call"}
+		}
+		if bytes.Equal(arg.Data[:4], selector("balanceOf(address)")) {
+			return hexutil.Bytes(common.LeftPadBytes(big.NewInt(7).Bytes(), 32)), nil
+		}
+		return hexutil.Bytes(info), nil
+	})
+	client := userop.NewBundlerClient(srv.URL)
+
+	got, err := userop.GetDepositInfo(context.Background(), client, userop.EntryPointV06Address, account)
+	if err != nil || got.Deposit.Int64() != 7 || got.Staked {
+		t.Fatalf("GetDepositInfo() = %+v, %v", got, err)
+	}
+	balance, err := userop.BalanceOf(context.Background(), client, userop.EntryPointV06Address, account)
+	if err != nil || balance.Int64() != 7 {
+		t.Fatalf("BalanceOf() = %v, %v", balance, err)
+	}
+}
+
+func TestDepositMonitor(t *testing.T) {
+	paymaster := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	account := common.HexToAddress("0x00000000000000000000000000000000000000bb")
+	caller := &fakeStakeCaller{deposits: map[common.Address]*big.Int{}, failing: map[common.Address]bool{}}
+	caller.set(paymaster, 50)
+	caller.set(account, 500)
+
+	var fired []userop.DepositAlert
+	m, err := userop.NewDepositMonitor(caller, userop.EntryPointV07Address, userop.DepositMonitorOptions{
+		Thresholds: map[common.Address]*big.Int{paymaster: big.NewInt(100), account: big.NewInt(100)},
+		OnLow:      func(a userop.DepositAlert) { fired = append(fired, a) },
+	})
+	if err != nil {
+		t.Fatalf("NewDepositMonitor() error = %v", err)
+	}
+
+	alerts, err := m.Check(context.Background())
+	if err != nil || len(alerts) != 1 || alerts[0].Account != paymaster || alerts[0].Shortfall.Int64() != 50 {
+		t.Fatalf("Check() = %+v, %v", alerts, err)
+	}
+	// Still low: reported by Check, but OnLow fires once per drop.
+	if alerts, _ := m.Check(context.Background()); len(alerts) != 1 || len(fired) != 1 {
+		t.Fatalf("second check alerts = %d, fired = %d", len(alerts), len(fired))
+	}
+	caller.set(paymaster, 100)
+	if alerts, _ := m.Check(context.Background()); len(alerts) != 0 {
+		t.Fatalf("topped-up alerts = %+v", alerts)
+	}
+	caller.set(paymaster, 10)
+	m.Check(context.Background())
+	if len(fired) != 2 || fired[1].Deposit.Int64() != 10 {
+		t.Fatalf("fired = %+v", fired)
+	}
+
+	caller.mu.Lock()
+	caller.failing[account] = true
+	caller.mu.Unlock()
+	alerts, err = m.Check(context.Background())
+	if err == nil || len(alerts) != 1 {
+		t.Fatalf("Check() with a failing read = %+v, %v", alerts, err)
+	}
+
+	if _, err := userop.NewDepositMonitor(caller, userop.EntryPointV07Address, userop.DepositMonitorOptions{}); err == nil {
+		t.Fatal("expected error without thresholds")
+	}
+}
+
+func TestDepositMonitorRun(t *testing.T) {
+	paymaster := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	caller := &fakeStakeCaller{deposits: map[common.Address]*big.Int{}, failing: map[common.Address]bool{}}
+	low := make(chan userop.DepositAlert, 1)
+	m, err := userop.NewDepositMonitor(caller, userop.EntryPointV07Address, userop.DepositMonitorOptions{
+		Thresholds: map[common.Address]*big.Int{paymaster: big.NewInt(1)},
+		Interval:   time.Millisecond,
+		OnLow:      func(a userop.DepositAlert) { low <- a },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- m.Run(ctx) }()
+
+	select {
+	case alert := <-low:
+		if alert.Account != paymaster || alert.Deposit.Sign() != 0 {
+			t.Fatalf("alert = %+v", alert)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no alert from Run")
+	}
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("Run() = %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,356 @@
+package userop
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+
+	"github.com/eipcodelab/eip7702-go/pkg/eip7702"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Severity ranks a validation finding.
+type Severity int
+
+const (
+	// SeverityInfo is a note that does not affect inclusion.
+	SeverityInfo Severity = iota
+	// SeverityWarning flags values that are legal but likely to fail or overpay.
+	SeverityWarning
+	// SeverityError marks operations a bundler or the EntryPoint will reject.
+	SeverityError
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	default:
+		return fmt.Sprintf("severity(%d)", int(s))
+	}
+}
+
+var (
+	// maxGasValue is the EntryPoint bound on every gas and fee field ("AA94 gas values overflow").
+	maxGasValue     = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 120), big.NewInt(1))
+	maxUint256      = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+	secp256k1HalfN  = new(big.Int).Rsh(crypto.S256().Params().N, 1)
+	maxGasLimitsSum = new(big.Int).SetUint64(math.MaxUint64)
+)
+
+// Finding is one validation result. Field is the JSON name of the offending field.
+type Finding struct {
+	Severity Severity
+	Field    string
+	Message  string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s: %s: %s", f.Severity, f.Field, f.Message)
+}
+
+// Findings is the result of Validate, in check order.
+type Findings []Finding
+
+// HasErrors reports whether any finding has SeverityError.
+func (fs Findings) HasErrors() bool {
+	for _, f := range fs {
+		if f.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// Err returns a *ValidationError holding the error findings, or nil if there are none.
+func (fs Findings) Err() error {
+	var errs Findings
+	for _, f := range fs {
+		if f.Severity == SeverityError {
+			errs = append(errs, f)
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return &ValidationError{Findings: errs}
+}
+
+// ValidationError is returned for operations with error findings. It matches
+// ErrInvalidParams, the code bundlers use for the same rejections.
+type ValidationError struct {
+	Findings Findings
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Findings))
+	for i, f := range e.Findings {
+		msgs[i] = f.Field + ": " + f.Message
+	}
+	return "invalid user operation: " + strings.Join(msgs, "; ")
+}
+
+// Is reports whether target is ErrInvalidParams.
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrInvalidParams
+}
+
+// ValidationOptions configures Validate. Zero values skip the optional checks.
+type ValidationOptions struct {
+	// EntryPoint enables version checks for canonical deployments.
+	EntryPoint common.Address
+	// ChainID enables eip7702Auth signature checks.
+	ChainID *big.Int
+	// SignatureLength is the expected signature length. Zero means 65 (ECDSA r || s || v),
+	// which also checks v and low-s; a negative value disables the check.
+	SignatureLength int
+	// MaxTotalGas bounds the sum of all gas limits, e.g. a bundler's max bundle gas.
+	MaxTotalGas uint64
+	// CheckDeployment compares initCode with SenderCode, the code currently at sender.
+	CheckDeployment bool
+	SenderCode      []byte
+}
+
+type validator struct {
+	opts     ValidationOptions
+	findings Findings
+}
+
+func (v *validator) add(severity Severity, field, format string, args ...any) {
+	v.findings = append(v.findings, Finding{Severity: severity, Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// Validate runs every local check a bundler or the v0.6 EntryPoint would apply
+// before simulation. Unlike ValidateBasic it reports all problems at once.
+func (u UserOperation) Validate(opts ValidationOptions) Findings {
+	v := &validator{opts: opts}
+	v.entryPoint(EntryPointV06)
+	v.common(u.Nonce.ToInt(), u.CallData, u.Signature, uint64(u.CallGasLimit), uint64(u.PreVerificationGas))
+	v.fees(u.MaxFeePerGas.ToInt(), u.MaxPriorityFeePerGas.ToInt())
+
+	verificationGas := uint64(u.VerificationGasLimit)
+	v.verificationGas("verificationGasLimit", verificationGas)
+	multiplier := uint64(1)
+	if len(u.PaymasterAndData) > 0 {
+		// The v0.6 EntryPoint reserves verification gas for the paymaster and its postOp.
+		multiplier = 3
+	}
+	v.gasSum(
+		new(big.Int).SetUint64(uint64(u.PreVerificationGas)),
+		new(big.Int).SetUint64(uint64(u.CallGasLimit)),
+		new(big.Int).Mul(new(big.Int).SetUint64(verificationGas), new(big.Int).SetUint64(multiplier)),
+	)
+
+	switch {
+	case len(u.InitCode) == 0:
+	case IsEIP7702InitCode(u.InitCode):
+		v.add(SeverityError, "initCode", "the v0.6 EntryPoint does not support EIP-7702 initCode")
+	case len(u.InitCode) < common.AddressLength:
+		v.add(SeverityError, "initCode", "must start with a 20-byte factory address, got %d bytes", len(u.InitCode))
+	case common.BytesToAddress(u.InitCode[:common.AddressLength]) == (common.Address{}):
+		v.add(SeverityError, "initCode", "factory address is zero")
+	}
+	switch {
+	case len(u.PaymasterAndData) == 0:
+	case len(u.PaymasterAndData) < common.AddressLength:
+		v.add(SeverityError, "paymasterAndData", "must be empty or at least %d bytes, got %d", common.AddressLength, len(u.PaymasterAndData))
+	case common.BytesToAddress(u.PaymasterAndData[:common.AddressLength]) == (common.Address{}):
+		v.add(SeverityError, "paymasterAndData", "paymaster address is zero")
+	}
+	v.deployment(len(u.InitCode) > 0, false)
+	return v.findings
+}
+
+// Validate runs every local check a bundler or the v0.7/v0.8 EntryPoint would
+// apply before simulation, including EIP-7702 consistency for v0.8.
+func (u UserOperationV07) Validate(opts ValidationOptions) Findings {
+	v := &validator{opts: opts}
+	if u.EIP7702Auth != nil {
+		v.entryPoint(EntryPointV08)
+	} else {
+		v.entryPoint(EntryPointV07, EntryPointV08)
+	}
+	v.common(u.Nonce.ToInt(), u.CallData, u.Signature, uint64(u.CallGasLimit), uint64(u.PreVerificationGas))
+	v.fees(u.MaxFeePerGas.ToInt(), u.MaxPriorityFeePerGas.ToInt())
+	v.verificationGas("verificationGasLimit", uint64(u.VerificationGasLimit))
+	v.gasSum(
+		new(big.Int).SetUint64(uint64(u.PreVerificationGas)),
+		new(big.Int).SetUint64(uint64(u.VerificationGasLimit)),
+		new(big.Int).SetUint64(uint64(u.CallGasLimit)),
+		new(big.Int).SetUint64(uint64(u.PaymasterVerificationGasLimit)),
+		new(big.Int).SetUint64(uint64(u.PaymasterPostOpGasLimit)),
+	)
+
+	if u.Factory == nil && len(u.FactoryData) > 0 {
+		v.add(SeverityError, "factoryData", "set without factory")
+	}
+	if u.Factory != nil && *u.Factory == (common.Address{}) {
+		v.add(SeverityError, "factory", "factory address is zero")
+	}
+	if u.Paymaster == nil {
+		if len(u.PaymasterData) > 0 || u.PaymasterVerificationGasLimit != 0 || u.PaymasterPostOpGasLimit != 0 {
+			v.add(SeverityError, "paymaster", "paymaster fields are set without paymaster")
+		}
+	} else {
+		if *u.Paymaster == (common.Address{}) {
+			v.add(SeverityError, "paymaster", "paymaster address is zero")
+		}
+		v.verificationGas("paymasterVerificationGasLimit", uint64(u.PaymasterVerificationGasLimit))
+	}
+
+	eip7702Op := u.Factory != nil && *u.Factory == EIP7702FactoryMarker
+	if eip7702Op {
+		v.eip7702(u)
+	} else if u.EIP7702Auth != nil {
+		v.add(SeverityError, "eip7702Auth", "requires the 0x7702 factory marker")
+	}
+	v.deployment(u.Factory != nil, eip7702Op)
+	return v.findings
+}
+
+func (v *validator) entryPoint(allowed ...EntryPointVersion) {
+	if v.opts.EntryPoint == (common.Address{}) {
+		return
+	}
+	if err := checkEntryPoint(v.opts.EntryPoint, allowed...); err != nil {
+		v.add(SeverityError, "entryPoint", "%v", err)
+	}
+}
+
+func (v *validator) common(nonce *big.Int, callData, signature []byte, callGas, preVerificationGas uint64) {
+	if nonce == nil {
+		v.add(SeverityError, "nonce", "is required")
+	} else if nonce.Sign() < 0 || nonce.Cmp(maxUint256) > 0 {
+		v.add(SeverityError, "nonce", "does not fit in uint256")
+	}
+	if len(callData) == 0 {
+		v.add(SeverityError, "callData", "must not be empty")
+	} else if callGas == 0 {
+		v.add(SeverityWarning, "callGasLimit", "is zero, so callData cannot execute")
+	}
+	if preVerificationGas == 0 {
+		v.add(SeverityWarning, "preVerificationGas", "is zero; bundlers require at least the calldata cost")
+	}
+	v.signature(signature)
+}
+
+func (v *validator) fees(maxFee, maxPriorityFee *big.Int) {
+	if maxFee == nil || maxPriorityFee == nil {
+		v.add(SeverityError, "maxFeePerGas", "max fee fields are required")
+		return
+	}
+	for _, f := range []struct {
+		field string
+		value *big.Int
+	}{{"maxFeePerGas", maxFee}, {"maxPriorityFeePerGas", maxPriorityFee}} {
+		if f.value.Sign() < 0 || f.value.Cmp(maxGasValue) > 0 {
+			v.add(SeverityError, f.field, "does not fit in uint120")
+		}
+	}
+	if maxPriorityFee.Cmp(maxFee) > 0 {
+		v.add(SeverityError, "maxPriorityFeePerGas", "%s exceeds maxFeePerGas %s", maxPriorityFee, maxFee)
+	}
+	if maxFee.Sign() == 0 {
+		v.add(SeverityWarning, "maxFeePerGas", "is zero; bundlers will not include the operation")
+	}
+}
+
+func (v *validator) verificationGas(field string, gas uint64) {
+	if gas == 0 {
+		v.add(SeverityError, field, "is zero, so validation runs out of gas")
+	}
+}
+
+// gasSum checks that the gas limits the EntryPoint adds up fit in uint64 and in MaxTotalGas.
+func (v *validator) gasSum(limits ...*big.Int) {
+	total := new(big.Int)
+	for _, limit := range limits {
+		total.Add(total, limit)
+	}
+	if total.Cmp(maxGasLimitsSum) > 0 {
+		v.add(SeverityError, "gas", "gas limits sum to %s, which overflows uint64", total)
+		return
+	}
+	if v.opts.MaxTotalGas != 0 && total.Uint64() > v.opts.MaxTotalGas {
+		v.add(SeverityError, "gas", "gas limits sum to %d, above the maximum of %d", total.Uint64(), v.opts.MaxTotalGas)
+	}
+}
+
+func (v *validator) signature(signature []byte) {
+	want := v.opts.SignatureLength
+	if want < 0 {
+		return
+	}
+	if want == 0 {
+		want = crypto.SignatureLength
+	}
+	if len(signature) != want {
+		v.add(SeverityError, "signature", "must be %d bytes, got %d", want, len(signature))
+		return
+	}
+	if want != crypto.SignatureLength {
+		return
+	}
+	if recID := signature[crypto.RecoveryIDOffset]; recID > 1 && recID != 27 && recID != 28 {
+		v.add(SeverityError, "signature", "v must be 0, 1, 27 or 28, got %d", recID)
+	}
+	if new(big.Int).SetBytes(signature[32:64]).Cmp(secp256k1HalfN) > 0 {
+		v.add(SeverityError, "signature", "s is in the upper half of the curve order")
+	}
+}
+
+func (v *validator) eip7702(u UserOperationV07) {
+	if u.EIP7702Auth == nil {
+		switch {
+		case !v.opts.CheckDeployment:
+			v.add(SeverityWarning, "eip7702Auth", "missing; the sender must already be delegated")
+		case !isDelegated(v.opts.SenderCode):
+			v.add(SeverityError, "eip7702Auth", "missing and the sender is not delegated")
+		}
+		return
+	}
+	if v.opts.ChainID != nil && v.opts.EntryPoint != (common.Address{}) {
+		if err := ValidateEIP7702(u, v.opts.EntryPoint, v.opts.ChainID); err != nil {
+			v.add(SeverityError, "eip7702Auth", "%v", err)
+			return
+		}
+	} else if _, err := u.EIP7702Auth.Authorization(); err != nil {
+		v.add(SeverityError, "eip7702Auth", "%v", err)
+		return
+	}
+	if v.opts.CheckDeployment {
+		if delegate, ok := eip7702.ParseDelegationCode(v.opts.SenderCode); ok && delegate == u.EIP7702Auth.Address {
+			v.add(SeverityInfo, "eip7702Auth", "sender is already delegated to %s", delegate.Hex())
+		}
+	}
+}
+
+// deployment compares initCode with the code at sender. EIP-7702 senders must be EOAs or
+// already delegated; other senders must be deployed exactly when there is no initCode.
+func (v *validator) deployment(hasInitCode, eip7702Op bool) {
+	if !v.opts.CheckDeployment {
+		return
+	}
+	code := v.opts.SenderCode
+	switch {
+	case eip7702Op:
+		if len(code) > 0 && !isDelegated(code) {
+			v.add(SeverityError, "sender", "has contract code and cannot be an EIP-7702 account")
+		}
+	case hasInitCode && len(code) > 0:
+		v.add(SeverityError, "initCode", "sender is already deployed (AA10)")
+	case !hasInitCode && len(code) == 0:
+		v.add(SeverityError, "sender", "has no code and the operation has no initCode (AA20)")
+	}
+}
+
+func isDelegated(code []byte) bool {
+	_, ok := eip7702.ParseDelegationCode(code)
+	return ok
+}
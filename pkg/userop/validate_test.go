@@ -0,0 +1,292 @@
+package userop_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/big"
+	"net/http"
+	"slices"
+	"sync/atomic"
+	"testing"
+
+	"github.com/eipcodelab/eip7702-go/pkg/eip7702"
+	"github.com/eipcodelab/eip7702-go/pkg/userop"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// validSignature is a well-formed 65-byte ECDSA signature shape.
+var validSignature = append(bytes.Repeat([]byte{0x11}, 64), 27)
+
+// findingFields returns "severity:field" for every finding.
+func findingFields(findings userop.Findings) []string {
+	out := make([]string, len(findings))
+	for i, f := range findings {
+		out[i] = f.Severity.String() + ":" + f.Field
+	}
+	return out
+}
+
+func TestValidateUserOperation(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(*userop.UserOperation)
+		opts   userop.ValidationOptions
+		want   []string
+	}{
+		{name: "valid", mutate: func(*userop.UserOperation) {}},
+		{
+			name:   "priority above max fee",
+			mutate: func(op *userop.UserOperation) { op.MaxPriorityFeePerGas = userop.HexBig(big.NewInt(40_000_000_000)) },
+			want:   []string{"error:maxPriorityFeePerGas"},
+		},
+		{
+			name:   "fee overflows uint120",
+			mutate: func(op *userop.UserOperation) { op.MaxFeePerGas = userop.HexBig(new(big.Int).Lsh(big.NewInt(1), 120)) },
+			want:   []string{"error:maxFeePerGas"},
+		},
+		{
+			name:   "short paymasterAndData",
+			mutate: func(op *userop.UserOperation) { op.PaymasterAndData = []byte{0x01, 0x02} },
+			want:   []string{"error:paymasterAndData"},
+		},
+		{
+			name:   "short initCode",
+			mutate: func(op *userop.UserOperation) { op.InitCode = []byte{0x01, 0x02, 0x03} },
+			want:   []string{"error:initCode"},
+		},
+		{
+			name:   "zero factory",
+			mutate: func(op *userop.UserOperation) { op.InitCode = make([]byte, 24) },
+			want:   []string{"error:initCode"},
+		},
+		{
+			name:   "eip-7702 initCode",
+			mutate: func(op *userop.UserOperation) { op.InitCode = userop.EIP7702FactoryMarker.Bytes() },
+			want:   []string{"error:initCode"},
+		},
+		{
+			name:   "signature length",
+			mutate: func(op *userop.UserOperation) { op.Signature = []byte{0xaa} },
+			want:   []string{"error:signature"},
+		},
+		{
+			name:   "custom signature length",
+			mutate: func(op *userop.UserOperation) { op.Signature = make([]byte, 130) },
+			opts:   userop.ValidationOptions{SignatureLength: 130},
+		},
+		{
+			name:   "bad v",
+			mutate: func(op *userop.UserOperation) { op.Signature[64] = 5 },
+			want:   []string{"error:signature"},
+		},
+		{
+			name: "high s",
+			mutate: func(op *userop.UserOperation) {
+				copy(op.Signature[32:64], bytes.Repeat([]byte{0xff}, 32))
+			},
+			want: []string{"error:signature"},
+		},
+		{
+			name: "zero gas",
+			mutate: func(op *userop.UserOperation) {
+				op.CallGasLimit, op.VerificationGasLimit, op.PreVerificationGas = 0, 0, 0
+			},
+			want: []string{"warning:callGasLimit", "warning:preVerificationGas", "error:verificationGasLimit"},
+		},
+		{
+			name: "paymaster triples verification gas",
+			mutate: func(op *userop.UserOperation) {
+				op.PaymasterAndData = common.HexToAddress("0x9a11").Bytes()
+			},
+			opts: userop.ValidationOptions{MaxTotalGas: 800_000},
+			want: []string{"error:gas"},
+		},
+		{
+			name:   "gas sum overflows",
+			mutate: func(op *userop.UserOperation) { op.CallGasLimit, op.PreVerificationGas = ^userop.HexUint64(0), 1 },
+			want:   []string{"error:gas"},
+		},
+		{
+			name:   "newer entry point",
+			mutate: func(*userop.UserOperation) {},
+			opts:   userop.ValidationOptions{EntryPoint: userop.EntryPointV07Address},
+			want:   []string{"error:entryPoint"},
+		},
+		{
+			name:   "sender not deployed",
+			mutate: func(*userop.UserOperation) {},
+			opts:   userop.ValidationOptions{CheckDeployment: true},
+			want:   []string{"error:sender"},
+		},
+		{
+			name: "sender already deployed",
+			mutate: func(op *userop.UserOperation) {
+				op.InitCode = common.HexToAddress("0xfac70").Bytes()
+			},
+			opts: userop.ValidationOptions{CheckDeployment: true, SenderCode: []byte{0x60, 0x80}},
+			want: []string{"error:initCode"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			op := makeUserOp()
+			op.Signature = slices.Clone(validSignature)
+			tt.mutate(&op)
+			got := findingFields(op.Validate(tt.opts))
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("findings = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateUserOperationV07(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(*userop.UserOperationV07)
+		opts   userop.ValidationOptions
+		want   []string
+	}{
+		{name: "valid", mutate: func(*userop.UserOperationV07) {}},
+		{
+			name: "paymaster fields without paymaster",
+			mutate: func(op *userop.UserOperationV07) {
+				op.Paymaster = nil
+			},
+			want: []string{"error:paymaster"},
+		},
+		{
+			name:   "paymaster without verification gas",
+			mutate: func(op *userop.UserOperationV07) { op.PaymasterVerificationGasLimit = 0 },
+			want:   []string{"error:paymasterVerificationGasLimit"},
+		},
+		{
+			name:   "factoryData without factory",
+			mutate: func(op *userop.UserOperationV07) { op.Factory = nil },
+			want:   []string{"error:factoryData"},
+		},
+		{
+			name:   "gas above bundle limit",
+			mutate: func(*userop.UserOperationV07) {},
+			opts:   userop.ValidationOptions{MaxTotalGas: 400_000},
+			want:   []string{"error:gas"},
+		},
+		{
+			name:   "v0.6 entry point",
+			mutate: func(*userop.UserOperationV07) {},
+			opts:   userop.ValidationOptions{EntryPoint: userop.EntryPointV06Address},
+			want:   []string{"error:entryPoint"},
+		},
+		{
+			name: "7702 marker without auth",
+			mutate: func(op *userop.UserOperationV07) {
+				marker := userop.EIP7702FactoryMarker
+				op.Factory, op.FactoryData = &marker, nil
+			},
+			want: []string{"warning:eip7702Auth"},
+		},
+		{
+			name: "7702 marker for undelegated sender",
+			mutate: func(op *userop.UserOperationV07) {
+				marker := userop.EIP7702FactoryMarker
+				op.Factory, op.FactoryData = &marker, nil
+			},
+			opts: userop.ValidationOptions{CheckDeployment: true},
+			want: []string{"error:eip7702Auth"},
+		},
+		{
+			name: "7702 sender with contract code",
+			mutate: func(op *userop.UserOperationV07) {
+				marker := userop.EIP7702FactoryMarker
+				op.Factory, op.FactoryData = &marker, nil
+			},
+			opts: userop.ValidationOptions{CheckDeployment: true, SenderCode: []byte{0x60, 0x80}},
+			want: []string{"error:eip7702Auth", "error:sender"},
+		},
+		{
+			name: "delegated sender without initCode",
+			mutate: func(op *userop.UserOperationV07) {
+				op.Factory, op.FactoryData = nil, nil
+			},
+			opts: userop.ValidationOptions{CheckDeployment: true, SenderCode: eip7702.DelegationCode(common.HexToAddress("0x1111"))},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			op := makeUserOpV07()
+			op.Signature = slices.Clone(validSignature)
+			tt.mutate(&op)
+			got := findingFields(op.Validate(tt.opts))
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("findings = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateEIP7702Operation(t *testing.T) {
+	op, auth := makeEIP7702UserOp(t)
+	opts := userop.ValidationOptions{EntryPoint: userop.EntryPointV08Address, ChainID: big.NewInt(1), CheckDeployment: true}
+	if findings := op.Validate(opts); len(findings) != 0 {
+		t.Fatalf("findings = %v", findings)
+	}
+
+	opts.SenderCode = eip7702.DelegationCode(auth.Address)
+	if got := findingFields(op.Validate(opts)); !slices.Equal(got, []string{"info:eip7702Auth"}) {
+		t.Fatalf("already delegated findings = %v", got)
+	}
+
+	opts.SenderCode = nil
+	opts.ChainID = big.NewInt(2)
+	if got := findingFields(op.Validate(opts)); !slices.Equal(got, []string{"error:eip7702Auth"}) {
+		t.Fatalf("wrong chain findings = %v", got)
+	}
+
+	opts.ChainID = big.NewInt(1)
+	opts.EntryPoint = userop.EntryPointV07Address
+	if got := findingFields(op.Validate(opts)); !slices.Contains(got, "error:entryPoint") {
+		t.Fatalf("v0.7 entry point findings = %v", got)
+	}
+}
+
+func TestFindingsErr(t *testing.T) {
+	op := makeUserOp()
+	op.Signature = slices.Clone(validSignature)
+	op.PreVerificationGas = 0
+	findings := op.Validate(userop.ValidationOptions{})
+	if !slices.Equal(findingFields(findings), []string{"warning:preVerificationGas"}) || findings.HasErrors() || findings.Err() != nil {
+		t.Fatalf("warnings only: %v", findings)
+	}
+
+	op.MaxPriorityFeePerGas = userop.HexBig(big.NewInt(40_000_000_000))
+	err := op.Validate(userop.ValidationOptions{}).Err()
+	var validationErr *userop.ValidationError
+	if !errors.As(err, &validationErr) || len(validationErr.Findings) != 1 || !errors.Is(err, userop.ErrInvalidParams) {
+		t.Fatalf("Err() = %v", err)
+	}
+}
+
+func TestSendUserOperationRunsConfiguredValidation(t *testing.T) {
+	var calls atomic.Int32
+	client := userop.NewBundlerClientWithOptions("http://bundler.invalid", userop.ClientOptions{
+		HTTPClient: &http.Client{Transport: roundTripFunc(func(*http.Request) (*http.Response, error) {
+			calls.Add(1)
+			return jsonResponse(http.StatusOK, `{"jsonrpc":"2.0","id":1,"result":"0x01"}`), nil
+		})},
+		Validation: &userop.ValidationOptions{},
+	})
+	op := makeUserOp()
+	op.MaxPriorityFeePerGas = userop.HexBig(big.NewInt(40_000_000_000))
+	op.Signature = slices.Clone(validSignature)
+	_, err := client.SendUserOperation(context.Background(), op, userop.EntryPointV06Address)
+	if !errors.Is(err, userop.ErrInvalidParams) || calls.Load() != 0 {
+		t.Fatalf("error = %v, calls = %d", err, calls.Load())
+	}
+
+	// makeUserOpV07 carries a 2-byte placeholder signature.
+	_, err = client.SendUserOperationV07(context.Background(), makeUserOpV07(), userop.EntryPointV07Address)
+	if !errors.Is(err, userop.ErrInvalidParams) || calls.Load() != 0 {
+		t.Fatalf("error = %v, calls = %d", err, calls.Load())
+	}
+}
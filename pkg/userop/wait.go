@@ -0,0 +1,139 @@
+package userop
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/eipcodelab/eip7702-go/pkg/batching"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+const (
+	defaultPollInterval    = time.Second
+	defaultMaxPollInterval = 10 * time.Second
+	defaultPollMultiplier  = 1.5
+)
+
+// WaitOptions configures WaitForUserOperation. Zero values use the defaults.
+type WaitOptions struct {
+	// PollInterval is the first delay between receipt polls. Default 1s.
+	PollInterval time.Duration
+	// MaxPollInterval caps the backoff delay. Default 10s.
+	MaxPollInterval time.Duration
+	// Multiplier grows the delay after every empty poll. Default 1.5.
+	Multiplier float64
+	// Timeout bounds the whole wait in addition to ctx. Zero means no extra bound.
+	Timeout time.Duration
+	// Confirmations is the number of blocks required on top of the inclusion block.
+	// It requires the endpoint to serve eth_blockNumber.
+	Confirmations uint64
+}
+
+// WaitResult is the outcome of an included user operation.
+type WaitResult struct {
+	Receipt *UserOperationReceipt
+	// Success reports whether the account call succeeded.
+	Success bool
+	// RevertReason is the raw data from the UserOperationRevertReason log, if emitted.
+	RevertReason []byte
+	// Revert is RevertReason decoded with batching.DecodeRevert.
+	Revert *batching.Revert
+}
+
+// WaitForUserOperation polls eth_getUserOperationReceipt with backoff until the
+// operation is included (and confirmed, if requested), ctx is done or the timeout elapses.
+// Errors for which IsRetryable reports true are retried with the same backoff; the
+// last one is wrapped into the error returned when the wait ends.
+// A reverted operation is not an error; check WaitResult.Success.
+func (c *BundlerClient) WaitForUserOperation(ctx context.Context, hash common.Hash, opts WaitOptions) (*WaitResult, error) {
+	opts = opts.withDefaults()
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	delay := opts.PollInterval
+	var lastErr error
+	for {
+		receipt, err := c.GetUserOperationReceipt(ctx, hash)
+		if err == nil {
+			var confirmed bool
+			confirmed, err = c.confirmed(ctx, receipt, opts.Confirmations)
+			if err == nil && confirmed {
+				return newWaitResult(receipt), nil
+			}
+		}
+		switch {
+		case err == nil, errors.Is(err, ErrNotFound):
+		case ctx.Err() != nil:
+			return nil, waitError(hash, ctx.Err(), lastErr)
+		case IsRetryable(err):
+			lastErr = err
+		default:
+			return nil, err
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, waitError(hash, ctx.Err(), lastErr)
+		case <-timer.C:
+		}
+		delay = time.Duration(float64(delay) * opts.Multiplier)
+		if delay > opts.MaxPollInterval {
+			delay = opts.MaxPollInterval
+		}
+	}
+}
+
+func waitError(hash common.Hash, ctxErr, lastErr error) error {
+	if lastErr != nil {
+		return fmt.Errorf("wait for user operation %s: %w (last error: %w)", hash.Hex(), ctxErr, lastErr)
+	}
+	return fmt.Errorf("wait for user operation %s: %w", hash.Hex(), ctxErr)
+}
+
+func (c *BundlerClient) confirmed(ctx context.Context, receipt *UserOperationReceipt, confirmations uint64) (bool, error) {
+	if confirmations == 0 {
+		return true, nil
+	}
+	if receipt.Receipt.BlockNumber == nil {
+		return false, errors.New("receipt has no block number")
+	}
+	var head hexutil.Uint64
+	if err := c.Call(ctx, &head, "eth_blockNumber"); err != nil {
+		return false, fmt.Errorf("read block number: %w", err)
+	}
+	included := receipt.Receipt.BlockNumber.ToInt().Uint64()
+	return uint64(head) >= included+confirmations, nil
+}
+
+func newWaitResult(receipt *UserOperationReceipt) *WaitResult {
+	result := &WaitResult{Receipt: receipt, Success: receipt.Success}
+	if reason := receipt.RevertReason(); reason != nil {
+		result.RevertReason = reason.RevertReason
+		result.Revert = batching.DecodeRevert(reason.RevertReason, nil)
+	}
+	return result
+}
+
+func (o WaitOptions) withDefaults() WaitOptions {
+	if o.PollInterval <= 0 {
+		o.PollInterval = defaultPollInterval
+	}
+	if o.MaxPollInterval <= 0 {
+		o.MaxPollInterval = defaultMaxPollInterval
+	}
+	if o.MaxPollInterval < o.PollInterval {
+		o.MaxPollInterval = o.PollInterval
+	}
+	if o.Multiplier < 1 {
+		o.Multiplier = defaultPollMultiplier
+	}
+	return o
+}
@@ -0,0 +1,179 @@
+package userop_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/eipcodelab/eip7702-go/pkg/batching"
+	"github.com/eipcodelab/eip7702-go/pkg/userop"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+var fastWait = userop.WaitOptions{PollInterval: time.Millisecond, MaxPollInterval: 5 * time.Millisecond}
+
+// revertReasonLog builds a UserOperationRevertReason log carrying Error("nope").
+func revertReasonLog() map[string]any {
+	reason := common.FromHex("0x08c379a0" +
+		"0000000000000000000000000000000000000000000000000000000000000020" +
+		"0000000000000000000000000000000000000000000000000000000000000004" +
+		"6e6f706500000000000000000000000000000000000000000000000000000000")
+	data := fmt.Sprintf("0x%064x%064x%064x%x", 1, 0x40, len(reason), common.RightPadBytes(reason, (len(reason)+31)/32*32))
+	return map[string]any{
+		"address":         userop.EntryPointV07Address,
+		"topics":          []common.Hash{userop.UserOperationRevertReasonTopic, testUserOpHash, common.BytesToHash(makeUserOpV07().Sender.Bytes())},
+		"data":            data,
+		"blockNumber":     "0x10",
+		"transactionHash": common.HexToHash("0xbb"),
+		"logIndex":        "0x0",
+	}
+}
+
+func TestWaitForUserOperationPollsUntilIncluded(t *testing.T) {
+	var polls atomic.Int32
+	srv := newRPCServer(t, func(call rpcCall) (any, map[string]any) {
+		if call.Method != "eth_getUserOperationReceipt" {
+			return nil, map[string]any{"code": -32601, "message": "method not found"}
+		}
+		if polls.Add(1) < 3 {
+			return nil, nil
+		}
+		return receiptFixture(true), nil
+	})
+
+	result, err := userop.NewBundlerClient(srv.URL).WaitForUserOperation(context.Background(), testUserOpHash, fastWait)
+	if err != nil {
+		t.Fatalf("WaitForUserOperation() error = %v", err)
+	}
+	if got := polls.Load(); got != 3 {
+		t.Fatalf("polls = %d, want 3", got)
+	}
+	if !result.Success || result.Revert != nil || result.RevertReason != nil {
+		t.Fatalf("result = %+v, want success without revert", result)
+	}
+	if result.Receipt.UserOpHash != testUserOpHash {
+		t.Fatalf("receipt hash = %s", result.Receipt.UserOpHash.Hex())
+	}
+}
+
+func TestWaitForUserOperationDecodesRevertReason(t *testing.T) {
+	srv := newRPCServer(t, func(call rpcCall) (any, map[string]any) {
+		receipt := receiptFixture(false)
+		inner := receipt["receipt"].(map[string]any)
+		inner["logs"] = append(inner["logs"].([]any), revertReasonLog())
+		return receipt, nil
+	})
+
+	result, err := userop.NewBundlerClient(srv.URL).WaitForUserOperation(context.Background(), testUserOpHash, fastWait)
+	if err != nil {
+		t.Fatalf("WaitForUserOperation() error = %v", err)
+	}
+	if result.Success {
+		t.Fatal("expected failed operation")
+	}
+	if result.Revert == nil || result.Revert.Kind != batching.RevertError || result.Revert.Message != "nope" {
+		t.Fatalf("revert = %+v, want Error(nope)", result.Revert)
+	}
+}
+
+func TestWaitForUserOperationWaitsForConfirmations(t *testing.T) {
+	var head atomic.Uint64
+	head.Store(0x10)
+	srv := newRPCServer(t, func(call rpcCall) (any, map[string]any) {
+		switch call.Method {
+		case "eth_blockNumber":
+			return hexutil.Uint64(head.Add(1) - 1), nil
+		default:
+			return receiptFixture(true), nil
+		}
+	})
+
+	opts := fastWait
+	opts.Confirmations = 3
+	result, err := userop.NewBundlerClient(srv.URL).WaitForUserOperation(context.Background(), testUserOpHash, opts)
+	if err != nil {
+		t.Fatalf("WaitForUserOperation() error = %v", err)
+	}
+	if got := head.Load(); got != 0x14 {
+		t.Fatalf("head after wait = %#x, want 0x14", got)
+	}
+	if !result.Success {
+		t.Fatal("expected success")
+	}
+}
+
+func TestWaitForUserOperationTimeout(t *testing.T) {
+	srv := newRPCServer(t, func(call rpcCall) (any, map[string]any) {
+		return nil, nil
+	})
+
+	opts := fastWait
+	opts.Timeout = 20 * time.Millisecond
+	_, err := userop.NewBundlerClient(srv.URL).WaitForUserOperation(context.Background(), testUserOpHash, opts)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("error = %v, want deadline exceeded", err)
+	}
+}
+
+func TestWaitForUserOperationReturnsRPCErrors(t *testing.T) {
+	srv := newRPCServer(t, func(call rpcCall) (any, map[string]any) {
+		return nil, map[string]any{"code": -32603, "message": "boom"}
+	})
+
+	_, err := userop.NewBundlerClient(srv.URL).WaitForUserOperation(context.Background(), testUserOpHash, fastWait)
+	if err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Fatalf("error = %v, want rpc error", err)
+	}
+}
+
+func TestWaitForUserOperationRetriesTransientErrors(t *testing.T) {
+	var polls atomic.Int32
+	rpc := newRPCServer(t, func(call rpcCall) (any, map[string]any) {
+		return receiptFixture(true), nil
+	})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if polls.Add(1) == 1 {
+			http.Error(w, "service unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		rpc.Config.Handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	result, err := userop.NewBundlerClient(srv.URL).WaitForUserOperation(context.Background(), testUserOpHash, fastWait)
+	if err != nil {
+		t.Fatalf("WaitForUserOperation() error = %v", err)
+	}
+	if !result.Success || polls.Load() != 2 {
+		t.Fatalf("result = %+v after %d polls", result, polls.Load())
+	}
+}
+
+func TestWaitForUserOperationTimeoutKeepsLastError(t *testing.T) {
+	srv := newRPCServer(t, func(call rpcCall) (any, map[string]any) {
+		return nil, map[string]any{"code": -32005, "message": "rate limited"}
+	})
+
+	opts := fastWait
+	opts.Timeout = 20 * time.Millisecond
+	_, err := userop.NewBundlerClient(srv.URL).WaitForUserOperation(context.Background(), testUserOpHash, opts)
+	var rpcErr *userop.RPCError
+	if !errors.Is(err, context.DeadlineExceeded) || !errors.As(err, &rpcErr) || rpcErr.Code != userop.CodeLimitExceeded {
+		t.Fatalf("error = %v, want deadline exceeded wrapping the rate-limit error", err)
+	}
+}
+
+func TestDecodeUserOperationRevertReasonRejectsOtherLogs(t *testing.T) {
+	log := &types.Log{Topics: []common.Hash{userop.UserOperationEventTopic, testUserOpHash, {}}}
+	if _, err := userop.DecodeUserOperationRevertReason(log); err == nil {
+		t.Fatal("expected error for non revert-reason log")
+	}
+}